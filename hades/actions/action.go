@@ -2,11 +2,23 @@ package actions
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/wzshiming/ctc"
 )
 
 type Action interface {
 	Execute(ctx context.Context, runtime *types.Runtime) error
 	DryRun(ctx context.Context, runtime *types.Runtime) string
 }
+
+// reportSkipped logs a skipped action and prints the skip status to the console
+func reportSkipped(runtime *types.Runtime, subject, reason string) {
+	runtime.Skipped = true
+	fmt.Fprintf(runtime.Stdout, "Skipping %s (%s)\n", subject, reason)
+	if runtime.ConsoleStdout != nil {
+		fmt.Fprintf(runtime.ConsoleStdout, "[%s] %s○%s Action %s: skipped (%s %s)\n",
+			runtime.Host.Name, ctc.ForegroundBlue, ctc.Reset, runtime.ActionDesc, subject, reason)
+	}
+}
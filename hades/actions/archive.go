@@ -0,0 +1,89 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+// archiveMarker is written into an extraction directory and holds the
+// SHA-256 of the archive that was extracted there
+const archiveMarker = ".hades-archive"
+
+// extractCommand builds the remote shell command that extracts archive into dest,
+// dropping the first strip leading path components
+func extractCommand(format, archive, dest string, strip int) (string, error) {
+	a := utils.ShellQuote(archive)
+	d := utils.ShellQuote(dest)
+
+	stripFlag := ""
+	if strip > 0 {
+		stripFlag = fmt.Sprintf(" --strip-components=%d", strip)
+	}
+
+	switch format {
+	case "tar":
+		return fmt.Sprintf("mkdir -p %s && tar -xf %s -C %s%s", d, a, d, stripFlag), nil
+	case "tar.gz":
+		return fmt.Sprintf("mkdir -p %s && tar -xzf %s -C %s%s", d, a, d, stripFlag), nil
+	case "tar.zst":
+		return fmt.Sprintf("mkdir -p %s && zstd -dc %s | tar -xf - -C %s%s", d, a, d, stripFlag), nil
+	case "zip":
+		if strip == 0 {
+			return fmt.Sprintf("mkdir -p %s && unzip -qo %s -d %s", d, a, d), nil
+		}
+		// unzip cannot strip components: extract into a staging directory and
+		// copy the entries found below the stripped levels
+		return fmt.Sprintf("mkdir -p %s && staging=$(mktemp -d) && unzip -qo %s -d \"$staging\" && "+
+			"(cd \"$staging\" && find . -mindepth %d -maxdepth %d -exec cp -a {} %s/ \\;) && rm -rf \"$staging\"",
+			d, a, strip+1, strip+1, d), nil
+	default:
+		return "", fmt.Errorf("unsupported archive format %q (expected tar, tar.gz, tar.zst or zip)", format)
+	}
+}
+
+// writeMarkerCommand builds the remote shell command that records checksum in dest
+func writeMarkerCommand(dest, checksum string) string {
+	return fmt.Sprintf("printf '%%s\\n' %s > %s", checksum, utils.ShellQuote(path.Join(dest, archiveMarker)))
+}
+
+// remoteTempFile creates a file only this run uses in /tmp on the host, so an
+// archive upload cannot collide with another run or follow a planted symlink
+func remoteTempFile(ctx context.Context, sess ssh.Session, prefix string) (string, error) {
+	var stdout bytes.Buffer
+	if err := sess.Run(ctx, fmt.Sprintf("mktemp /tmp/%s.XXXXXXXXXX", prefix), &stdout, io.Discard); err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := strings.TrimSpace(stdout.String())
+	if !strings.HasPrefix(tmpPath, "/tmp/"+prefix+".") {
+		return "", fmt.Errorf("unexpected temporary file %q", tmpPath)
+	}
+	return tmpPath, nil
+}
+
+// removeRemoteFile removes a temporary file after a failed step, best effort
+func removeRemoteFile(ctx context.Context, sess ssh.Session, path string) {
+	sess.Run(ctx, "rm -f "+utils.ShellQuote(path), io.Discard, io.Discard)
+}
+
+// cleanupCommand wraps cmds so that path is removed whether they succeed or
+// not, keeping their exit status
+func cleanupCommand(cmds []string, path string) string {
+	return fmt.Sprintf("{ %s; }; status=$?; rm -f %s; exit $status", strings.Join(cmds, " && "), utils.ShellQuote(path))
+}
+
+// getArchiveMarker returns the checksum recorded in dest, or "" if there is none
+func getArchiveMarker(ctx context.Context, sess ssh.Session, dest string) string {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("cat %s 2>/dev/null || true", utils.ShellQuote(path.Join(dest, archiveMarker)))
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(stdout.String())
+}
@@ -0,0 +1,122 @@
+package actions
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+func TestExtractCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		strip   int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "tar.gz with strip",
+			format: "tar.gz",
+			strip:  1,
+			want:   "mkdir -p '/opt/app' && tar -xzf '/tmp/a' -C '/opt/app' --strip-components=1",
+		},
+		{
+			name:   "tar.zst",
+			format: "tar.zst",
+			want:   "mkdir -p '/opt/app' && zstd -dc '/tmp/a' | tar -xf - -C '/opt/app'",
+		},
+		{
+			name:   "zip",
+			format: "zip",
+			want:   "mkdir -p '/opt/app' && unzip -qo '/tmp/a' -d '/opt/app'",
+		},
+		{
+			name:    "unknown format",
+			format:  "rar",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractCommand(tt.format, "/tmp/a", "/opt/app", tt.strip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnarchiveAction_DryRun(t *testing.T) {
+	action := NewUnarchiveAction(&schema.ActionUnarchive{
+		Registry:        "releases",
+		Name:            "app.tar.gz",
+		Tag:             "${TAG}",
+		Dst:             "/opt/app/${TAG}",
+		StripComponents: 1,
+	})
+
+	runtime := &types.Runtime{
+		Env: map[string]string{"TAG": "v1.2.0"},
+	}
+
+	result := action.DryRun(context.Background(), runtime)
+	expected := "unarchive: releases/app.tar.gz:v1.2.0 to /opt/app/v1.2.0 (tar.gz, strip 1, skip if unchanged)"
+
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestUnarchiveAction_TempFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.tar.gz")
+	if err := os.WriteFile(src, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const tmpPath = "/tmp/hades-unarchive.Xr4nd0m"
+	var commands []string
+	var uploaded string
+	client := &sessionClient{session: &mockSession{
+		runFunc: func(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+			commands = append(commands, cmd)
+			if strings.HasPrefix(cmd, "mktemp /tmp/hades-unarchive.") {
+				io.WriteString(stdout, tmpPath+"\n")
+			}
+			return nil
+		},
+		copyFileFunc: func(ctx context.Context, content io.Reader, remotePath string, mode uint32) error {
+			uploaded = remotePath
+			return nil
+		},
+	}}
+	runtime := &types.Runtime{
+		SSHClient: client,
+		Env:       map[string]string{},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+
+	action := NewUnarchiveAction(&schema.ActionUnarchive{Src: src, Dst: "/opt/app"})
+	if err := action.Execute(context.Background(), runtime); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if uploaded != tmpPath {
+		t.Errorf("Expected the archive to be uploaded to %s, got %s", tmpPath, uploaded)
+	}
+	last := commands[len(commands)-1]
+	if want := "status=$?; rm -f '" + tmpPath + "'; exit $status"; !strings.Contains(last, want) {
+		t.Errorf("Expected the archive to be removed even if extraction fails, got %q", last)
+	}
+}
@@ -0,0 +1,250 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+type AuthorizedKeyAction struct {
+	User      string
+	Key       string
+	Src       string
+	URL       string
+	Path      string
+	State     string
+	Exclusive bool
+}
+
+func NewAuthorizedKeyAction(action *schema.ActionAuthorizedKey) Action {
+	state := action.State
+	if state == "" {
+		state = "present"
+	}
+	return &AuthorizedKeyAction{
+		User:      action.User,
+		Key:       action.Key,
+		Src:       action.Src,
+		URL:       action.URL,
+		Path:      action.Path,
+		State:     state,
+		Exclusive: action.Exclusive,
+	}
+}
+
+func (a *AuthorizedKeyAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	user, err := expandEnv(a.User, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand user: %w", err)
+	}
+
+	keys, err := a.readKeys(ctx, runtime)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys found in %s", a.sourceDesc())
+	}
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	account, err := lookupUser(ctx, sess, user)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return fmt.Errorf("user %q does not exist", user)
+	}
+
+	keysPath := path.Join(account.home, ".ssh", "authorized_keys")
+	if a.Path != "" {
+		if keysPath, err = expandEnv(a.Path, runtime.Env); err != nil {
+			return fmt.Errorf("failed to expand path: %w", err)
+		}
+	}
+
+	var existing bytes.Buffer
+	cmd := fmt.Sprintf("cat %s 2>/dev/null || true", utils.ShellQuote(keysPath))
+	if err := sess.Run(ctx, cmd, &existing, io.Discard); err != nil {
+		return fmt.Errorf("failed to read %s: %w", keysPath, err)
+	}
+
+	updated := mergeAuthorizedKeys(existing.String(), keys, a.State, a.Exclusive)
+	if updated == existing.String() {
+		reportSkipped(runtime, keysPath, "keys already "+a.State)
+		return nil
+	}
+
+	for _, line := range diffLines(existing.String(), updated) {
+		fmt.Fprintf(runtime.Stdout, "%s %s\n", line[:1], keyIdentity(line[1:]))
+	}
+
+	// sshd ignores keys in directories other users can write to
+	dir := path.Dir(keysPath)
+	owner := utils.ShellQuote(user + ":")
+	prepare := fmt.Sprintf("mkdir -p %s", utils.ShellQuote(dir))
+	if a.Path == "" {
+		prepare += fmt.Sprintf(" && chmod 700 %s && chown %s %s", utils.ShellQuote(dir), owner, utils.ShellQuote(dir))
+	}
+	if err := sess.Run(ctx, prepare, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to prepare %s: %w", dir, err)
+	}
+
+	if err := sess.CopyFile(ctx, strings.NewReader(updated), keysPath, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", keysPath, err)
+	}
+
+	chown := fmt.Sprintf("chown %s %s", owner, utils.ShellQuote(keysPath))
+	if err := sess.Run(ctx, chown, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", keysPath, err)
+	}
+
+	return nil
+}
+
+func (a *AuthorizedKeyAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	user, _ := expandEnv(a.User, runtime.Env)
+
+	details := a.State
+	if a.Exclusive {
+		details += ", exclusive"
+	}
+	return fmt.Sprintf("authorized_key: %s for %s (%s)", a.sourceDesc(), user, details)
+}
+
+func (a *AuthorizedKeyAction) sourceDesc() string {
+	switch {
+	case a.Src != "":
+		return a.Src
+	case a.URL != "":
+		return a.URL
+	default:
+		return "inline key"
+	}
+}
+
+// readKeys loads the public keys from the literal key, local file or URL
+func (a *AuthorizedKeyAction) readKeys(ctx context.Context, runtime *types.Runtime) ([]string, error) {
+	var content string
+	switch {
+	case a.Src != "":
+		src, err := expandEnv(a.Src, runtime.Env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand src: %w", err)
+		}
+		data, err := os.ReadFile(runtime.ResolvePath(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keys from %s: %w", src, err)
+		}
+		content = string(data)
+	case a.URL != "":
+		url, err := expandEnv(a.URL, runtime.Env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand url: %w", err)
+		}
+		data, err := downloadURL(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		content = string(data)
+	default:
+		key, err := expandEnv(a.Key, runtime.Env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand key: %w", err)
+		}
+		content = key
+	}
+
+	return parseAuthorizedKeys(content), nil
+}
+
+// parseAuthorizedKeys returns the non-empty, non-comment lines of content
+func parseAuthorizedKeys(content string) []string {
+	var keys []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys
+}
+
+// keyIdentity returns the "type base64" part of an authorized_keys line,
+// ignoring options and comments, so the same key is matched regardless of them
+func keyIdentity(line string) string {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields)-1; i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, "ssh-") || strings.HasPrefix(f, "ecdsa-") || strings.HasPrefix(f, "sk-") {
+			return f + " " + fields[i+1]
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// mergeAuthorizedKeys returns the authorized_keys content after adding or
+// removing keys. In exclusive mode only the given keys are kept.
+func mergeAuthorizedKeys(existing string, keys []string, state string, exclusive bool) string {
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[keyIdentity(key)] = true
+	}
+
+	lines := splitLines(existing)
+
+	if state == "absent" {
+		var kept []string
+		for _, line := range lines {
+			if !wanted[keyIdentity(line)] {
+				kept = append(kept, line)
+			}
+		}
+		if len(kept) == len(lines) {
+			return existing
+		}
+		return joinLines(kept)
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	if exclusive {
+		for _, key := range keys {
+			if id := keyIdentity(key); !seen[id] {
+				seen[id] = true
+				result = append(result, key)
+			}
+		}
+	} else {
+		for _, line := range lines {
+			seen[keyIdentity(line)] = true
+		}
+		result = lines
+		for _, key := range keys {
+			if id := keyIdentity(key); !seen[id] {
+				seen[id] = true
+				result = append(result, key)
+			}
+		}
+	}
+
+	updated := joinLines(result)
+	if updated == existing || (len(result) == len(lines) && !exclusive) {
+		return existing
+	}
+	return updated
+}
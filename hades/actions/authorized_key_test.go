@@ -0,0 +1,59 @@
+package actions
+
+import "testing"
+
+func TestMergeAuthorizedKeys(t *testing.T) {
+	alice := "ssh-ed25519 AAAAalice alice@laptop"
+	bob := "ssh-ed25519 AAAAbob bob@laptop"
+	carol := "ssh-rsa AAAAcarol carol"
+	existing := alice + "\n" + `from="10.0.0.0/8" ` + bob + "\n"
+
+	tests := []struct {
+		name      string
+		keys      []string
+		state     string
+		exclusive bool
+		expected  string
+	}{
+		{
+			name:     "add new key",
+			keys:     []string{carol},
+			state:    "present",
+			expected: existing + carol + "\n",
+		},
+		{
+			name:     "key with different comment already present",
+			keys:     []string{"ssh-ed25519 AAAAbob other-comment"},
+			state:    "present",
+			expected: existing,
+		},
+		{
+			name:      "exclusive keeps only given keys",
+			keys:      []string{alice, carol},
+			state:     "present",
+			exclusive: true,
+			expected:  alice + "\n" + carol + "\n",
+		},
+		{
+			name:     "remove key with options",
+			keys:     []string{bob},
+			state:    "absent",
+			expected: alice + "\n",
+		},
+		{
+			name:     "remove missing key",
+			keys:     []string{carol},
+			state:    "absent",
+			expected: existing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeAuthorizedKeys(existing, tt.keys, tt.state, tt.exclusive)
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
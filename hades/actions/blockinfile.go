@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+const defaultBlockMarker = "# {mark} HADES MANAGED BLOCK"
+
+type BlockInFileAction struct {
+	Path         string
+	Block        string
+	Marker       string
+	State        string
+	InsertAfter  string
+	InsertBefore string
+	Create       bool
+	Backup       bool
+	Mode         uint32
+}
+
+func NewBlockInFileAction(action *schema.ActionBlockInFile) Action {
+	state := action.State
+	if state == "" {
+		state = "present"
+	}
+	marker := action.Marker
+	if marker == "" {
+		marker = defaultBlockMarker
+	}
+	return &BlockInFileAction{
+		Path:         action.Path,
+		Block:        action.Block,
+		Marker:       marker,
+		State:        state,
+		InsertAfter:  action.InsertAfter,
+		InsertBefore: action.InsertBefore,
+		Create:       action.Create,
+		Backup:       action.Backup,
+		Mode:         action.Mode,
+	}
+}
+
+func (a *BlockInFileAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	edit, err := a.fileEdit(runtime)
+	if err != nil {
+		return err
+	}
+	return edit.apply(ctx, runtime)
+}
+
+func (a *BlockInFileAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	path, _ := expandEnv(a.Path, runtime.Env)
+	summary := fmt.Sprintf("blockinfile: %s (%s)", path, a.State)
+
+	edit, err := a.fileEdit(runtime)
+	if err != nil {
+		return fmt.Sprintf("%s: %v", summary, err)
+	}
+	return summary + "\n" + indentDiff(edit.preview(ctx, runtime))
+}
+
+func (a *BlockInFileAction) fileEdit(runtime *types.Runtime) (*fileEdit, error) {
+	path, err := expandEnv(a.Path, runtime.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand path: %w", err)
+	}
+	block, err := expandEnv(a.Block, runtime.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand block: %w", err)
+	}
+
+	begin := strings.ReplaceAll(a.Marker, "{mark}", "BEGIN")
+	end := strings.ReplaceAll(a.Marker, "{mark}", "END")
+
+	return &fileEdit{
+		path:   path,
+		create: a.Create,
+		backup: a.Backup,
+		mode:   a.Mode,
+		edit: func(content string) (string, error) {
+			return editBlock(content, block, begin, end, a.State, a.InsertAfter, a.InsertBefore)
+		},
+	}, nil
+}
+
+// editBlock ensures the lines between the begin and end markers equal block, or
+// removes the markers and everything between them when state is absent
+func editBlock(content, block, begin, end, state, insertAfter, insertBefore string) (string, error) {
+	lines := splitLines(content)
+
+	start, stop := -1, -1
+	for i, line := range lines {
+		if start < 0 && line == begin {
+			start = i
+		} else if start >= 0 && line == end {
+			stop = i
+			break
+		}
+	}
+	found := start >= 0 && stop >= 0
+
+	if state == "absent" {
+		if !found {
+			return content, nil
+		}
+		return joinLines(append(lines[:start:start], lines[stop+1:]...)), nil
+	}
+
+	managed := append([]string{begin}, splitLines(block)...)
+	managed = append(managed, end)
+
+	if found {
+		result := append(lines[:start:start], managed...)
+		result = append(result, lines[stop+1:]...)
+		updated := joinLines(result)
+		if updated == content {
+			return content, nil
+		}
+		return updated, nil
+	}
+
+	index, err := insertIndex(lines, insertAfter, insertBefore)
+	if err != nil {
+		return "", err
+	}
+	return joinLines(insertLines(lines, index, managed...)), nil
+}
@@ -135,6 +135,7 @@ func (a *CopyAction) Execute(ctx context.Context, runtime *types.Runtime) error
 				fmt.Fprintf(runtime.ConsoleStdout, "[%s] %s○%s Action %s: skipped (%s, %s already up to date)\n",
 					runtime.Host.Name, ctc.ForegroundBlue, ctc.Reset, runtime.ActionDesc, dst, sizeStr)
 			}
+			runtime.Skipped = true
 			return nil
 		}
 
@@ -145,6 +146,7 @@ func (a *CopyAction) Execute(ctx context.Context, runtime *types.Runtime) error
 				fmt.Fprintf(runtime.ConsoleStdout, "[%s] %s○%s Action %s: skipped (%s, %s already up to date)\n",
 					runtime.Host.Name, ctc.ForegroundBlue, ctc.Reset, runtime.ActionDesc, dst, sizeStr)
 			}
+			runtime.Skipped = true
 			return nil
 		}
 
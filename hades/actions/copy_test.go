@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
 	"github.com/SoftKiwiGames/hades/hades/types"
 )
 
@@ -175,6 +176,29 @@ func (m *mockSession) CopyFile(ctx context.Context, content io.Reader, remotePat
 	return nil
 }
 
+func (m *mockSession) ReadFile(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (m *mockSession) SetEnv(env map[string]string) {}
+
+// sessionClient is an ssh.Client whose connections all share one session
+type sessionClient struct {
+	session ssh.Session
+}
+
+func (c *sessionClient) Connect(ctx context.Context, host ssh.Host) (ssh.Session, error) {
+	return c.session, nil
+}
+
+func (c *sessionClient) Forward(ctx context.Context, host ssh.Host, remoteAddr string, localPort int) (*ssh.Forward, error) {
+	return nil, nil
+}
+
+func (c *sessionClient) Close() error {
+	return nil
+}
+
 func (m *mockSession) Close() error {
 	return nil
 }
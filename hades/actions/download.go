@@ -0,0 +1,238 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/config"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+type DownloadAction struct {
+	URL      string
+	Dst      string
+	Checksum string
+	Extract  string
+	Mode     uint32
+	Owner    string
+	OnHost   bool
+}
+
+func NewDownloadAction(action *schema.ActionDownload) Action {
+	mode := action.Mode
+	if mode == 0 && action.Extract == "" {
+		mode = 0644 // Default mode for downloaded files
+	}
+	return &DownloadAction{
+		URL:      action.URL,
+		Dst:      action.Dst,
+		Checksum: action.Checksum,
+		Extract:  action.Extract,
+		Mode:     mode,
+		Owner:    action.Owner,
+		OnHost:   action.OnHost,
+	}
+}
+
+func (a *DownloadAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	// Expand environment variables in fields
+	url, err := expandEnv(a.URL, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand url: %w", err)
+	}
+
+	dst, err := expandEnv(a.Dst, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand dst: %w", err)
+	}
+
+	checksum, err := parseChecksum(a.Checksum)
+	if err != nil {
+		return err
+	}
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	// Skip if the pinned content is already in place
+	if a.Extract == "" {
+		remoteChecksum, exists, err := getRemoteChecksum(ctx, sess, dst)
+		if err != nil {
+			return fmt.Errorf("failed to check remote file: %w", err)
+		}
+		if exists && remoteChecksum == checksum {
+			reportSkipped(runtime, dst, "already up to date")
+			return nil
+		}
+	} else if getArchiveMarker(ctx, sess, dst) == checksum {
+		reportSkipped(runtime, dst, "already extracted")
+		return nil
+	}
+
+	// Download into a temporary path next to the destination, or into a
+	// unique file in /tmp for archives
+	tmpPath := dst + ".hades-download"
+	if a.Extract != "" {
+		if tmpPath, err = remoteTempFile(ctx, sess, "hades-download"); err != nil {
+			return err
+		}
+	}
+
+	if err := a.fetch(ctx, runtime, sess, url, tmpPath, checksum); err != nil {
+		removeRemoteFile(ctx, sess, tmpPath)
+		return err
+	}
+
+	// Install the file or extract the archive
+	var cmds []string
+	if a.Extract != "" {
+		extractCmd, err := extractCommand(a.Extract, tmpPath, dst, 0)
+		if err != nil {
+			removeRemoteFile(ctx, sess, tmpPath)
+			return err
+		}
+		cmds = append(cmds, extractCmd, writeMarkerCommand(dst, checksum))
+		if a.Mode != 0 {
+			cmds = append(cmds, fmt.Sprintf("chmod %o %s", a.Mode, utils.ShellQuote(dst)))
+		}
+		if a.Owner != "" {
+			cmds = append(cmds, fmt.Sprintf("chown -R %s %s", utils.ShellQuote(a.Owner), utils.ShellQuote(dst)))
+		}
+	} else {
+		cmds = append(cmds, fmt.Sprintf("chmod %o %s", a.Mode, utils.ShellQuote(tmpPath)))
+		if a.Owner != "" {
+			cmds = append(cmds, fmt.Sprintf("chown %s %s", utils.ShellQuote(a.Owner), utils.ShellQuote(tmpPath)))
+		}
+		cmds = append(cmds, fmt.Sprintf("mv -f %s %s", utils.ShellQuote(tmpPath), utils.ShellQuote(dst)))
+	}
+
+	// Always remove the downloaded file if it was not moved into place
+	if err := sess.Run(ctx, cleanupCommand(cmds, tmpPath), runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to install download to %s: %w", dst, err)
+	}
+
+	if a.Extract != "" {
+		fmt.Fprintf(runtime.Stdout, "Extracted %s to %s\n", url, dst)
+	} else {
+		fmt.Fprintf(runtime.Stdout, "Installed %s to %s\n", url, dst)
+	}
+
+	return nil
+}
+
+// fetch downloads url to tmpPath on the host, on the host itself or through
+// the controller, and verifies its checksum
+func (a *DownloadAction) fetch(ctx context.Context, runtime *types.Runtime, sess ssh.Session, url, tmpPath, checksum string) error {
+	if a.OnHost {
+		if err := sess.Run(ctx, hostDownloadCommand(url, tmpPath, checksum), runtime.Stdout, runtime.Stderr); err != nil {
+			return fmt.Errorf("failed to download %s on host: %w", url, err)
+		}
+		fmt.Fprintf(runtime.Stdout, "Downloaded %s on host (checksum verified)\n", url)
+		return nil
+	}
+
+	data, err := downloadURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != checksum {
+		return fmt.Errorf("checksum mismatch for %s: expected sha256:%s, got sha256:%s", url, checksum, got)
+	}
+	if err := sess.CopyFile(ctx, bytes.NewReader(data), tmpPath, 0600); err != nil {
+		return fmt.Errorf("failed to copy download to host: %w", err)
+	}
+	fmt.Fprintf(runtime.Stdout, "Downloaded %s (%s, checksum verified)\n", url, formatFileSize(int64(len(data))))
+	return nil
+}
+
+func (a *DownloadAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	// Expand for dry-run display
+	url, _ := expandEnv(a.URL, runtime.Env)
+	dst, _ := expandEnv(a.Dst, runtime.Env)
+
+	where := "controller"
+	if a.OnHost {
+		where = "host"
+	}
+
+	if a.Extract != "" {
+		return fmt.Sprintf("download: %s, extract %s to %s (via %s, %s)", url, a.Extract, dst, where, a.Checksum)
+	}
+	return fmt.Sprintf("download: %s to %s (mode: %o, via %s, %s)", url, dst, a.Mode, where, a.Checksum)
+}
+
+// parseChecksum validates a "sha256:<hex>" pin and returns the lowercase hex digest
+func parseChecksum(pin string) (string, error) {
+	digest, ok := strings.CutPrefix(pin, "sha256:")
+	if !ok {
+		return "", fmt.Errorf("checksum must be in the form sha256:<hex>, got %q", pin)
+	}
+	digest = strings.ToLower(digest)
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid sha256 checksum %q", pin)
+	}
+	return digest, nil
+}
+
+// hostDownloadCommand builds a shell command that downloads url to path on the
+// host with curl or wget and verifies its checksum
+func hostDownloadCommand(url, path, checksum string) string {
+	u := utils.ShellQuote(url)
+	p := utils.ShellQuote(path)
+	return fmt.Sprintf("if command -v curl >/dev/null 2>&1; then curl -fsSL -o %s %s; else wget -q -O %s %s; fi && "+
+		"if ! echo %s | sha256sum -c - >/dev/null; then rm -f %s; echo 'checksum mismatch' >&2; exit 1; fi",
+		p, u, p, u, utils.ShellQuote(checksum+"  "+path), p)
+}
+
+// downloadURL fetches url on the controller and returns the response body
+func downloadURL(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "hades/"+config.Version)
+	req.Header.Set("Accept", "*/*")
+
+	client := &http.Client{
+		Timeout: 30 * time.Minute,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to download %s: HTTP %s\nResponse: %s", url, resp.Status, string(body))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download from %s: %w", url, err)
+	}
+	return data, nil
+}
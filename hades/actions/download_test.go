@@ -0,0 +1,171 @@
+package actions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+const testChecksum = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		pin     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "valid lowercase",
+			pin:  testChecksum,
+			want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			name: "valid uppercase",
+			pin:  "sha256:E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855",
+			want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			name:    "missing prefix",
+			pin:     "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			pin:     "sha256:abc",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksum(tt.pin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDownloadAction_DryRun(t *testing.T) {
+	action := NewDownloadAction(&schema.ActionDownload{
+		URL:      "https://example.com/${NAME}.tar.gz",
+		Dst:      "/opt/${NAME}",
+		Checksum: testChecksum,
+		Extract:  "tar.gz",
+		OnHost:   true,
+	})
+
+	runtime := &types.Runtime{
+		Env: map[string]string{"NAME": "exporter"},
+	}
+
+	result := action.DryRun(context.Background(), runtime)
+	expected := "download: https://example.com/exporter.tar.gz, extract tar.gz to /opt/exporter (via host, " + testChecksum + ")"
+
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestNewDownloadAction_DefaultMode(t *testing.T) {
+	action := NewDownloadAction(&schema.ActionDownload{
+		URL:      "https://example.com/tool",
+		Dst:      "/usr/local/bin/tool",
+		Checksum: testChecksum,
+	})
+
+	if mode := action.(*DownloadAction).Mode; mode != 0644 {
+		t.Errorf("Expected default mode 0644, got %o", mode)
+	}
+}
+
+func TestDownloadAction_ChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer server.Close()
+
+	client := &mockClient{}
+	runtime := &types.Runtime{
+		SSHClient: client,
+		Env:       map[string]string{},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+
+	action := NewDownloadAction(&schema.ActionDownload{
+		URL:      server.URL,
+		Dst:      "/usr/local/bin/tool",
+		Checksum: testChecksum,
+	})
+
+	err := action.Execute(context.Background(), runtime)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected checksum mismatch error, got %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("tampered"))
+	if !strings.Contains(err.Error(), hex.EncodeToString(sum[:])) {
+		t.Errorf("Expected error to include actual checksum, got %v", err)
+	}
+}
+
+func TestDownloadAction_ExtractTempFile(t *testing.T) {
+	const tmpPath = "/tmp/hades-download.Xr4nd0m"
+	for _, failing := range []string{"curl", "tar -xzf"} {
+		t.Run(failing, func(t *testing.T) {
+			var commands []string
+			client := &sessionClient{session: &mockSession{
+				runFunc: func(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+					commands = append(commands, cmd)
+					if strings.HasPrefix(cmd, "mktemp ") {
+						io.WriteString(stdout, tmpPath+"\n")
+					}
+					if strings.Contains(cmd, failing) {
+						return fmt.Errorf("exit status 1")
+					}
+					return nil
+				},
+			}}
+			runtime := &types.Runtime{
+				SSHClient: client,
+				Env:       map[string]string{},
+				Stdout:    io.Discard,
+				Stderr:    io.Discard,
+			}
+
+			action := NewDownloadAction(&schema.ActionDownload{
+				URL:      "https://example.com/tool.tar.gz",
+				Dst:      "/opt/tool",
+				Checksum: testChecksum,
+				Extract:  "tar.gz",
+				OnHost:   true,
+			})
+			if err := action.Execute(context.Background(), runtime); err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			if !strings.HasPrefix(commands[1], "mktemp /tmp/hades-download.") {
+				t.Errorf("Expected a unique temporary file, got %q", commands[1])
+			}
+			// The archive is removed however far the action got
+			last := commands[len(commands)-1]
+			if !strings.Contains(last, "rm -f '"+tmpPath+"'") {
+				t.Errorf("Expected the archive to be removed, got %q", last)
+			}
+		})
+	}
+}
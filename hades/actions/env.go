@@ -2,14 +2,19 @@ package actions
 
 import (
 	"regexp"
+	"strings"
 )
 
-var envVarPatternShared = regexp.MustCompile(`\$\{([^}]+)\}`)
+var envVarPatternShared = regexp.MustCompile(`\$?\$\{([^}]+)\}`)
 
 // ExpandEnvVars expands ${VAR} references in a string using the provided environment
-// If a variable is not found in env, it's left as-is (${VAR})
+// If a variable is not found in env, it's left as-is (${VAR}); $${VAR} becomes ${VAR}
 func ExpandEnvVars(s string, env map[string]string) string {
 	return envVarPatternShared.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
 		// Extract variable name from ${VAR}
 		varName := match[2 : len(match)-1]
 
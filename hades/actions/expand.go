@@ -6,13 +6,19 @@ import (
 	"strings"
 )
 
-var envVarPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+// envVarPattern matches ${VAR} and the escaped form $${VAR}
+var envVarPattern = regexp.MustCompile(`\$?\$\{([^}]+)\}`)
 
-// expandEnv expands ${VAR} references in a string using the provided env map
+// expandEnv expands ${VAR} references in a string using the provided env map.
+// $${VAR} is an escape and becomes a literal ${VAR}, e.g. for shell variables.
 func expandEnv(s string, env map[string]string) (string, error) {
 	var missingVars []string
 
 	result := envVarPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
 		// Extract variable name from ${VAR}
 		varName := match[2 : len(match)-1]
 
@@ -0,0 +1,259 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+// fileEdit describes an in-place edit of a remote file shared by lineinfile and blockinfile
+type fileEdit struct {
+	path   string
+	create bool
+	backup bool
+	mode   uint32
+	edit   func(content string) (string, error)
+}
+
+// apply reads the remote file, runs the edit and writes the result back through
+// the session's atomic write path when the content changed
+func (f *fileEdit) apply(ctx context.Context, runtime *types.Runtime) error {
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	original, exists, err := f.read(ctx, sess)
+	if err != nil {
+		return err
+	}
+
+	updated, err := f.edit(original)
+	if err != nil {
+		return err
+	}
+
+	if updated == original {
+		reportSkipped(runtime, f.path, "already up to date")
+		return nil
+	}
+
+	fmt.Fprintf(runtime.Stdout, "Updating %s\n", f.path)
+	for _, line := range diffLines(original, updated) {
+		fmt.Fprintln(runtime.Stdout, line)
+	}
+
+	// Keep the current permissions unless a mode is set explicitly
+	mode := f.mode
+	if mode == 0 {
+		mode = 0644
+		if exists {
+			if perms, err := getRemotePermissions(ctx, sess, utils.ShellQuote(f.path)); err == nil {
+				mode = perms
+			}
+		}
+	}
+
+	// The atomic write replaces the file, so remember who owned it
+	var owner string
+	if exists {
+		if owner, err = getRemoteOwner(ctx, sess, f.path); err != nil {
+			return err
+		}
+	}
+
+	if f.backup && exists {
+		backupPath := fmt.Sprintf("%s.%s.bak", f.path, time.Now().Format("20060102-150405"))
+		cmd := fmt.Sprintf("cp -p %s %s", utils.ShellQuote(f.path), utils.ShellQuote(backupPath))
+		if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", f.path, err)
+		}
+		fmt.Fprintf(runtime.Stdout, "Backup written to %s\n", backupPath)
+	}
+
+	if err := sess.CopyFile(ctx, strings.NewReader(updated), f.path, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+
+	if owner != "" {
+		cmd := fmt.Sprintf("chown %s %s", owner, utils.ShellQuote(f.path))
+		if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
+			return fmt.Errorf("failed to restore owner of %s: %w", f.path, err)
+		}
+	}
+
+	return nil
+}
+
+var ownerPattern = regexp.MustCompile(`^\d+:\d+$`)
+
+// getRemoteOwner returns the numeric owner and group of a remote file as uid:gid
+func getRemoteOwner(ctx context.Context, sess ssh.Session, path string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("stat -c '%%u:%%g' %s", utils.ShellQuote(path))
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	owner := strings.TrimSpace(stdout.String())
+	if !ownerPattern.MatchString(owner) {
+		return "", fmt.Errorf("unexpected owner %q for %s", owner, path)
+	}
+	return owner, nil
+}
+
+// preview returns the diff the edit would produce on the host, for dry-run output
+func (f *fileEdit) preview(ctx context.Context, runtime *types.Runtime) string {
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Sprintf("diff unavailable: %v", err)
+	}
+	defer sess.Close()
+
+	original, _, err := f.read(ctx, sess)
+	if err != nil {
+		return fmt.Sprintf("diff unavailable: %v", err)
+	}
+
+	updated, err := f.edit(original)
+	if err != nil {
+		return fmt.Sprintf("diff unavailable: %v", err)
+	}
+	if updated == original {
+		return "no changes"
+	}
+	return strings.Join(diffLines(original, updated), "\n")
+}
+
+// read returns the current file content and whether the file exists
+func (f *fileEdit) read(ctx context.Context, sess ssh.Session) (string, bool, error) {
+	var stdout bytes.Buffer
+	check := fmt.Sprintf("test -f %s && echo exists || true", utils.ShellQuote(f.path))
+	if err := sess.Run(ctx, check, &stdout, io.Discard); err != nil {
+		return "", false, fmt.Errorf("failed to check %s: %w", f.path, err)
+	}
+	if strings.TrimSpace(stdout.String()) != "exists" {
+		if !f.create {
+			return "", false, fmt.Errorf("%s does not exist (set 'create: true' to create it)", f.path)
+		}
+		return "", false, nil
+	}
+
+	reader, err := sess.ReadFile(ctx, f.path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	return string(data), true, nil
+}
+
+// splitLines splits content into lines without their trailing newlines
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// joinLines joins lines back into file content ending with a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// insertIndex returns where new lines go: after the last line matching after,
+// before the first line matching before, or at the end of the file. "BOF" and
+// "EOF" select the start and end of the file.
+func insertIndex(lines []string, after, before string) (int, error) {
+	switch {
+	case before == "BOF":
+		return 0, nil
+	case before != "":
+		re, err := regexp.Compile(before)
+		if err != nil {
+			return 0, fmt.Errorf("invalid insert_before %q: %w", before, err)
+		}
+		for i, line := range lines {
+			if re.MatchString(line) {
+				return i, nil
+			}
+		}
+	case after != "" && after != "EOF":
+		re, err := regexp.Compile(after)
+		if err != nil {
+			return 0, fmt.Errorf("invalid insert_after %q: %w", after, err)
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if re.MatchString(lines[i]) {
+				return i + 1, nil
+			}
+		}
+	}
+	return len(lines), nil
+}
+
+// insertLines returns lines with extra inserted at index
+func insertLines(lines []string, index int, extra ...string) []string {
+	result := make([]string, 0, len(lines)+len(extra))
+	result = append(result, lines[:index]...)
+	result = append(result, extra...)
+	return append(result, lines[index:]...)
+}
+
+// diffLines returns a minimal line diff between a and b with "-" and "+" prefixes
+func diffLines(a, b string) []string {
+	x, y := splitLines(a), splitLines(b)
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+x[i])
+			i++
+		default:
+			diff = append(diff, "+"+y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, "-"+x[i])
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, "+"+y[j])
+	}
+	return diff
+}
@@ -0,0 +1,217 @@
+package actions
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+func TestEditLine(t *testing.T) {
+	sshd := "Port 22\n#PermitRootLogin yes\nPasswordAuthentication yes\n"
+
+	tests := []struct {
+		name         string
+		content      string
+		line         string
+		regexp       string
+		state        string
+		insertAfter  string
+		insertBefore string
+		expected     string
+	}{
+		{
+			name:     "replace last regexp match",
+			content:  sshd,
+			line:     "PermitRootLogin no",
+			regexp:   `^#?PermitRootLogin`,
+			state:    "present",
+			expected: "Port 22\nPermitRootLogin no\nPasswordAuthentication yes\n",
+		},
+		{
+			name:     "line already present",
+			content:  "Port 22\nPermitRootLogin no\n",
+			line:     "PermitRootLogin no",
+			regexp:   `^#?PermitRootLogin`,
+			state:    "present",
+			expected: "Port 22\nPermitRootLogin no\n",
+		},
+		{
+			name:     "append when nothing matches",
+			content:  "127.0.0.1 localhost",
+			line:     "10.0.0.5 db",
+			state:    "present",
+			expected: "127.0.0.1 localhost\n10.0.0.5 db\n",
+		},
+		{
+			name:         "insert at start of file",
+			content:      "b\n",
+			line:         "a",
+			state:        "present",
+			insertBefore: "BOF",
+			expected:     "a\nb\n",
+		},
+		{
+			name:        "insert after last match",
+			content:     "[main]\nx=1\n[other]\n",
+			line:        "y=2",
+			state:       "present",
+			insertAfter: `^x=`,
+			expected:    "[main]\nx=1\ny=2\n[other]\n",
+		},
+		{
+			name:     "remove all regexp matches",
+			content:  "a\n# drop\nb\n# drop too\n",
+			regexp:   `^# drop`,
+			state:    "absent",
+			expected: "a\nb\n",
+		},
+		{
+			name:     "absent line not present",
+			content:  "a\nb\n",
+			line:     "c",
+			state:    "absent",
+			expected: "a\nb\n",
+		},
+		{
+			name:     "create new file",
+			content:  "",
+			line:     "vm.swappiness = 10",
+			state:    "present",
+			expected: "vm.swappiness = 10\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *regexp.Regexp
+			if tt.regexp != "" {
+				re = regexp.MustCompile(tt.regexp)
+			}
+			got, err := editLine(tt.content, tt.line, re, tt.state, tt.insertAfter, tt.insertBefore)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEditBlock(t *testing.T) {
+	begin := "# BEGIN HADES MANAGED BLOCK"
+	end := "# END HADES MANAGED BLOCK"
+
+	tests := []struct {
+		name     string
+		content  string
+		block    string
+		state    string
+		expected string
+	}{
+		{
+			name:     "insert at end",
+			content:  "127.0.0.1 localhost\n",
+			block:    "10.0.0.5 db\n10.0.0.6 cache\n",
+			state:    "present",
+			expected: "127.0.0.1 localhost\n" + begin + "\n10.0.0.5 db\n10.0.0.6 cache\n" + end + "\n",
+		},
+		{
+			name:     "replace existing block",
+			content:  "a\n" + begin + "\nold\n" + end + "\nz\n",
+			block:    "new",
+			state:    "present",
+			expected: "a\n" + begin + "\nnew\n" + end + "\nz\n",
+		},
+		{
+			name:     "block unchanged",
+			content:  "a\n" + begin + "\nsame\n" + end + "\n",
+			block:    "same\n",
+			state:    "present",
+			expected: "a\n" + begin + "\nsame\n" + end + "\n",
+		},
+		{
+			name:     "remove block",
+			content:  "a\n" + begin + "\nold\n" + end + "\nz\n",
+			state:    "absent",
+			expected: "a\nz\n",
+		},
+		{
+			name:     "remove missing block",
+			content:  "a\n",
+			state:    "absent",
+			expected: "a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := editBlock(tt.content, tt.block, begin, end, tt.state, "", "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc\n", "a\nB\nc\nd\n")
+	expected := []string{"-b", "+B", "+d"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestFileEdit_PreservesOwner(t *testing.T) {
+	var commands []string
+	var writtenMode uint32
+	sess := &mockSession{
+		runFunc: func(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+			commands = append(commands, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "test -f "):
+				io.WriteString(stdout, "exists\n")
+			case strings.HasPrefix(cmd, "stat -c '%a'"):
+				io.WriteString(stdout, "640\n")
+			case strings.HasPrefix(cmd, "stat -c '%u:%g'"):
+				io.WriteString(stdout, "0:33\n")
+			}
+			return nil
+		},
+		copyFileFunc: func(ctx context.Context, content io.Reader, remotePath string, mode uint32) error {
+			commands = append(commands, "copy "+remotePath)
+			writtenMode = mode
+			return nil
+		},
+	}
+	runtime := &types.Runtime{
+		SSHClient: &sessionClient{session: sess},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+
+	edit := &fileEdit{
+		path: "/etc/app/app.conf",
+		edit: func(content string) (string, error) { return content + "debug = true\n", nil },
+	}
+	if err := edit.apply(context.Background(), runtime); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if writtenMode != 0640 {
+		t.Errorf("Expected mode 0640, got %o", writtenMode)
+	}
+	last := commands[len(commands)-1]
+	if last != "chown 0:33 '/etc/app/app.conf'" {
+		t.Errorf("Expected owner to be restored after the write, got %v", commands)
+	}
+}
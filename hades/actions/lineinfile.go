@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+type LineInFileAction struct {
+	Path         string
+	Line         string
+	Regexp       string
+	State        string
+	InsertAfter  string
+	InsertBefore string
+	Create       bool
+	Backup       bool
+	Mode         uint32
+}
+
+func NewLineInFileAction(action *schema.ActionLineInFile) Action {
+	state := action.State
+	if state == "" {
+		state = "present"
+	}
+	return &LineInFileAction{
+		Path:         action.Path,
+		Line:         action.Line,
+		Regexp:       action.Regexp,
+		State:        state,
+		InsertAfter:  action.InsertAfter,
+		InsertBefore: action.InsertBefore,
+		Create:       action.Create,
+		Backup:       action.Backup,
+		Mode:         action.Mode,
+	}
+}
+
+func (a *LineInFileAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	edit, err := a.fileEdit(runtime)
+	if err != nil {
+		return err
+	}
+	return edit.apply(ctx, runtime)
+}
+
+func (a *LineInFileAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	path, _ := expandEnv(a.Path, runtime.Env)
+	summary := fmt.Sprintf("lineinfile: %s (%s)", path, a.State)
+
+	edit, err := a.fileEdit(runtime)
+	if err != nil {
+		return fmt.Sprintf("%s: %v", summary, err)
+	}
+	return summary + "\n" + indentDiff(edit.preview(ctx, runtime))
+}
+
+func (a *LineInFileAction) fileEdit(runtime *types.Runtime) (*fileEdit, error) {
+	path, err := expandEnv(a.Path, runtime.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand path: %w", err)
+	}
+	line, err := expandEnv(a.Line, runtime.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand line: %w", err)
+	}
+
+	var re *regexp.Regexp
+	if a.Regexp != "" {
+		re, err = regexp.Compile(a.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %w", a.Regexp, err)
+		}
+	}
+
+	return &fileEdit{
+		path:   path,
+		create: a.Create,
+		backup: a.Backup,
+		mode:   a.Mode,
+		edit: func(content string) (string, error) {
+			return editLine(content, line, re, a.State, a.InsertAfter, a.InsertBefore)
+		},
+	}, nil
+}
+
+// editLine ensures line is present or absent in content. With a regexp, the last
+// matching line is replaced (present) or every matching line removed (absent);
+// without one, lines equal to line are used.
+func editLine(content, line string, re *regexp.Regexp, state, insertAfter, insertBefore string) (string, error) {
+	lines := splitLines(content)
+	matches := func(l string) bool {
+		if re != nil {
+			return re.MatchString(l)
+		}
+		return l == line
+	}
+
+	if state == "absent" {
+		kept := make([]string, 0, len(lines))
+		for _, l := range lines {
+			if !matches(l) {
+				kept = append(kept, l)
+			}
+		}
+		if len(kept) == len(lines) {
+			return content, nil
+		}
+		return joinLines(kept), nil
+	}
+
+	// Replace the last match in place
+	for i := len(lines) - 1; i >= 0; i-- {
+		if matches(lines[i]) {
+			if lines[i] == line {
+				return content, nil
+			}
+			lines[i] = line
+			return joinLines(lines), nil
+		}
+	}
+
+	// A regexp that no longer matches may still leave the exact line in place
+	for _, l := range lines {
+		if l == line {
+			return content, nil
+		}
+	}
+
+	index, err := insertIndex(lines, insertAfter, insertBefore)
+	if err != nil {
+		return "", err
+	}
+	return joinLines(insertLines(lines, index, line)), nil
+}
+
+// indentDiff indents multi-line dry-run details under the action summary
+func indentDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		lines[i] = "        " + line
+	}
+	return strings.Join(lines, "\n")
+}
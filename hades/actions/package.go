@@ -0,0 +1,365 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+type PackageAction struct {
+	Names       []string
+	State       string
+	UpdateCache bool
+	Repository  *schema.PackageRepository
+}
+
+func NewPackageAction(action *schema.ActionPackage) Action {
+	state := action.State
+	if state == "" {
+		state = "present"
+	}
+	return &PackageAction{
+		Names:       action.Names,
+		State:       state,
+		UpdateCache: action.UpdateCache,
+		Repository:  action.Repository,
+	}
+}
+
+// packageManager holds the commands used for one package manager
+type packageManager struct {
+	update  string
+	install string
+	upgrade string
+	remove  string
+	query   string
+}
+
+var packageManagers = map[string]packageManager{
+	"apt": {
+		update:  "apt-get update",
+		install: "DEBIAN_FRONTEND=noninteractive apt-get install -y",
+		upgrade: "DEBIAN_FRONTEND=noninteractive apt-get install -y --only-upgrade",
+		remove:  "DEBIAN_FRONTEND=noninteractive apt-get remove -y",
+		query:   `v=$(dpkg-query -W -f='${Status}|${Version}' "$p" 2>/dev/null); case "$v" in "install ok installed|"*) echo "$p ${v#*|}" ;; *) echo "$p" ;; esac`,
+	},
+	"dnf": {
+		update:  "dnf makecache",
+		install: "dnf install -y",
+		upgrade: "dnf upgrade -y",
+		remove:  "dnf remove -y",
+		query:   `v=$(rpm -q --qf '%{VERSION}-%{RELEASE}' "$p" 2>/dev/null) && echo "$p $v" || echo "$p"`,
+	},
+	"yum": {
+		update:  "yum makecache",
+		install: "yum install -y",
+		upgrade: "yum update -y",
+		remove:  "yum remove -y",
+		query:   `v=$(rpm -q --qf '%{VERSION}-%{RELEASE}' "$p" 2>/dev/null) && echo "$p $v" || echo "$p"`,
+	},
+	"apk": {
+		update:  "apk update",
+		install: "apk add",
+		upgrade: "apk add --upgrade",
+		remove:  "apk del",
+		query:   `echo "$p $(apk list -I "$p" 2>/dev/null | awk 'NR==1{print $1}')"`,
+	},
+}
+
+func (a *PackageAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	names, err := a.expandNames(runtime.Env)
+	if err != nil {
+		return err
+	}
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	manager, err := detectPackageManager(ctx, sess)
+	if err != nil {
+		return err
+	}
+	pm := packageManagers[manager]
+
+	repoChanged := false
+	if a.Repository != nil {
+		if manager != "apt" {
+			return fmt.Errorf("package repository is only supported with apt (host uses %s)", manager)
+		}
+		repoChanged, err = a.configureRepository(ctx, runtime, sess)
+		if err != nil {
+			return err
+		}
+	}
+
+	before, err := queryPackages(ctx, sess, pm, names)
+	if err != nil {
+		return err
+	}
+
+	cmds := packageCommands(pm, a.State, a.UpdateCache || repoChanged, names, before)
+	if len(cmds) == 0 && repoChanged {
+		// New sources should be visible to later actions even if nothing is installed here
+		cmds = []string{pm.update}
+	}
+	if len(cmds) == 0 {
+		reportSkipped(runtime, "packages "+strings.Join(names, ", "), "already "+a.State)
+		return nil
+	}
+
+	cmd := strings.Join(cmds, " && ")
+	fmt.Fprintf(runtime.Stdout, "Running: %s\n", cmd)
+	if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to %s packages: %w", a.verb(), err)
+	}
+
+	after, err := queryPackages(ctx, sess, pm, names)
+	if err != nil {
+		return err
+	}
+	changes := packageChanges(names, before, after)
+	if len(changes) == 0 {
+		fmt.Fprintf(runtime.Stdout, "Packages unchanged\n")
+	}
+	for _, change := range changes {
+		fmt.Fprintf(runtime.Stdout, "Changed: %s\n", change)
+	}
+
+	return nil
+}
+
+func (a *PackageAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	names, _ := a.expandNames(runtime.Env)
+
+	details := []string{a.State}
+	if a.UpdateCache {
+		details = append(details, "update cache")
+	}
+	if a.Repository != nil {
+		details = append(details, "repository "+a.Repository.Name)
+	}
+	return fmt.Sprintf("package: %s (%s)", strings.Join(names, ", "), strings.Join(details, ", "))
+}
+
+func (a *PackageAction) expandNames(env map[string]string) ([]string, error) {
+	names := make([]string, 0, len(a.Names))
+	for _, name := range a.Names {
+		expanded, err := expandEnv(name, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand package name: %w", err)
+		}
+		names = append(names, expanded)
+	}
+	return names, nil
+}
+
+func (a *PackageAction) verb() string {
+	switch a.State {
+	case "absent":
+		return "remove"
+	case "latest":
+		return "upgrade"
+	default:
+		return "install"
+	}
+}
+
+// configureRepository installs the signing key (when missing) and the apt source
+// list. It reports whether anything on the host changed.
+func (a *PackageAction) configureRepository(ctx context.Context, runtime *types.Runtime, sess ssh.Session) (bool, error) {
+	repo := a.Repository
+	changed := false
+
+	keyring := ""
+	if repo.Key != "" {
+		keyring = fmt.Sprintf("/usr/share/keyrings/%s-archive-keyring.gpg", repo.Name)
+		if repo.Keyring != "" {
+			expanded, err := expandEnv(repo.Keyring, runtime.Env)
+			if err != nil {
+				return false, fmt.Errorf("failed to expand keyring: %w", err)
+			}
+			keyring = expanded
+		}
+
+		var stdout bytes.Buffer
+		check := fmt.Sprintf("test -s %s && echo present || true", utils.ShellQuote(keyring))
+		if err := sess.Run(ctx, check, &stdout, io.Discard); err != nil {
+			return false, fmt.Errorf("failed to check keyring: %w", err)
+		}
+		if strings.TrimSpace(stdout.String()) != "present" {
+			gpg := NewGpgAction(&schema.ActionGpg{
+				Src:     repo.Key,
+				Path:    keyring,
+				Dearmor: repo.Dearmor,
+			})
+			if err := gpg.Execute(ctx, runtime); err != nil {
+				return false, fmt.Errorf("failed to install repository key: %w", err)
+			}
+			changed = true
+		}
+	}
+
+	source, err := expandEnv(repo.Source, runtime.Env)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand repository source: %w", err)
+	}
+	content := aptSourceList(source, keyring)
+	listPath := path.Join("/etc/apt/sources.list.d", repo.Name+".list")
+
+	var existing bytes.Buffer
+	cmd := fmt.Sprintf("cat %s 2>/dev/null || true", utils.ShellQuote(listPath))
+	if err := sess.Run(ctx, cmd, &existing, io.Discard); err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", listPath, err)
+	}
+	if existing.String() != content {
+		if err := sess.CopyFile(ctx, strings.NewReader(content), listPath, 0644); err != nil {
+			return false, fmt.Errorf("failed to write %s: %w", listPath, err)
+		}
+		fmt.Fprintf(runtime.Stdout, "Updated repository %s\n", listPath)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// detectPackageManager returns the first supported package manager found on the host
+func detectPackageManager(ctx context.Context, sess ssh.Session) (string, error) {
+	var stdout bytes.Buffer
+	cmd := "for pm in apt-get dnf yum apk; do if command -v $pm >/dev/null 2>&1; then echo $pm; break; fi; done"
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return "", fmt.Errorf("failed to detect package manager: %w", err)
+	}
+
+	switch manager := strings.TrimSpace(stdout.String()); manager {
+	case "apt-get":
+		return "apt", nil
+	case "dnf", "yum", "apk":
+		return manager, nil
+	default:
+		return "", fmt.Errorf("no supported package manager found (expected apt, dnf, yum or apk)")
+	}
+}
+
+// queryPackages returns the installed version of each package, empty if not installed
+func queryPackages(ctx context.Context, sess ssh.Session, pm packageManager, names []string) (map[string]string, error) {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("for p in %s; do %s; done", quoteAll(names), pm.query)
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return nil, fmt.Errorf("failed to query installed packages: %w", err)
+	}
+	return parseInstalledPackages(stdout.String()), nil
+}
+
+// parseInstalledPackages parses "name [version]" lines produced by the query command
+func parseInstalledPackages(output string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		version := ""
+		if len(fields) > 1 {
+			version = fields[1]
+		}
+		installed[fields[0]] = version
+	}
+	return installed
+}
+
+// packageCommands returns the commands needed to reach state from the installed
+// versions. An empty result means the packages are already in the desired state.
+func packageCommands(pm packageManager, state string, updateCache bool, names []string, installed map[string]string) []string {
+	var missing, present []string
+	for _, name := range names {
+		if installed[name] == "" {
+			missing = append(missing, name)
+		} else {
+			present = append(present, name)
+		}
+	}
+
+	var cmds []string
+	switch state {
+	case "absent":
+		if len(present) > 0 {
+			cmds = append(cmds, pm.remove+" "+quoteAll(present))
+		}
+		return cmds
+	case "latest":
+		if len(missing) > 0 {
+			cmds = append(cmds, pm.install+" "+quoteAll(missing))
+		}
+		if len(present) > 0 {
+			cmds = append(cmds, pm.upgrade+" "+quoteAll(present))
+		}
+	default:
+		if len(missing) > 0 {
+			cmds = append(cmds, pm.install+" "+quoteAll(missing))
+		}
+	}
+
+	if len(cmds) > 0 && updateCache {
+		cmds = append([]string{pm.update}, cmds...)
+	}
+	return cmds
+}
+
+// packageChanges describes packages whose installed version differs between before and after
+func packageChanges(names []string, before, after map[string]string) []string {
+	var changes []string
+	for _, name := range names {
+		from, to := before[name], after[name]
+		switch {
+		case from == to:
+			continue
+		case from == "":
+			changes = append(changes, fmt.Sprintf("%s installed (%s)", name, to))
+		case to == "":
+			changes = append(changes, fmt.Sprintf("%s removed (%s)", name, from))
+		default:
+			changes = append(changes, fmt.Sprintf("%s upgraded (%s -> %s)", name, from, to))
+		}
+	}
+	return changes
+}
+
+// aptSourceList renders source lines for a sources.list.d file, adding a
+// signed-by option to deb lines that do not set their own options
+func aptSourceList(source, keyring string) string {
+	var b strings.Builder
+	b.WriteString("# Managed by hades\n")
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if keyring != "" && (fields[0] == "deb" || fields[0] == "deb-src") && len(fields) > 1 && !strings.HasPrefix(fields[1], "[") {
+			line = fmt.Sprintf("%s [signed-by=%s] %s", fields[0], keyring, strings.Join(fields[1:], " "))
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = utils.ShellQuote(v)
+	}
+	return strings.Join(quoted, " ")
+}
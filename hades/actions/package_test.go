@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInstalledPackages(t *testing.T) {
+	output := "curl 7.88.1-10\nvim\n\ncaddy 2.7.6\n"
+
+	got := parseInstalledPackages(output)
+	expected := map[string]string{"curl": "7.88.1-10", "vim": "", "caddy": "2.7.6"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestPackageCommands(t *testing.T) {
+	apt := packageManagers["apt"]
+	installed := map[string]string{"curl": "7.88.1", "vim": ""}
+
+	tests := []struct {
+		name        string
+		state       string
+		updateCache bool
+		names       []string
+		expected    []string
+	}{
+		{
+			name:     "present with everything installed",
+			state:    "present",
+			names:    []string{"curl"},
+			expected: nil,
+		},
+		{
+			name:        "present skips cache update when nothing to install",
+			state:       "present",
+			updateCache: true,
+			names:       []string{"curl"},
+			expected:    nil,
+		},
+		{
+			name:        "present installs missing only",
+			state:       "present",
+			updateCache: true,
+			names:       []string{"curl", "vim"},
+			expected: []string{
+				"apt-get update",
+				"DEBIAN_FRONTEND=noninteractive apt-get install -y 'vim'",
+			},
+		},
+		{
+			name:  "latest installs and upgrades",
+			state: "latest",
+			names: []string{"curl", "vim"},
+			expected: []string{
+				"DEBIAN_FRONTEND=noninteractive apt-get install -y 'vim'",
+				"DEBIAN_FRONTEND=noninteractive apt-get install -y --only-upgrade 'curl'",
+			},
+		},
+		{
+			name:        "absent removes installed only",
+			state:       "absent",
+			updateCache: true,
+			names:       []string{"curl", "vim"},
+			expected:    []string{"DEBIAN_FRONTEND=noninteractive apt-get remove -y 'curl'"},
+		},
+		{
+			name:     "absent with nothing installed",
+			state:    "absent",
+			names:    []string{"vim"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := packageCommands(apt, tt.state, tt.updateCache, tt.names, installed)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPackageChanges(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	before := map[string]string{"a": "1.0", "b": "", "c": "2.0", "d": "3.0"}
+	after := map[string]string{"a": "1.1", "b": "0.9", "c": "", "d": "3.0"}
+
+	got := packageChanges(names, before, after)
+	expected := []string{
+		"a upgraded (1.0 -> 1.1)",
+		"b installed (0.9)",
+		"c removed (2.0)",
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestAptSourceList(t *testing.T) {
+	source := `
+deb https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main
+deb-src https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main
+deb [arch=amd64] https://example.com/debian stable main
+`
+
+	got := aptSourceList(source, "/usr/share/keyrings/caddy-stable-archive-keyring.gpg")
+	expected := "# Managed by hades\n" +
+		"deb [signed-by=/usr/share/keyrings/caddy-stable-archive-keyring.gpg] https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main\n" +
+		"deb-src [signed-by=/usr/share/keyrings/caddy-stable-archive-keyring.gpg] https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main\n" +
+		"deb [arch=amd64] https://example.com/debian stable main\n"
+
+	if got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
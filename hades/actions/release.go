@@ -0,0 +1,405 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+// ReleaseAction uploads content into <path>/releases/<id>, atomically points
+// <path>/current at it and prunes old releases
+type ReleaseAction struct {
+	Path            string
+	ID              string
+	Src             string
+	Artifact        string
+	Registry        string
+	Name            string
+	Tag             string
+	File            string
+	Extract         bool
+	Format          string
+	StripComponents int
+	Mode            uint32
+	Owner           string
+	Keep            int
+}
+
+func NewReleaseAction(action *schema.ActionRelease) Action {
+	id := action.ID
+	if id == "" {
+		id = "${HADES_RUN_ID}"
+	}
+	mode := action.Mode
+	if mode == 0 && !action.Extract {
+		mode = 0644 // Default mode for release files
+	}
+	return &ReleaseAction{
+		Path:            action.Path,
+		ID:              id,
+		Src:             action.Src,
+		Artifact:        action.Artifact,
+		Registry:        action.Registry,
+		Name:            action.Name,
+		Tag:             action.Tag,
+		File:            action.File,
+		Extract:         action.Extract,
+		Format:          action.Format,
+		StripComponents: action.StripComponents,
+		Mode:            mode,
+		Owner:           action.Owner,
+		Keep:            action.Keep,
+	}
+}
+
+func (a *ReleaseAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	base, err := expandEnv(a.Path, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand path: %w", err)
+	}
+
+	id, err := expandEnv(a.ID, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand id: %w", err)
+	}
+	if id == "" || strings.Contains(id, "/") || strings.HasPrefix(id, rolledBackPrefix) || id == "." || id == ".." {
+		return fmt.Errorf("invalid release id %q", id)
+	}
+
+	releaseDir := path.Join(base, "releases", id)
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	// Create the release directory
+	mkdirCmd := fmt.Sprintf("mkdir -p %s", utils.ShellQuote(releaseDir))
+	if err := sess.Run(ctx, mkdirCmd, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to create release directory: %w", err)
+	}
+
+	// Upload release content
+	if err := a.uploadContent(ctx, runtime, releaseDir); err != nil {
+		return err
+	}
+
+	// Atomically switch the current symlink
+	current, err := currentRelease(ctx, sess, base)
+	if err != nil {
+		return err
+	}
+	if current == id {
+		fmt.Fprintf(runtime.Stdout, "Release %s is already current\n", id)
+	} else {
+		if err := sess.Run(ctx, switchCommand(base, id), runtime.Stdout, runtime.Stderr); err != nil {
+			return fmt.Errorf("failed to switch current release: %w", err)
+		}
+		fmt.Fprintf(runtime.Stdout, "Switched %s/current to releases/%s\n", base, id)
+	}
+
+	// Record the release as the newest, then prune the oldest ones; the
+	// current release is the newest, so it is never pruned. Releasing an id
+	// again clears its rolled back mark.
+	order, rolledBack, err := listReleases(ctx, sess, base)
+	if err != nil {
+		return err
+	}
+	order = append(removeRelease(order, id), id)
+	delete(rolledBack, id)
+
+	var pruned []string
+	if a.Keep > 0 && len(order) > a.Keep {
+		pruned = order[:len(order)-a.Keep]
+		order = order[len(order)-a.Keep:]
+	}
+	if err := sess.Run(ctx, writeHistoryCommand(base, order, rolledBack), runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to record release history: %w", err)
+	}
+	if len(pruned) > 0 {
+		if err := sess.Run(ctx, pruneCommand(base, pruned), runtime.Stdout, runtime.Stderr); err != nil {
+			return fmt.Errorf("failed to prune old releases: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *ReleaseAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	base := ExpandEnvVars(a.Path, runtime.Env)
+	id := ExpandEnvVars(a.ID, runtime.Env)
+
+	var src string
+	switch {
+	case a.Artifact != "":
+		src = fmt.Sprintf("artifact=%s", a.Artifact)
+	case a.Registry != "":
+		src = fmt.Sprintf("%s/%s:%s", ExpandEnvVars(a.Registry, runtime.Env), ExpandEnvVars(a.Name, runtime.Env), ExpandEnvVars(a.Tag, runtime.Env))
+	default:
+		src = a.Src
+	}
+
+	desc := fmt.Sprintf("release: %s to %s/releases/%s, switch %s/current", src, base, id, base)
+	if a.Extract {
+		desc += " (extract)"
+	}
+	if a.Keep > 0 {
+		desc += fmt.Sprintf(", keep %d", a.Keep)
+	}
+	return desc
+}
+
+// uploadContent places the release content in releaseDir, reusing the
+// unarchive, copy and pull actions
+func (a *ReleaseAction) uploadContent(ctx context.Context, runtime *types.Runtime, releaseDir string) error {
+	if a.Extract {
+		unarchive := &UnarchiveAction{
+			Src:             a.Src,
+			Artifact:        a.Artifact,
+			Registry:        a.Registry,
+			Name:            a.Name,
+			Tag:             a.Tag,
+			Dst:             releaseDir,
+			Format:          a.Format,
+			StripComponents: a.StripComponents,
+			Owner:           a.Owner,
+			Mode:            a.Mode,
+		}
+		return unarchive.Execute(ctx, runtime)
+	}
+
+	file := a.File
+	if file == "" {
+		if a.Registry != "" {
+			file = path.Base(ExpandEnvVars(a.Name, runtime.Env))
+		} else {
+			file = path.Base(a.Src)
+		}
+	}
+	dst := path.Join(releaseDir, file)
+
+	if a.Registry != "" {
+		pull := &PullAction{Registry: a.Registry, Name: a.Name, Tag: a.Tag, To: dst}
+		if err := pull.Execute(ctx, runtime); err != nil {
+			return err
+		}
+	} else {
+		copyAction := &CopyAction{Src: a.Src, Artifact: a.Artifact, Dst: dst, Mode: a.Mode}
+		if err := copyAction.Execute(ctx, runtime); err != nil {
+			return err
+		}
+	}
+
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	cmd := fmt.Sprintf("chmod %o %s", a.Mode, utils.ShellQuote(dst))
+	if a.Owner != "" {
+		cmd += fmt.Sprintf(" && chown -R %s %s", utils.ShellQuote(a.Owner), utils.ShellQuote(releaseDir))
+	}
+	if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to set release permissions: %w", err)
+	}
+	return nil
+}
+
+// RollbackRelease points <base>/current at the release that preceded the
+// current one and returns the previous and new release ids. The release rolled
+// back from is marked in the history, so that a later rollback does not return
+// to it and pruning removes it first.
+func RollbackRelease(ctx context.Context, runtime *types.Runtime, base string) (string, string, error) {
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	current, err := currentRelease(ctx, sess, base)
+	if err != nil {
+		return "", "", err
+	}
+	if current == "" {
+		return "", "", fmt.Errorf("%s/current does not point to a release", base)
+	}
+
+	order, rolledBack, err := listReleases(ctx, sess, base)
+	if err != nil {
+		return "", "", err
+	}
+	previous := previousRelease(order, rolledBack, current)
+	if previous == "" {
+		return "", "", fmt.Errorf("no release older than %s in %s/releases", current, base)
+	}
+
+	if err := sess.Run(ctx, switchCommand(base, previous), runtime.Stdout, runtime.Stderr); err != nil {
+		return "", "", fmt.Errorf("failed to switch current release: %w", err)
+	}
+	fmt.Fprintf(runtime.Stdout, "Rolled back %s/current from releases/%s to releases/%s\n", base, current, previous)
+
+	rolledBack[current] = true
+	if err := sess.Run(ctx, writeHistoryCommand(base, order, rolledBack), runtime.Stdout, runtime.Stderr); err != nil {
+		return "", "", fmt.Errorf("failed to record release history: %w", err)
+	}
+
+	return current, previous, nil
+}
+
+// currentRelease returns the release id that <base>/current points to, or ""
+func currentRelease(ctx context.Context, sess ssh.Session, base string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("readlink %s || true", utils.ShellQuote(path.Join(base, "current")))
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return "", fmt.Errorf("failed to read current release: %w", err)
+	}
+	target := strings.TrimSpace(stdout.String())
+	if target == "" {
+		return "", nil
+	}
+	return path.Base(target), nil
+}
+
+// releaseHistory is the file in the release base path listing release ids in
+// the order they were released, oldest first. Directory mtimes are not used,
+// as any write inside an old release would reorder it.
+const releaseHistory = ".release-history"
+
+// rolledBackPrefix marks a release in the history that was rolled back from
+const rolledBackPrefix = "!"
+
+// listReleases returns the existing releases oldest first and those that were
+// rolled back from
+func listReleases(ctx context.Context, sess ssh.Session, base string) ([]string, map[string]bool, error) {
+	var dirs, history bytes.Buffer
+	listCmd := fmt.Sprintf("ls -1 %s 2>/dev/null || true", utils.ShellQuote(path.Join(base, "releases")))
+	if err := sess.Run(ctx, listCmd, &dirs, io.Discard); err != nil {
+		return nil, nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	historyCmd := fmt.Sprintf("cat %s 2>/dev/null || true", utils.ShellQuote(path.Join(base, releaseHistory)))
+	if err := sess.Run(ctx, historyCmd, &history, io.Discard); err != nil {
+		return nil, nil, fmt.Errorf("failed to read release history: %w", err)
+	}
+	order, rolledBack := releaseOrder(strings.Split(dirs.String(), "\n"), strings.Split(history.String(), "\n"))
+	return order, rolledBack, nil
+}
+
+// releaseOrder orders the existing release directories oldest first:
+// directories missing from the history (released before it was kept, or by
+// hand) come first in name order, then releases rolled back from, then the
+// history order. History entries without a directory are dropped.
+func releaseOrder(existing, history []string) ([]string, map[string]bool) {
+	exists := make(map[string]bool)
+	var names []string
+	for _, name := range existing {
+		name = strings.TrimSpace(name)
+		if name == "" || exists[name] {
+			continue
+		}
+		exists[name] = true
+		names = append(names, name)
+	}
+
+	var tracked []string
+	rolledBack := make(map[string]bool)
+	for _, entry := range history {
+		id, marked := strings.CutPrefix(strings.TrimSpace(entry), rolledBackPrefix)
+		if exists[id] {
+			tracked = append(removeRelease(tracked, id), id)
+			rolledBack[id] = marked
+		}
+	}
+
+	inHistory := make(map[string]bool, len(tracked))
+	for _, id := range tracked {
+		inHistory[id] = true
+	}
+	var order []string
+	sort.Strings(names)
+	for _, name := range names {
+		if !inHistory[name] {
+			order = append(order, name)
+		}
+	}
+	var live []string
+	for _, id := range tracked {
+		if rolledBack[id] {
+			order = append(order, id)
+		} else {
+			live = append(live, id)
+			delete(rolledBack, id)
+		}
+	}
+	return append(order, live...), rolledBack
+}
+
+// removeRelease returns order without id
+func removeRelease(order []string, id string) []string {
+	result := make([]string, 0, len(order))
+	for _, r := range order {
+		if r != id {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// previousRelease returns the release before current in an oldest-first
+// order, skipping releases that were rolled back from
+func previousRelease(order []string, rolledBack map[string]bool, current string) string {
+	for i, r := range order {
+		if r != current {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if !rolledBack[order[j]] {
+				return order[j]
+			}
+		}
+	}
+	return ""
+}
+
+// switchCommand builds a command that atomically replaces <base>/current with
+// a relative symlink to releases/<id>
+func switchCommand(base, id string) string {
+	tmpLink := utils.ShellQuote(path.Join(base, ".current.tmp"))
+	return fmt.Sprintf("ln -sfn %s %s && mv -Tf %s %s",
+		utils.ShellQuote(path.Join("releases", id)), tmpLink, tmpLink, utils.ShellQuote(path.Join(base, "current")))
+}
+
+// writeHistoryCommand builds a command that atomically replaces the release
+// history with order, marking the releases rolled back from
+func writeHistoryCommand(base string, order []string, rolledBack map[string]bool) string {
+	quoted := make([]string, len(order))
+	for i, id := range order {
+		if rolledBack[id] {
+			id = rolledBackPrefix + id
+		}
+		quoted[i] = utils.ShellQuote(id)
+	}
+	history := path.Join(base, releaseHistory)
+	tmp := utils.ShellQuote(history + ".tmp")
+	return fmt.Sprintf("printf '%%s\\n' %s > %s && mv -f %s %s", strings.Join(quoted, " "), tmp, tmp, utils.ShellQuote(history))
+}
+
+// pruneCommand builds a command that removes the given releases
+func pruneCommand(base string, releases []string) string {
+	cmds := make([]string, len(releases))
+	for i, id := range releases {
+		cmds[i] = fmt.Sprintf("echo %s && rm -rf -- %s", utils.ShellQuote("Pruning release "+id), utils.ShellQuote(path.Join(base, "releases", id)))
+	}
+	return strings.Join(cmds, " && ")
+}
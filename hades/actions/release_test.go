@@ -0,0 +1,235 @@
+package actions
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/artifacts"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+func TestPreviousRelease(t *testing.T) {
+	releases := []string{"r1", "r2", "r3", "r4"}
+	rolledBack := map[string]bool{"r3": true}
+
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{current: "r4", want: "r2"},
+		{current: "r2", want: "r1"},
+		{current: "r1", want: ""},
+		{current: "missing", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.current, func(t *testing.T) {
+			if got := previousRelease(releases, rolledBack, tt.current); got != tt.want {
+				t.Errorf("previousRelease(%q) = %q, want %q", tt.current, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseOrder(t *testing.T) {
+	// v10 was released last, v9 was redeployed after v2; legacy predates the
+	// history and gone was removed by hand
+	existing := []string{"v2", "v10", "v9", "legacy", ""}
+	history := []string{"v9", "gone", "v2", "v9", "v10", ""}
+
+	got, rolledBack := releaseOrder(existing, history)
+	want := []string{"legacy", "v2", "v9", "v10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("releaseOrder() = %v, want %v", got, want)
+	}
+	if len(rolledBack) != 0 {
+		t.Errorf("Expected no rolled back releases, got %v", rolledBack)
+	}
+
+	// Releases rolled back from come before the others; releasing one
+	// again clears the mark
+	history = []string{"v2", "!v9", "v10", "!v2", "!legacy", "legacy"}
+	got, rolledBack = releaseOrder(existing, history)
+	want = []string{"v9", "v2", "v10", "legacy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("releaseOrder() = %v, want %v", got, want)
+	}
+	if wantRolledBack := map[string]bool{"v9": true, "v2": true}; !reflect.DeepEqual(rolledBack, wantRolledBack) {
+		t.Errorf("Expected rolled back %v, got %v", wantRolledBack, rolledBack)
+	}
+}
+
+func TestHistoryCommands(t *testing.T) {
+	got := writeHistoryCommand("/opt/app", []string{"r1", "r2"}, map[string]bool{"r1": true})
+	want := `printf '%s\n' '!r1' 'r2' > '/opt/app/.release-history.tmp' && mv -f '/opt/app/.release-history.tmp' '/opt/app/.release-history'`
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	got = pruneCommand("/opt/app", []string{"r1"})
+	want = `echo 'Pruning release r1' && rm -rf -- '/opt/app/releases/r1'`
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestSwitchCommand(t *testing.T) {
+	got := switchCommand("/opt/app", "r2")
+	expected := "ln -sfn 'releases/r2' '/opt/app/.current.tmp' && mv -Tf '/opt/app/.current.tmp' '/opt/app/current'"
+
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestReleaseAction_DryRun(t *testing.T) {
+	action := NewReleaseAction(&schema.ActionRelease{
+		Path:     "/opt/app",
+		Artifact: "bin",
+		File:     "app",
+		Keep:     5,
+	})
+
+	runtime := &types.Runtime{
+		Env: map[string]string{"HADES_RUN_ID": "hades-20260101-120000"},
+	}
+
+	result := action.DryRun(context.Background(), runtime)
+	expected := "release: artifact=bin to /opt/app/releases/hades-20260101-120000, switch /opt/app/current, keep 5"
+
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestReleaseAction_ArtifactExtract(t *testing.T) {
+	artifactMgr := artifacts.NewManager()
+	if err := artifactMgr.Store("bundle", strings.NewReader("archive")); err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+	client := &sessionClient{session: &mockSession{
+		runFunc: func(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+			commands = append(commands, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "mktemp "):
+				io.WriteString(stdout, "/tmp/hades-unarchive.Xr4nd0m\n")
+			case strings.HasPrefix(cmd, "ls -1 "):
+				io.WriteString(stdout, "r1\nr2\n")
+			case strings.HasPrefix(cmd, "cat "):
+				io.WriteString(stdout, "r2\nr1\n")
+			}
+			return nil
+		},
+	}}
+
+	action := NewReleaseAction(&schema.ActionRelease{
+		Path:     "/opt/app",
+		ID:       "r3",
+		Artifact: "bundle",
+		Extract:  true,
+		Format:   "tar.gz",
+		Keep:     2,
+	})
+	runtime := &types.Runtime{
+		SSHClient:   client,
+		ArtifactMgr: artifactMgr,
+		Env:         map[string]string{},
+		Stdout:      io.Discard,
+		Stderr:      io.Discard,
+	}
+	if err := action.Execute(context.Background(), runtime); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	all := strings.Join(commands, "\n")
+	for _, want := range []string{
+		"tar -xzf",
+		`printf '%s\n' 'r1' 'r3' > '/opt/app/.release-history.tmp'`,
+		`rm -rf -- '/opt/app/releases/r2'`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("Expected a command containing %q, got:\n%s", want, all)
+		}
+	}
+}
+
+// releaseHost keeps the release state of a host for the commands the release
+// action and rollback run
+type releaseHost struct {
+	current  string
+	dirs     []string
+	history  []string
+	commands []string
+}
+
+func (h *releaseHost) run(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	h.commands = append(h.commands, cmd)
+	switch {
+	case strings.HasPrefix(cmd, "mkdir -p "):
+		h.dirs = append(h.dirs, path.Base(strings.Trim(strings.Fields(cmd)[2], "'")))
+	case strings.HasPrefix(cmd, "readlink "):
+		io.WriteString(stdout, "releases/"+h.current+"\n")
+	case strings.HasPrefix(cmd, "ls -1 "):
+		io.WriteString(stdout, strings.Join(h.dirs, "\n")+"\n")
+	case strings.HasPrefix(cmd, "cat "):
+		io.WriteString(stdout, strings.Join(h.history, "\n")+"\n")
+	case strings.HasPrefix(cmd, "ln -sfn "):
+		h.current = path.Base(strings.Trim(strings.Fields(cmd)[2], "'"))
+	case strings.HasPrefix(cmd, "printf "):
+		h.history = nil
+		for _, id := range strings.Fields(cmd[:strings.Index(cmd, " > ")])[2:] {
+			h.history = append(h.history, strings.Trim(id, "'"))
+		}
+	}
+	return nil
+}
+
+func TestRollbackRelease_Consecutive(t *testing.T) {
+	host := &releaseHost{current: "r3", dirs: []string{"r1", "r2", "r3"}, history: []string{"r1", "r2", "r3"}}
+	runtime := &types.Runtime{
+		SSHClient: &sessionClient{session: &mockSession{runFunc: host.run}},
+		Env:       map[string]string{},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+
+	// Each rollback moves one release further back, never forward again
+	for _, want := range [][2]string{{"r3", "r2"}, {"r2", "r1"}} {
+		from, to, err := RollbackRelease(context.Background(), runtime, "/opt/app")
+		if err != nil {
+			t.Fatalf("RollbackRelease: %v", err)
+		}
+		if from != want[0] || to != want[1] {
+			t.Fatalf("Expected rollback from %s to %s, got %s to %s", want[0], want[1], from, to)
+		}
+	}
+	if _, _, err := RollbackRelease(context.Background(), runtime, "/opt/app"); err == nil || !strings.Contains(err.Error(), "no release older than r1") {
+		t.Fatalf("Expected no release to roll back to, got %v", err)
+	}
+
+	// The next release prunes the releases rolled back from before the live one
+	src := filepath.Join(t.TempDir(), "app.jar")
+	if err := os.WriteFile(src, []byte("app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	action := NewReleaseAction(&schema.ActionRelease{Path: "/opt/app", ID: "r4", Src: src, Keep: 2})
+	if err := action.Execute(context.Background(), runtime); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"r1", "r4"}; !reflect.DeepEqual(host.history, want) {
+		t.Errorf("Expected history %v, got %v", want, host.history)
+	}
+	last := host.commands[len(host.commands)-1]
+	if !strings.Contains(last, "releases/r3'") || !strings.Contains(last, "releases/r2'") || strings.Contains(last, "releases/r1'") {
+		t.Errorf("Expected r3 and r2 to be pruned, got %q", last)
+	}
+}
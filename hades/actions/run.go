@@ -27,7 +27,13 @@ func (a *RunAction) Execute(ctx context.Context, runtime *types.Runtime) error {
 	defer sess.Close()
 
 	// Expand environment variables in the command
-	cmd := ExpandEnvVars(a.Command, runtime.Env)
+	cmd, err := expandEnv(a.Command, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand command: %w", err)
+	}
+
+	// Export the runtime environment to the remote process
+	sess.SetEnv(runtime.Env)
 
 	// Execute command - use runtime's stdout/stderr to ensure output goes to logs
 	if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
@@ -0,0 +1,117 @@
+package actions
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+// mockClient is a test double for ssh.Client that records executed commands
+type mockClient struct {
+	commands []string
+	env      map[string]string
+}
+
+func (c *mockClient) Connect(ctx context.Context, host ssh.Host) (ssh.Session, error) {
+	return &recordingSession{client: c}, nil
+}
+
+func (c *mockClient) Forward(ctx context.Context, host ssh.Host, remoteAddr string, localPort int) (*ssh.Forward, error) {
+	return nil, nil
+}
+
+func (c *mockClient) Close() error {
+	return nil
+}
+
+type recordingSession struct {
+	mockSession
+	client *mockClient
+}
+
+func (s *recordingSession) Run(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	s.client.commands = append(s.client.commands, cmd)
+	return nil
+}
+
+func (s *recordingSession) SetEnv(env map[string]string) {
+	s.client.env = env
+}
+
+func TestRunAction_ExportsEnv(t *testing.T) {
+	client := &mockClient{}
+	runtime := &types.Runtime{
+		SSHClient: client,
+		Env: map[string]string{
+			"HADES_RUN_ID": "hades-1",
+			"VERSION":      "1.2.3",
+		},
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+	}
+
+	run := schema.ActionRun("echo ${VERSION}")
+	action := NewRunAction(&run)
+	if err := action.Execute(context.Background(), runtime); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(client.commands) != 1 || client.commands[0] != "echo 1.2.3" {
+		t.Errorf("Expected command %q, got %v", "echo 1.2.3", client.commands)
+	}
+	if client.env["HADES_RUN_ID"] != "hades-1" {
+		t.Errorf("Expected HADES_RUN_ID to be exported, got %v", client.env)
+	}
+}
+
+func TestRunAction_UnknownVariable(t *testing.T) {
+	client := &mockClient{}
+	runtime := &types.Runtime{
+		SSHClient: client,
+		Env:       map[string]string{},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+
+	run := schema.ActionRun("echo ${MISSING}")
+	action := NewRunAction(&run)
+	err := action.Execute(context.Background(), runtime)
+	if err == nil {
+		t.Fatal("Expected error for unknown variable")
+	}
+	if !strings.Contains(err.Error(), "MISSING") {
+		t.Errorf("Expected error to mention MISSING, got %v", err)
+	}
+	if len(client.commands) != 0 {
+		t.Errorf("Expected no commands to run, got %v", client.commands)
+	}
+}
+
+func TestRunAction_EscapedVariable(t *testing.T) {
+	client := &mockClient{}
+	runtime := &types.Runtime{
+		SSHClient: client,
+		Env:       map[string]string{"VERSION": "1.2.3"},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+
+	run := schema.ActionRun("cp app-${VERSION} $${HOME}/bin")
+	action := NewRunAction(&run)
+	if err := action.Execute(context.Background(), runtime); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "cp app-1.2.3 ${HOME}/bin"
+	if len(client.commands) != 1 || client.commands[0] != want {
+		t.Errorf("Expected command %q, got %v", want, client.commands)
+	}
+	if got := action.DryRun(context.Background(), runtime); got != "run: "+want {
+		t.Errorf("Expected dry run %q, got %q", "run: "+want, got)
+	}
+}
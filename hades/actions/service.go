@@ -0,0 +1,160 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+type ServiceAction struct {
+	Name         string
+	State        string
+	Enabled      *bool
+	DaemonReload bool
+}
+
+func NewServiceAction(action *schema.ActionService) Action {
+	return &ServiceAction{
+		Name:         action.Name,
+		State:        action.State,
+		Enabled:      action.Enabled,
+		DaemonReload: action.DaemonReload,
+	}
+}
+
+func (a *ServiceAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	name, err := expandEnv(a.Name, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand name: %w", err)
+	}
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	// Reload unit files first so the state checks see the current definition
+	if a.DaemonReload {
+		if err := sess.Run(ctx, "systemctl daemon-reload", runtime.Stdout, runtime.Stderr); err != nil {
+			return fmt.Errorf("failed to reload systemd: %w", err)
+		}
+	}
+
+	active, enabled, err := serviceStatus(ctx, sess, name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(runtime.Stdout, "Service %s: active=%s enabled=%s\n", name, active, enabled)
+
+	cmds := serviceCommands(name, a.State, a.Enabled, active, enabled)
+	if len(cmds) == 0 {
+		if a.DaemonReload && a.State == "" && a.Enabled == nil {
+			return nil
+		}
+		reportSkipped(runtime, "service "+name, "already "+a.describe())
+		return nil
+	}
+
+	cmd := strings.Join(cmds, " && ")
+	fmt.Fprintf(runtime.Stdout, "Changing service %s: %s\n", name, cmd)
+	if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to change service %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (a *ServiceAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	name, _ := expandEnv(a.Name, runtime.Env)
+
+	if a.DaemonReload {
+		return fmt.Sprintf("service: %s (daemon-reload, %s)", name, a.describe())
+	}
+	return fmt.Sprintf("service: %s (%s)", name, a.describe())
+}
+
+// describe summarizes the desired state, e.g. "started, enabled"
+func (a *ServiceAction) describe() string {
+	var parts []string
+	if a.State != "" {
+		parts = append(parts, a.State)
+	}
+	if a.Enabled != nil {
+		if *a.Enabled {
+			parts = append(parts, "enabled")
+		} else {
+			parts = append(parts, "disabled")
+		}
+	}
+	if len(parts) == 0 {
+		return "no state change"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// serviceStatus returns the output of systemctl is-active and is-enabled for name
+func serviceStatus(ctx context.Context, sess ssh.Session, name string) (string, string, error) {
+	var stdout bytes.Buffer
+	unit := utils.ShellQuote(name)
+	cmd := fmt.Sprintf("echo \"$(systemctl is-active %s)\"; echo \"$(systemctl is-enabled %s 2>/dev/null)\"", unit, unit)
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return "", "", fmt.Errorf("failed to query service %s: %w", name, err)
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+	active := strings.TrimSpace(lines[0])
+	enabled := ""
+	if len(lines) > 1 {
+		enabled = strings.TrimSpace(lines[1])
+	}
+	return active, enabled, nil
+}
+
+// serviceCommands returns the systemctl commands needed to move a unit from its
+// current active/enabled state to the desired one. An empty result means no change.
+func serviceCommands(name, state string, enabled *bool, active, enabledState string) []string {
+	unit := utils.ShellQuote(name)
+	running := active == "active" || active == "activating" || active == "reloading"
+
+	var cmds []string
+	if enabled != nil {
+		isEnabled := enabledState == "enabled" || enabledState == "enabled-runtime"
+		if *enabled && !isEnabled && enabledState != "static" && enabledState != "alias" {
+			cmds = append(cmds, "systemctl enable "+unit)
+		}
+		if !*enabled && isEnabled {
+			cmds = append(cmds, "systemctl disable "+unit)
+		}
+	}
+
+	switch state {
+	case "started":
+		if !running {
+			cmds = append(cmds, "systemctl start "+unit)
+		}
+	case "stopped":
+		if running {
+			cmds = append(cmds, "systemctl stop "+unit)
+		}
+	case "restarted":
+		cmds = append(cmds, "systemctl restart "+unit)
+	case "reloaded":
+		// A stopped unit cannot be reloaded, starting it picks up the new config
+		if running {
+			cmds = append(cmds, "systemctl reload "+unit)
+		} else {
+			cmds = append(cmds, "systemctl start "+unit)
+		}
+	}
+
+	return cmds
+}
@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServiceCommands(t *testing.T) {
+	yes := true
+	no := false
+
+	tests := []struct {
+		name         string
+		state        string
+		enabled      *bool
+		active       string
+		enabledState string
+		expected     []string
+	}{
+		{
+			name:         "already started and enabled",
+			state:        "started",
+			enabled:      &yes,
+			active:       "active",
+			enabledState: "enabled",
+			expected:     nil,
+		},
+		{
+			name:         "start and enable",
+			state:        "started",
+			enabled:      &yes,
+			active:       "inactive",
+			enabledState: "disabled",
+			expected:     []string{"systemctl enable 'caddy'", "systemctl start 'caddy'"},
+		},
+		{
+			name:         "stop running unit",
+			state:        "stopped",
+			active:       "active",
+			enabledState: "enabled",
+			expected:     []string{"systemctl stop 'caddy'"},
+		},
+		{
+			name:         "already stopped",
+			state:        "stopped",
+			active:       "failed",
+			enabledState: "enabled",
+			expected:     nil,
+		},
+		{
+			name:         "restart always runs",
+			state:        "restarted",
+			active:       "active",
+			enabledState: "enabled",
+			expected:     []string{"systemctl restart 'caddy'"},
+		},
+		{
+			name:         "reload running unit",
+			state:        "reloaded",
+			active:       "active",
+			enabledState: "enabled",
+			expected:     []string{"systemctl reload 'caddy'"},
+		},
+		{
+			name:         "reload starts stopped unit",
+			state:        "reloaded",
+			active:       "inactive",
+			enabledState: "enabled",
+			expected:     []string{"systemctl start 'caddy'"},
+		},
+		{
+			name:         "disable only",
+			enabled:      &no,
+			active:       "active",
+			enabledState: "enabled",
+			expected:     []string{"systemctl disable 'caddy'"},
+		},
+		{
+			name:         "static unit cannot be enabled",
+			enabled:      &yes,
+			active:       "active",
+			enabledState: "static",
+			expected:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := serviceCommands("caddy", tt.state, tt.enabled, tt.active, tt.enabledState)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
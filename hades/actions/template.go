@@ -3,6 +3,8 @@ package actions
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -57,6 +59,7 @@ func (a *TemplateAction) Execute(ctx context.Context, runtime *types.Runtime) er
 	// Build template context
 	data := map[string]interface{}{
 		"Env":    runtime.Env,
+		"Vars":   runtime.Host.Vars,
 		"Host":   runtime.Host.Name,
 		"Target": runtime.Target,
 	}
@@ -84,6 +87,13 @@ func (a *TemplateAction) Execute(ctx context.Context, runtime *types.Runtime) er
 	}
 	defer sess.Close()
 
+	// Skip the write if the remote file already has the rendered content
+	sum := sha256.Sum256(buf.Bytes())
+	if remoteChecksum, exists, err := getRemoteChecksum(ctx, sess, dst); err == nil && exists && remoteChecksum == hex.EncodeToString(sum[:]) {
+		reportSkipped(runtime, dst, "already up to date")
+		return nil
+	}
+
 	// Copy rendered template to remote host
 	if err := sess.CopyFile(ctx, &buf, dst, 0644); err != nil {
 		return fmt.Errorf("failed to copy rendered template to %s: %w", dst, err)
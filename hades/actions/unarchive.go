@@ -0,0 +1,214 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+type UnarchiveAction struct {
+	Src             string
+	Artifact        string
+	Registry        string
+	Name            string
+	Tag             string
+	Dst             string
+	Format          string
+	StripComponents int
+	Owner           string
+	Mode            uint32
+}
+
+func NewUnarchiveAction(action *schema.ActionUnarchive) Action {
+	return &UnarchiveAction{
+		Src:             action.Src,
+		Artifact:        action.Artifact,
+		Registry:        action.Registry,
+		Name:            action.Name,
+		Tag:             action.Tag,
+		Dst:             action.Dst,
+		Format:          action.Format,
+		StripComponents: action.StripComponents,
+		Owner:           action.Owner,
+		Mode:            action.Mode,
+	}
+}
+
+func (a *UnarchiveAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	dst, err := expandEnv(a.Dst, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand dst: %w", err)
+	}
+
+	// Read the archive into memory
+	data, srcDesc, err := a.readArchive(ctx, runtime)
+	if err != nil {
+		return err
+	}
+
+	format, err := a.format(srcDesc)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	// Skip if this exact archive was already extracted into dst
+	if getArchiveMarker(ctx, sess, dst) == checksum {
+		reportSkipped(runtime, dst, "already extracted")
+		return nil
+	}
+
+	// Upload the archive to a temporary location
+	tmpPath, err := remoteTempFile(ctx, sess, "hades-unarchive")
+	if err != nil {
+		return err
+	}
+	if err := sess.CopyFile(ctx, bytes.NewReader(data), tmpPath, 0600); err != nil {
+		removeRemoteFile(ctx, sess, tmpPath)
+		return fmt.Errorf("failed to copy %s to host: %w", srcDesc, err)
+	}
+
+	extractCmd, err := extractCommand(format, tmpPath, dst, a.StripComponents)
+	if err != nil {
+		removeRemoteFile(ctx, sess, tmpPath)
+		return err
+	}
+
+	cmds := []string{extractCmd, writeMarkerCommand(dst, checksum)}
+	if a.Mode != 0 {
+		cmds = append(cmds, fmt.Sprintf("chmod %o %s", a.Mode, utils.ShellQuote(dst)))
+	}
+	if a.Owner != "" {
+		cmds = append(cmds, fmt.Sprintf("chown -R %s %s", utils.ShellQuote(a.Owner), utils.ShellQuote(dst)))
+	}
+
+	// Always remove the uploaded archive, even if extraction fails
+	if err := sess.Run(ctx, cleanupCommand(cmds, tmpPath), runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to extract %s to %s: %w", srcDesc, dst, err)
+	}
+
+	fmt.Fprintf(runtime.Stdout, "Extracted %s to %s (%s, %s)\n", srcDesc, dst, format, formatFileSize(int64(len(data))))
+	return nil
+}
+
+func (a *UnarchiveAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	dst, _ := expandEnv(a.Dst, runtime.Env)
+	srcDesc := a.describeSource(runtime)
+
+	format, err := a.format(srcDesc)
+	if err != nil {
+		format = "unknown format"
+	}
+
+	if a.StripComponents > 0 {
+		return fmt.Sprintf("unarchive: %s to %s (%s, strip %d, skip if unchanged)", srcDesc, dst, format, a.StripComponents)
+	}
+	return fmt.Sprintf("unarchive: %s to %s (%s, skip if unchanged)", srcDesc, dst, format)
+}
+
+// readArchive loads the archive from a local file, artifact or registry
+func (a *UnarchiveAction) readArchive(ctx context.Context, runtime *types.Runtime) ([]byte, string, error) {
+	srcDesc := a.describeSource(runtime)
+
+	var reader io.ReadCloser
+	switch {
+	case a.Artifact != "":
+		art, err := runtime.ArtifactMgr.Get(a.Artifact)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get artifact %s: %w", a.Artifact, err)
+		}
+		reader = art
+
+	case a.Registry != "":
+		registry, err := expandEnv(a.Registry, runtime.Env)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to expand registry: %w", err)
+		}
+		name, err := expandEnv(a.Name, runtime.Env)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to expand name: %w", err)
+		}
+		tag, err := expandEnv(a.Tag, runtime.Env)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to expand tag: %w", err)
+		}
+		reg, err := runtime.RegistryMgr.GetRegistry(registry)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get registry: %w", err)
+		}
+		art, err := reg.Pull(ctx, name, tag)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to pull from registry: %w", err)
+		}
+		reader = art
+
+	case a.Src != "":
+		src, err := expandEnv(a.Src, runtime.Env)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to expand src: %w", err)
+		}
+		resolvedSrc := runtime.ResolvePath(src)
+		f, err := os.Open(resolvedSrc)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open archive %s: %w", resolvedSrc, err)
+		}
+		reader = f
+
+	default:
+		return nil, "", fmt.Errorf("one of src, artifact or registry must be specified")
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read archive %s: %w", srcDesc, err)
+	}
+	return data, srcDesc, nil
+}
+
+// describeSource returns a human-readable archive source
+func (a *UnarchiveAction) describeSource(runtime *types.Runtime) string {
+	switch {
+	case a.Artifact != "":
+		return fmt.Sprintf("artifact:%s", a.Artifact)
+	case a.Registry != "":
+		registry := ExpandEnvVars(a.Registry, runtime.Env)
+		name := ExpandEnvVars(a.Name, runtime.Env)
+		tag := ExpandEnvVars(a.Tag, runtime.Env)
+		return fmt.Sprintf("%s/%s:%s", registry, name, tag)
+	default:
+		return ExpandEnvVars(a.Src, runtime.Env)
+	}
+}
+
+// format returns the configured format or detects it from the source name
+func (a *UnarchiveAction) format(srcDesc string) (string, error) {
+	if a.Format != "" {
+		return a.Format, nil
+	}
+	name := srcDesc
+	if a.Registry != "" {
+		name = a.Name
+	}
+	if format, ok := utils.DetectArchiveFormat(name); ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("cannot detect archive format of %s, set 'format'", srcDesc)
+}
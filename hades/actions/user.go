@@ -0,0 +1,260 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+type UserAction struct {
+	Name   string
+	State  string
+	Shell  string
+	Home   string
+	Groups []string
+	System bool
+}
+
+func NewUserAction(action *schema.ActionUser) Action {
+	state := action.State
+	if state == "" {
+		state = "present"
+	}
+	return &UserAction{
+		Name:   action.Name,
+		State:  state,
+		Shell:  action.Shell,
+		Home:   action.Home,
+		Groups: action.Groups,
+		System: action.System,
+	}
+}
+
+// userEntry holds the fields of a passwd entry that the user action manages
+type userEntry struct {
+	home  string
+	shell string
+}
+
+// userSpec is the desired state of a user after environment expansion
+type userSpec struct {
+	name   string
+	shell  string
+	home   string
+	groups []string
+	system bool
+}
+
+func (a *UserAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	spec, err := a.expand(runtime.Env)
+	if err != nil {
+		return err
+	}
+
+	// Create SSH session
+	sess, err := runtime.SSHClient.Connect(ctx, runtime.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host: %w", err)
+	}
+	defer sess.Close()
+
+	current, err := lookupUser(ctx, sess, spec.name)
+	if err != nil {
+		return err
+	}
+
+	var cmds []string
+	if a.State == "absent" {
+		if current != nil {
+			cmds = []string{"userdel " + utils.ShellQuote(spec.name)}
+		}
+	} else {
+		var memberOf []string
+		if current != nil {
+			memberOf, err = userGroups(ctx, sess, spec.name)
+			if err != nil {
+				return err
+			}
+		}
+		existingGroups, err := existingGroups(ctx, sess, spec.groups)
+		if err != nil {
+			return err
+		}
+		cmds = userCommands(spec, current, memberOf, existingGroups)
+	}
+
+	if len(cmds) == 0 {
+		reportSkipped(runtime, "user "+spec.name, "already "+a.State)
+		return nil
+	}
+
+	cmd := strings.Join(cmds, " && ")
+	fmt.Fprintf(runtime.Stdout, "Changing user %s: %s\n", spec.name, cmd)
+	if err := sess.Run(ctx, cmd, runtime.Stdout, runtime.Stderr); err != nil {
+		return fmt.Errorf("failed to change user %s: %w", spec.name, err)
+	}
+
+	return nil
+}
+
+func (a *UserAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	spec, _ := a.expand(runtime.Env)
+
+	if a.State == "absent" {
+		return fmt.Sprintf("user: %s (absent)", spec.name)
+	}
+
+	details := []string{"present"}
+	if spec.system {
+		details = append(details, "system")
+	}
+	if spec.shell != "" {
+		details = append(details, "shell "+spec.shell)
+	}
+	if spec.home != "" {
+		details = append(details, "home "+spec.home)
+	}
+	if len(spec.groups) > 0 {
+		details = append(details, "groups "+strings.Join(spec.groups, ","))
+	}
+	return fmt.Sprintf("user: %s (%s)", spec.name, strings.Join(details, ", "))
+}
+
+func (a *UserAction) expand(env map[string]string) (userSpec, error) {
+	spec := userSpec{system: a.System}
+	var err error
+
+	if spec.name, err = expandEnv(a.Name, env); err != nil {
+		return spec, fmt.Errorf("failed to expand name: %w", err)
+	}
+	if spec.shell, err = expandEnv(a.Shell, env); err != nil {
+		return spec, fmt.Errorf("failed to expand shell: %w", err)
+	}
+	if spec.home, err = expandEnv(a.Home, env); err != nil {
+		return spec, fmt.Errorf("failed to expand home: %w", err)
+	}
+	for _, group := range a.Groups {
+		expanded, err := expandEnv(group, env)
+		if err != nil {
+			return spec, fmt.Errorf("failed to expand group: %w", err)
+		}
+		spec.groups = append(spec.groups, expanded)
+	}
+	return spec, nil
+}
+
+// lookupUser returns the passwd entry for name, or nil if the user does not exist
+func lookupUser(ctx context.Context, sess ssh.Session, name string) (*userEntry, error) {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("getent passwd %s || true", utils.ShellQuote(name))
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return nil, fmt.Errorf("failed to look up user %s: %w", name, err)
+	}
+	return parsePasswdEntry(stdout.String()), nil
+}
+
+// parsePasswdEntry parses a getent passwd line (name:x:uid:gid:gecos:home:shell)
+func parsePasswdEntry(line string) *userEntry {
+	fields := strings.Split(strings.TrimSpace(line), ":")
+	if len(fields) < 7 {
+		return nil
+	}
+	return &userEntry{home: fields[5], shell: fields[6]}
+}
+
+// userGroups returns the names of all groups the user belongs to
+func userGroups(ctx context.Context, sess ssh.Session, name string) ([]string, error) {
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("id -nG %s", utils.ShellQuote(name))
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return nil, fmt.Errorf("failed to read groups of %s: %w", name, err)
+	}
+	return strings.Fields(stdout.String()), nil
+}
+
+// existingGroups returns which of groups already exist on the host
+func existingGroups(ctx context.Context, sess ssh.Session, groups []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(groups) == 0 {
+		return existing, nil
+	}
+
+	var stdout bytes.Buffer
+	cmd := fmt.Sprintf("for g in %s; do getent group \"$g\" >/dev/null && echo \"$g\"; done; true", quoteAll(groups))
+	if err := sess.Run(ctx, cmd, &stdout, io.Discard); err != nil {
+		return nil, fmt.Errorf("failed to look up groups: %w", err)
+	}
+	for _, group := range strings.Fields(stdout.String()) {
+		existing[group] = true
+	}
+	return existing, nil
+}
+
+// userCommands returns the commands needed to create the user or bring an
+// existing one in line with spec. Groups are only ever added, never removed.
+func userCommands(spec userSpec, current *userEntry, memberOf []string, existingGroups map[string]bool) []string {
+	var cmds []string
+	for _, group := range spec.groups {
+		if existingGroups[group] {
+			continue
+		}
+		if spec.system {
+			cmds = append(cmds, "groupadd -r "+utils.ShellQuote(group))
+		} else {
+			cmds = append(cmds, "groupadd "+utils.ShellQuote(group))
+		}
+	}
+
+	name := utils.ShellQuote(spec.name)
+
+	if current == nil {
+		args := []string{"useradd"}
+		if spec.system {
+			args = append(args, "-r")
+		}
+		if !spec.system || spec.home != "" {
+			args = append(args, "-m")
+		}
+		if spec.home != "" {
+			args = append(args, "-d", utils.ShellQuote(spec.home))
+		}
+		if spec.shell != "" {
+			args = append(args, "-s", utils.ShellQuote(spec.shell))
+		}
+		if len(spec.groups) > 0 {
+			args = append(args, "-G", utils.ShellQuote(strings.Join(spec.groups, ",")))
+		}
+		args = append(args, name)
+		return append(cmds, strings.Join(args, " "))
+	}
+
+	if spec.shell != "" && spec.shell != current.shell {
+		cmds = append(cmds, fmt.Sprintf("usermod -s %s %s", utils.ShellQuote(spec.shell), name))
+	}
+	if spec.home != "" && spec.home != current.home {
+		cmds = append(cmds, fmt.Sprintf("usermod -d %s -m %s", utils.ShellQuote(spec.home), name))
+	}
+
+	member := make(map[string]bool, len(memberOf))
+	for _, group := range memberOf {
+		member[group] = true
+	}
+	var missing []string
+	for _, group := range spec.groups {
+		if !member[group] {
+			missing = append(missing, group)
+		}
+	}
+	if len(missing) > 0 {
+		cmds = append(cmds, fmt.Sprintf("usermod -aG %s %s", utils.ShellQuote(strings.Join(missing, ",")), name))
+	}
+
+	return cmds
+}
@@ -0,0 +1,66 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePasswdEntry(t *testing.T) {
+	entry := parsePasswdEntry("deploy:x:1001:1001:,,,:/home/deploy:/bin/bash\n")
+	expected := &userEntry{home: "/home/deploy", shell: "/bin/bash"}
+
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, entry)
+	}
+	if parsePasswdEntry("") != nil {
+		t.Errorf("Expected nil entry for missing user")
+	}
+}
+
+func TestUserCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     userSpec
+		current  *userEntry
+		memberOf []string
+		groups   map[string]bool
+		expected []string
+	}{
+		{
+			name:     "create user",
+			spec:     userSpec{name: "deploy", shell: "/bin/bash", groups: []string{"sudo", "docker"}},
+			groups:   map[string]bool{"sudo": true},
+			expected: []string{"groupadd 'docker'", "useradd -m -s '/bin/bash' -G 'sudo,docker' 'deploy'"},
+		},
+		{
+			name:     "create system user without home",
+			spec:     userSpec{name: "app", system: true, shell: "/usr/sbin/nologin"},
+			expected: []string{"useradd -r -s '/usr/sbin/nologin' 'app'"},
+		},
+		{
+			name:     "existing user unchanged",
+			spec:     userSpec{name: "deploy", shell: "/bin/bash", groups: []string{"sudo"}},
+			current:  &userEntry{home: "/home/deploy", shell: "/bin/bash"},
+			memberOf: []string{"deploy", "sudo"},
+			groups:   map[string]bool{"sudo": true},
+			expected: nil,
+		},
+		{
+			name:     "update shell and add group",
+			spec:     userSpec{name: "deploy", shell: "/bin/zsh", groups: []string{"sudo", "docker"}},
+			current:  &userEntry{home: "/home/deploy", shell: "/bin/bash"},
+			memberOf: []string{"deploy", "sudo"},
+			groups:   map[string]bool{"sudo": true, "docker": true},
+			expected: []string{"usermod -s '/bin/zsh' 'deploy'", "usermod -aG 'docker' 'deploy'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := userCommands(tt.spec, tt.current, tt.memberOf, tt.groups)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,13 +1,12 @@
 package actions
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strings"
 	"time"
 
+	"github.com/SoftKiwiGames/hades/hades/approval"
 	"github.com/SoftKiwiGames/hades/hades/schema"
 	"github.com/SoftKiwiGames/hades/hades/types"
 )
@@ -31,55 +30,33 @@ func (a *WaitAction) Execute(ctx context.Context, runtime *types.Runtime) error
 	}
 
 	// Parse timeout if provided
-	var timeout time.Duration
-	var err error
 	if a.Timeout != "" {
-		timeout, err = time.ParseDuration(a.Timeout)
+		timeout, err := time.ParseDuration(a.Timeout)
 		if err != nil {
 			return fmt.Errorf("invalid timeout format: %w", err)
 		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	// Create a channel for the user response
-	responseChan := make(chan bool, 1)
-
-	// Start goroutine to read user input
-	go func() {
-		fmt.Fprintf(runtime.Stdout, "\n⏸️  %s [y/N]: ", message)
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
-		if err != nil {
-			responseChan <- false
-			return
-		}
-		response = strings.TrimSpace(strings.ToLower(response))
-		responseChan <- (response == "y" || response == "yes")
-	}()
-
-	// Wait for response or timeout
-	if timeout > 0 {
-		select {
-		case approved := <-responseChan:
-			if !approved {
-				return fmt.Errorf("user declined to continue")
-			}
-		case <-time.After(timeout):
-			return fmt.Errorf("wait timed out after %s", timeout)
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-	} else {
-		select {
-		case approved := <-responseChan:
-			if !approved {
-				return fmt.Errorf("user declined to continue")
-			}
-		case <-ctx.Done():
-			return ctx.Err()
-		}
+	if runtime.Approver == nil {
+		return fmt.Errorf("no approval source configured")
 	}
 
-	return nil
+	// Hosts of the same batch share the gate, so the operator is asked once
+	err := runtime.Approver.Approve(ctx, approval.Request{Key: runtime.ActionDesc, Message: message})
+	switch {
+	case err == nil:
+		fmt.Fprintf(runtime.Stdout, "Approved: %s\n", message)
+		return nil
+	case errors.Is(err, approval.ErrDeclined):
+		return fmt.Errorf("user declined to continue")
+	case errors.Is(err, context.DeadlineExceeded) && a.Timeout != "":
+		return fmt.Errorf("wait timed out after %s", a.Timeout)
+	default:
+		return err
+	}
 }
 
 func (a *WaitAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
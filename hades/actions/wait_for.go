@@ -0,0 +1,362 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+const (
+	defaultWaitForTimeout  = 60 * time.Second
+	defaultWaitForInterval = 2 * time.Second
+	waitForProbeTimeout    = 5 * time.Second
+)
+
+type WaitForAction struct {
+	TCP      string
+	HTTP     string
+	Status   int
+	Body     string
+	File     string
+	Command  string
+	Timeout  string
+	Interval string
+	From     string
+}
+
+func NewWaitForAction(action *schema.ActionWaitFor) Action {
+	from := action.From
+	if from == "" {
+		from = "host"
+	}
+	return &WaitForAction{
+		TCP:      action.TCP,
+		HTTP:     action.HTTP,
+		Status:   action.Status,
+		Body:     action.Body,
+		File:     action.File,
+		Command:  action.Command,
+		Timeout:  action.Timeout,
+		Interval: action.Interval,
+		From:     from,
+	}
+}
+
+// probeFunc checks the condition once and returns nil when it is met
+type probeFunc func(ctx context.Context) error
+
+func (a *WaitForAction) Execute(ctx context.Context, runtime *types.Runtime) error {
+	timeout, err := parseWaitDuration(a.Timeout, defaultWaitForTimeout, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	interval, err := parseWaitDuration(a.Interval, defaultWaitForInterval, runtime.Env)
+	if err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
+	}
+
+	target, err := expandEnv(a.target(), runtime.Env)
+	if err != nil {
+		return fmt.Errorf("failed to expand %s: %w", a.kind(), err)
+	}
+
+	var probe probeFunc
+	if a.From == "controller" {
+		probe, err = a.controllerProbe(runtime, target)
+	} else {
+		sess, connErr := runtime.SSHClient.Connect(ctx, runtime.Host)
+		if connErr != nil {
+			return fmt.Errorf("failed to connect to host: %w", connErr)
+		}
+		defer sess.Close()
+		probe, err = a.hostProbe(runtime, sess, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(runtime.Stdout, "Waiting for %s %s from %s (timeout %s, interval %s)\n", a.kind(), target, a.From, timeout, interval)
+	return pollUntil(ctx, runtime.Stdout, probe, timeout, interval)
+}
+
+func (a *WaitForAction) DryRun(ctx context.Context, runtime *types.Runtime) string {
+	target, _ := expandEnv(a.target(), runtime.Env)
+
+	timeout := a.Timeout
+	if timeout == "" {
+		timeout = defaultWaitForTimeout.String()
+	}
+	return fmt.Sprintf("wait_for: %s %s from %s (timeout: %s)", a.kind(), target, a.From, timeout)
+}
+
+func (a *WaitForAction) kind() string {
+	switch {
+	case a.TCP != "":
+		return "tcp"
+	case a.HTTP != "":
+		return "http"
+	case a.File != "":
+		return "file"
+	default:
+		return "command"
+	}
+}
+
+func (a *WaitForAction) target() string {
+	switch {
+	case a.TCP != "":
+		return a.TCP
+	case a.HTTP != "":
+		return a.HTTP
+	case a.File != "":
+		return a.File
+	default:
+		return a.Command
+	}
+}
+
+func (a *WaitForAction) bodyPattern() (*regexp.Regexp, error) {
+	if a.Body == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(a.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body regexp %q: %w", a.Body, err)
+	}
+	return re, nil
+}
+
+// controllerProbe builds a probe that runs on the machine running hades
+func (a *WaitForAction) controllerProbe(runtime *types.Runtime, target string) (probeFunc, error) {
+	switch a.kind() {
+	case "tcp":
+		addr, err := parseTCPAddress(target)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			dialer := net.Dialer{Timeout: waitForProbeTimeout}
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}, nil
+
+	case "http":
+		body, err := a.bodyPattern()
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{Timeout: waitForProbeTimeout}
+		return func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			if err != nil {
+				return err
+			}
+			return checkHTTPResponse(resp.StatusCode, string(data), a.Status, body)
+		}, nil
+
+	case "file":
+		path := runtime.ResolvePath(target)
+		return func(ctx context.Context) error {
+			_, err := os.Stat(path)
+			return err
+		}, nil
+
+	default:
+		return func(ctx context.Context) error {
+			cmd := exec.CommandContext(ctx, "sh", "-c", target)
+			cmd.Dir = runtime.SourceDir
+			cmd.Env = append(os.Environ(), runtime.EnvSlice()...)
+			cmd.Stdout = runtime.Stdout
+			cmd.Stderr = runtime.Stderr
+			return cmd.Run()
+		}, nil
+	}
+}
+
+// hostProbe builds a probe that runs on the target host over the session
+func (a *WaitForAction) hostProbe(runtime *types.Runtime, sess ssh.Session, target string) (probeFunc, error) {
+	switch a.kind() {
+	case "tcp":
+		addr, err := parseTCPAddress(target)
+		if err != nil {
+			return nil, err
+		}
+		cmd := tcpProbeCommand(addr)
+		return func(ctx context.Context) error {
+			return sess.Run(ctx, cmd, io.Discard, io.Discard)
+		}, nil
+
+	case "http":
+		body, err := a.bodyPattern()
+		if err != nil {
+			return nil, err
+		}
+		cmd := fmt.Sprintf("curl -sS --max-time %d -o - -w '\\n%%{http_code}' %s",
+			int(waitForProbeTimeout.Seconds()), utils.ShellQuote(target))
+		return func(ctx context.Context) error {
+			var stdout, stderr bytes.Buffer
+			if err := sess.Run(ctx, cmd, &stdout, &stderr); err != nil {
+				return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			status, content, err := splitCurlOutput(stdout.String())
+			if err != nil {
+				return err
+			}
+			return checkHTTPResponse(status, content, a.Status, body)
+		}, nil
+
+	case "file":
+		cmd := fmt.Sprintf("test -e %s", utils.ShellQuote(target))
+		return func(ctx context.Context) error {
+			return sess.Run(ctx, cmd, io.Discard, io.Discard)
+		}, nil
+
+	default:
+		sess.SetEnv(runtime.Env)
+		return func(ctx context.Context) error {
+			return sess.Run(ctx, commandProbe(ctx, target), runtime.Stdout, runtime.Stderr)
+		}, nil
+	}
+}
+
+// commandProbe wraps a remote probe command in timeout so that a hanging
+// command is killed on the host once the wait deadline passes
+func commandProbe(ctx context.Context, command string) string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return command
+	}
+	seconds := int(math.Ceil(time.Until(deadline).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return fmt.Sprintf("timeout %d sh -c %s", seconds, utils.ShellQuote(command))
+}
+
+// pollUntil runs probe every interval until it succeeds, the timeout expires or
+// the context is cancelled
+func pollUntil(ctx context.Context, log io.Writer, probe probeFunc, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	attempt := 0
+	for {
+		attempt++
+		err := probe(ctx)
+		if err == nil {
+			fmt.Fprintf(log, "Condition met after %d attempt(s)\n", attempt)
+			return nil
+		}
+		fmt.Fprintf(log, "Attempt %d: %v\n", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("condition not met after %s (last error: %v)", timeout, err)
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// parseWaitDuration parses an optional duration field, falling back to def
+func parseWaitDuration(value string, def time.Duration, env map[string]string) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	expanded, err := expandEnv(value, env)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(expanded)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive: %s", expanded)
+	}
+	return d, nil
+}
+
+// parseTCPAddress accepts "host:port" or "tcp://host:port"
+func parseTCPAddress(target string) (string, error) {
+	addr := strings.TrimPrefix(target, "tcp://")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid tcp address %q: %w", target, err)
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", fmt.Errorf("invalid tcp port in %q", target)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// tcpProbeCommand checks a TCP port from the host with nc, falling back to bash /dev/tcp
+func tcpProbeCommand(addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	devTCP := fmt.Sprintf("exec 3<>/dev/tcp/%s/%s", host, port)
+	return fmt.Sprintf("if command -v nc >/dev/null 2>&1; then nc -z -w %d %s %s; else timeout %d bash -c %s; fi",
+		int(waitForProbeTimeout.Seconds()), utils.ShellQuote(host), port,
+		int(waitForProbeTimeout.Seconds()), utils.ShellQuote(devTCP))
+}
+
+// splitCurlOutput separates the response body from the status code that curl
+// appends on its own line
+func splitCurlOutput(output string) (int, string, error) {
+	i := strings.LastIndex(output, "\n")
+	if i < 0 {
+		return 0, "", fmt.Errorf("unexpected curl output %q", output)
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(output[i+1:]))
+	if err != nil {
+		return 0, "", fmt.Errorf("unexpected curl status %q", output[i+1:])
+	}
+	return status, output[:i], nil
+}
+
+// checkHTTPResponse succeeds for the expected status (any 2xx by default) and,
+// when set, a body matching the pattern
+func checkHTTPResponse(status int, body string, wantStatus int, pattern *regexp.Regexp) error {
+	if wantStatus != 0 {
+		if status != wantStatus {
+			return fmt.Errorf("HTTP %d (want %d)", status, wantStatus)
+		}
+	} else if status < 200 || status > 299 {
+		return fmt.Errorf("HTTP %d (want 2xx)", status)
+	}
+	if pattern != nil && !pattern.MatchString(body) {
+		return fmt.Errorf("HTTP %d: body does not match %q", status, pattern.String())
+	}
+	return nil
+}
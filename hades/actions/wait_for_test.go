@@ -0,0 +1,178 @@
+package actions
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+)
+
+func TestParseTCPAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "tcp://localhost:8080", expected: "localhost:8080"},
+		{input: "10.0.0.5:5432", expected: "10.0.0.5:5432"},
+		{input: ":80", expected: "localhost:80"},
+		{input: "localhost", wantErr: true},
+		{input: "localhost:http", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTCPAddress(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSplitCurlOutput(t *testing.T) {
+	status, body, err := splitCurlOutput("{\"ok\":true}\n200")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status != 200 || body != "{\"ok\":true}" {
+		t.Errorf("Expected 200 and body, got %d %q", status, body)
+	}
+}
+
+func TestCheckHTTPResponse(t *testing.T) {
+	ready := regexp.MustCompile(`"status":\s*"ready"`)
+
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		pattern    *regexp.Regexp
+		wantErr    bool
+	}{
+		{name: "2xx by default", status: 204},
+		{name: "5xx fails", status: 503, wantErr: true},
+		{name: "exact status", status: 401, wantStatus: 401},
+		{name: "body matches", status: 200, body: `{"status": "ready"}`, pattern: ready},
+		{name: "body does not match", status: 200, body: `{"status": "starting"}`, pattern: ready, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkHTTPResponse(tt.status, tt.body, tt.wantStatus, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWaitForAction_ControllerHTTP(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Become ready on the third request
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready"))
+	}))
+	defer server.Close()
+
+	action := NewWaitForAction(&schema.ActionWaitFor{
+		HTTP:     server.URL,
+		Body:     "ready",
+		Interval: "10ms",
+		Timeout:  "5s",
+		From:     "controller",
+	})
+
+	runtime := &types.Runtime{Env: map[string]string{}, Stdout: io.Discard, Stderr: io.Discard}
+	if err := action.Execute(context.Background(), runtime); err != nil {
+		t.Fatalf("Expected wait to succeed, got: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected 3 requests, got %d", got)
+	}
+}
+
+func TestWaitForAction_ControllerTCPTimeout(t *testing.T) {
+	// Reserve a port and close it so nothing is listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	action := NewWaitForAction(&schema.ActionWaitFor{
+		TCP:      "tcp://" + addr,
+		Interval: "10ms",
+		Timeout:  "100ms",
+		From:     "controller",
+	})
+
+	runtime := &types.Runtime{Env: map[string]string{}, Stdout: io.Discard, Stderr: io.Discard}
+	start := time.Now()
+	if err := action.Execute(context.Background(), runtime); err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected wait to stop near the timeout, took %s", elapsed)
+	}
+}
+
+func TestWaitForAction_HostCommandHangs(t *testing.T) {
+	// Run probes locally, ignoring the context like a remote command would
+	var commands []string
+	sess := &mockSession{
+		runFunc: func(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+			commands = append(commands, cmd)
+			return exec.Command("sh", "-c", cmd).Run()
+		},
+	}
+
+	action := NewWaitForAction(&schema.ActionWaitFor{
+		Command:  "sleep 30",
+		Interval: "10ms",
+		Timeout:  "1s",
+	})
+
+	runtime := &types.Runtime{
+		SSHClient: &sessionClient{session: sess},
+		Env:       map[string]string{},
+		Stdout:    io.Discard,
+		Stderr:    io.Discard,
+	}
+	start := time.Now()
+	err := action.Execute(context.Background(), runtime)
+	if err == nil || !strings.Contains(err.Error(), "condition not met after 1s") {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the hanging probe to be killed near the timeout, took %s", elapsed)
+	}
+	if len(commands) == 0 || !strings.HasPrefix(commands[0], "timeout 1 sh -c 'sleep 30'") {
+		t.Errorf("Expected the probe to run under timeout, got %v", commands)
+	}
+}
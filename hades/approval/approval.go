@@ -0,0 +1,107 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/wzshiming/ctc"
+)
+
+// ErrDeclined is returned when an approval request is answered with "no"
+var ErrDeclined = errors.New("declined")
+
+// Request describes a single approval gate
+type Request struct {
+	Key     string // Identifies the gate; requests with the same key share one answer
+	Message string // Question shown to the operator
+}
+
+// Approver answers approval requests. Implementations must be safe for
+// concurrent use; parallel hosts may hit the same gate at once.
+type Approver interface {
+	Approve(ctx context.Context, req Request) error
+}
+
+type autoApprover struct {
+	out io.Writer
+	mu  sync.Mutex
+}
+
+// NewAuto returns an approver that approves every request (--yes)
+func NewAuto(out io.Writer) Approver {
+	return &autoApprover{out: out}
+}
+
+func (a *autoApprover) Approve(ctx context.Context, req Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(a.out, "%s?%s %s %sapproved (--yes)%s\n", ctc.ForegroundYellow, ctc.Reset, req.Message, ctc.ForegroundGreen, ctc.Reset)
+	return nil
+}
+
+type nonInteractiveApprover struct{}
+
+// NewNonInteractive returns an approver that fails every request instead of
+// waiting for input that will never come (--non-interactive, CI)
+func NewNonInteractive() Approver {
+	return nonInteractiveApprover{}
+}
+
+func (nonInteractiveApprover) Approve(ctx context.Context, req Request) error {
+	return fmt.Errorf("approval required (%s) but running non-interactively; pass --yes, --approve-file or --approve-socket", req.Message)
+}
+
+// Gate wraps an approver so that each key is asked only once within a scope,
+// such as a batch of hosts. Concurrent callers for the same key wait for the
+// first caller's answer.
+type Gate struct {
+	approver Approver
+	scope    string
+
+	mu        sync.Mutex
+	decisions map[string]*decision
+}
+
+type decision struct {
+	done chan struct{}
+	err  error
+}
+
+// NewGate creates a gate that prefixes messages with scope
+func NewGate(approver Approver, scope string) *Gate {
+	return &Gate{
+		approver:  approver,
+		scope:     scope,
+		decisions: make(map[string]*decision),
+	}
+}
+
+func (g *Gate) Approve(ctx context.Context, req Request) error {
+	g.mu.Lock()
+	d, ok := g.decisions[req.Key]
+	if !ok {
+		d = &decision{done: make(chan struct{})}
+		g.decisions[req.Key] = d
+	}
+	g.mu.Unlock()
+
+	if !ok {
+		scoped := req
+		if g.scope != "" {
+			scoped.Message = fmt.Sprintf("%s: %s", g.scope, req.Message)
+		}
+		d.err = g.approver.Approve(ctx, scoped)
+		close(d.done)
+		return d.err
+	}
+
+	select {
+	case <-d.done:
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
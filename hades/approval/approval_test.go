@@ -0,0 +1,230 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingApprover struct {
+	calls atomic.Int32
+	err   error
+	delay time.Duration
+}
+
+func (c *countingApprover) Approve(ctx context.Context, req Request) error {
+	c.calls.Add(1)
+	time.Sleep(c.delay)
+	return c.err
+}
+
+func TestGate_AsksOncePerKey(t *testing.T) {
+	inner := &countingApprover{delay: 20 * time.Millisecond}
+	gate := NewGate(inner, "Step \"deploy\"")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := gate.Approve(context.Background(), Request{Key: "[1] wait", Message: "Continue?"}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := inner.calls.Load(); got != 1 {
+		t.Errorf("Expected 1 prompt for concurrent hosts, got %d", got)
+	}
+
+	// A different gate in the same scope is asked separately
+	gate.Approve(context.Background(), Request{Key: "[3] wait", Message: "Continue?"})
+	if got := inner.calls.Load(); got != 2 {
+		t.Errorf("Expected 2 prompts, got %d", got)
+	}
+}
+
+func TestGate_SharesDecline(t *testing.T) {
+	gate := NewGate(&countingApprover{err: ErrDeclined}, "")
+
+	for i := 0; i < 2; i++ {
+		if err := gate.Approve(context.Background(), Request{Key: "k"}); !errors.Is(err, ErrDeclined) {
+			t.Errorf("Expected ErrDeclined, got %v", err)
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	approver := NewTerminal(strings.NewReader("yes\nno\n"), io.Discard)
+
+	if err := approver.Approve(context.Background(), Request{Message: "first"}); err != nil {
+		t.Errorf("Expected approval, got %v", err)
+	}
+	if err := approver.Approve(context.Background(), Request{Message: "second"}); !errors.Is(err, ErrDeclined) {
+		t.Errorf("Expected ErrDeclined, got %v", err)
+	}
+	if err := approver.Approve(context.Background(), Request{Message: "third"}); err == nil {
+		t.Error("Expected error after stdin is exhausted")
+	}
+}
+
+func TestNonInteractive(t *testing.T) {
+	if err := NewNonInteractive().Approve(context.Background(), Request{Message: "Continue?"}); err == nil {
+		t.Error("Expected non-interactive approver to fail")
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approve")
+	approver := NewFile(path, io.Discard)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- approver.Approve(context.Background(), Request{Message: "Continue?"})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := AnswerFile(path, true); err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected approval, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Approval was not picked up")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected approval file to be consumed")
+	}
+}
+
+func TestFile_EarlyAnswer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approve")
+	approver := NewFile(path, io.Discard)
+
+	// Answered before the gate is reached
+	if err := AnswerFile(path, false); err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := approver.Approve(ctx, Request{Message: "Continue?"}); !errors.Is(err, ErrDeclined) {
+		t.Errorf("Expected early answer to decline, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected approval file to be consumed")
+	}
+}
+
+func TestFile_StaleAnswer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approve")
+	if err := AnswerFile(path, true); err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to age approval file: %v", err)
+	}
+
+	approver := NewFile(path, io.Discard)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := approver.Approve(ctx, Request{Message: "Continue?"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected stale answer to be ignored, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected stale approval file to be removed")
+	}
+}
+
+func TestFile_EmptyAnswer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approve")
+	approver := NewFile(path, io.Discard)
+
+	// An empty file is an answer still being written, not an approval
+	if err := os.WriteFile(path, []byte(" \n"), 0600); err != nil {
+		t.Fatalf("Failed to create approval file: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- approver.Approve(context.Background(), Request{Message: "Continue?"})
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Expected empty file to be ignored, got %v", err)
+	case <-time.After(2 * filePollInterval):
+	}
+
+	if err := AnswerFile(path, false); err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrDeclined) {
+			t.Errorf("Expected decline, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Answer was not picked up")
+	}
+
+	// Nothing but the answer is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files left, got %v", entries)
+	}
+}
+
+func TestFile_Timeout(t *testing.T) {
+	approver := NewFile(filepath.Join(t.TempDir(), "approve"), io.Discard)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := approver.Approve(ctx, Request{Message: "Continue?"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approve.sock")
+	approver, err := NewSocket(path, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to create socket approver: %v", err)
+	}
+	defer approver.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- approver.Approve(context.Background(), Request{Message: "Canary healthy?"})
+	}()
+
+	message, err := AnswerSocket(path, false)
+	if err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	if message != "Canary healthy?" {
+		t.Errorf("Expected pending message, got %q", message)
+	}
+
+	if err := <-done; !errors.Is(err, ErrDeclined) {
+		t.Errorf("Expected ErrDeclined, got %v", err)
+	}
+}
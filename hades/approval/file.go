@@ -0,0 +1,128 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/ctc"
+)
+
+const filePollInterval = 500 * time.Millisecond
+
+type fileApprover struct {
+	path  string
+	out   io.Writer
+	since time.Time // Answers written before this are left over from an earlier run
+	mu    sync.Mutex
+}
+
+// NewFile returns an approver that waits for path to be written by another
+// process. "yes" approves, "no" declines; an empty file is not an answer yet.
+// Each answer is consumed by the next gate, so it may be written before the
+// gate is reached.
+func NewFile(path string, out io.Writer) Approver {
+	// File timestamps are coarser than time.Now, allow some slack
+	return &fileApprover{path: path, out: out, since: time.Now().Add(-time.Second)}
+}
+
+func (f *fileApprover) Approve(ctx context.Context, req Request) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(f.out, "\n%s?%s %s (waiting for approval: hades approve --file %s)\n", ctc.ForegroundYellow, ctc.Reset, req.Message, f.path)
+
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		answer, ok, err := f.read()
+		if err != nil {
+			return err
+		}
+		if ok {
+			if isYes(answer) {
+				return nil
+			}
+			if isNo(answer) {
+				return ErrDeclined
+			}
+			fmt.Fprintf(f.out, "Ignoring unexpected answer %q in %s\n", strings.TrimSpace(answer), f.path)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// read consumes the answer file if it exists, was written during this run and
+// is not empty. An empty file may be an answer still being written, so it is
+// left in place for the next poll.
+func (f *fileApprover) read() (string, bool, error) {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read approval file: %w", err)
+	}
+
+	if info.ModTime().Before(f.since) {
+		fmt.Fprintf(f.out, "Ignoring answer in %s left over from an earlier run\n", f.path)
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", false, fmt.Errorf("failed to clear approval file: %w", err)
+		}
+		return "", false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read approval file: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", false, nil
+	}
+	os.Remove(f.path)
+	return string(data), true, nil
+}
+
+// AnswerFile records an answer for a run waiting on an approval file. The
+// answer is written to a temporary file and renamed into place, so a waiting
+// run never sees it half written.
+func AnswerFile(path string, approve bool) error {
+	answer := "no\n"
+	if approve {
+		answer = "yes\n"
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write approval file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(answer); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write approval file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write approval file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write approval file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package approval
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/ctc"
+)
+
+const socketAcceptTimeout = 500 * time.Millisecond
+
+// SocketApprover serves pending approval requests on a unix socket. Each
+// connection is sent one "APPROVE <message>" line and answers with "yes" or "no".
+type SocketApprover struct {
+	path     string
+	out      io.Writer
+	listener *net.UnixListener
+	mu       sync.Mutex
+}
+
+// NewSocket listens on path, replacing a stale socket left by an earlier run
+func NewSocket(path string, out io.Writer) (*SocketApprover, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	return &SocketApprover{path: path, out: out, listener: listener}, nil
+}
+
+func (s *SocketApprover) Approve(ctx context.Context, req Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(s.out, "\n%s?%s %s (waiting for approval: hades approve --socket %s)\n", ctc.ForegroundYellow, ctc.Reset, req.Message, s.path)
+
+	for {
+		// Short accept deadlines keep the loop responsive to cancellation
+		s.listener.SetDeadline(time.Now().Add(socketAcceptTimeout))
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return fmt.Errorf("approval socket failed: %w", err)
+		}
+
+		answer, err := askConnection(ctx, conn, req.Message)
+		if err != nil {
+			fmt.Fprintf(s.out, "Approval connection failed: %v\n", err)
+			continue
+		}
+		if isYes(answer) {
+			return nil
+		}
+		if isNo(answer) {
+			return ErrDeclined
+		}
+	}
+}
+
+// Close stops listening and removes the socket file
+func (s *SocketApprover) Close() error {
+	return s.listener.Close()
+}
+
+func askConnection(ctx context.Context, conn net.Conn, message string) (string, error) {
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "APPROVE %s\n", message); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	answer := strings.TrimSpace(line)
+	if isYes(answer) || isNo(answer) {
+		fmt.Fprintf(conn, "OK\n")
+	}
+	return answer, nil
+}
+
+// AnswerSocket connects to a run waiting on an approval socket and answers its
+// pending request. It returns the message that was answered.
+func AnswerSocket(path string, approve bool) (string, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to %s: %w", path, err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read pending request: %w", err)
+	}
+	message := strings.TrimPrefix(strings.TrimSpace(line), "APPROVE ")
+
+	answer := "no"
+	if approve {
+		answer = "yes"
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", answer); err != nil {
+		return message, fmt.Errorf("failed to send answer: %w", err)
+	}
+	if ack, err := reader.ReadString('\n'); err != nil || strings.TrimSpace(ack) != "OK" {
+		return message, fmt.Errorf("answer was not acknowledged")
+	}
+	return message, nil
+}
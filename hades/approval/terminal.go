@@ -0,0 +1,79 @@
+package approval
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/wzshiming/ctc"
+)
+
+type terminalApprover struct {
+	in  io.Reader
+	out io.Writer
+
+	mu       sync.Mutex // One prompt at a time
+	start    sync.Once
+	lines    chan string
+	readErr  error
+	finished chan struct{}
+}
+
+// NewTerminal returns an approver that prompts on out and reads answers from in.
+// A single reader goroutine owns in, so prompts that time out do not leave
+// competing readers behind.
+func NewTerminal(in io.Reader, out io.Writer) Approver {
+	return &terminalApprover{
+		in:       in,
+		out:      out,
+		lines:    make(chan string),
+		finished: make(chan struct{}),
+	}
+}
+
+func (t *terminalApprover) Approve(ctx context.Context, req Request) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.start.Do(func() { go t.read() })
+
+	fmt.Fprintf(t.out, "\n%s?%s %s [y/N]: ", ctc.ForegroundYellow, ctc.Reset, req.Message)
+
+	select {
+	case line := <-t.lines:
+		if isYes(line) {
+			return nil
+		}
+		return ErrDeclined
+	case <-t.finished:
+		return fmt.Errorf("no answer on stdin (%v); pass --yes, --approve-file or --approve-socket", t.readErr)
+	case <-ctx.Done():
+		fmt.Fprintln(t.out)
+		return ctx.Err()
+	}
+}
+
+func (t *terminalApprover) read() {
+	scanner := bufio.NewScanner(t.in)
+	for scanner.Scan() {
+		t.lines <- scanner.Text()
+	}
+	t.readErr = scanner.Err()
+	if t.readErr == nil {
+		t.readErr = io.EOF
+	}
+	close(t.finished)
+}
+
+func isYes(answer string) bool {
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func isNo(answer string) bool {
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	return answer == "n" || answer == "no"
+}
@@ -0,0 +1,109 @@
+package hades
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SoftKiwiGames/hades/hades/approval"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/ctc"
+)
+
+// approvalOptions selects where answers to approval gates come from
+type approvalOptions struct {
+	yes            bool
+	nonInteractive bool
+	file           string
+	socket         string
+}
+
+func (o *approvalOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, "Approve all wait gates and dynamic host confirmation")
+	cmd.Flags().BoolVar(&o.nonInteractive, "non-interactive", false, "Fail instead of prompting when approval is required")
+	cmd.Flags().StringVar(&o.file, "approve-file", "", "Wait for approvals written to this file (see 'hades approve')")
+	cmd.Flags().StringVar(&o.socket, "approve-socket", "", "Serve approval requests on this unix socket (see 'hades approve')")
+}
+
+// buildApprover returns the approver selected by opts and a function that releases it.
+// Without flags, answers are read from stdin.
+func (h *Hades) buildApprover(opts approvalOptions) (approval.Approver, func(), error) {
+	sources := 0
+	for _, set := range []bool{opts.yes, opts.nonInteractive, opts.file != "", opts.socket != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, nil, fmt.Errorf("--yes, --non-interactive, --approve-file and --approve-socket are mutually exclusive")
+	}
+
+	switch {
+	case opts.yes:
+		return approval.NewAuto(h.stdout), func() {}, nil
+	case opts.file != "":
+		return approval.NewFile(opts.file, h.stdout), func() {}, nil
+	case opts.socket != "":
+		socket, err := approval.NewSocket(opts.socket, h.stdout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return socket, func() { socket.Close() }, nil
+	case opts.nonInteractive:
+		return approval.NewNonInteractive(), func() {}, nil
+	default:
+		return approval.NewTerminal(os.Stdin, h.stdout), func() {}, nil
+	}
+}
+
+func (h *Hades) buildApproveCommand() *cobra.Command {
+	var (
+		file   string
+		socket string
+		deny   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:           "approve",
+		Short:         "Answer an approval gate of a running plan",
+		Long:          "Answer the pending approval gate of a plan started with --approve-file or --approve-socket.",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.runApprove(file, socket, !deny)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "Approval file passed to 'hades run --approve-file'")
+	cmd.Flags().StringVar(&socket, "socket", "", "Approval socket passed to 'hades run --approve-socket'")
+	cmd.Flags().BoolVar(&deny, "deny", false, "Decline instead of approving")
+
+	return cmd
+}
+
+func (h *Hades) runApprove(file, socket string, approve bool) error {
+	if (file == "") == (socket == "") {
+		return fmt.Errorf("exactly one of --file or --socket is required")
+	}
+
+	answer := fmt.Sprintf("%sapproved%s", ctc.ForegroundGreen, ctc.Reset)
+	if !approve {
+		answer = fmt.Sprintf("%sdeclined%s", ctc.ForegroundRed, ctc.Reset)
+	}
+
+	if file != "" {
+		if err := approval.AnswerFile(file, approve); err != nil {
+			return fmt.Errorf("failed to write approval: %w", err)
+		}
+		fmt.Fprintf(h.stdout, "Next gate waiting on %s: %s\n", file, answer)
+		return nil
+	}
+
+	fmt.Fprintf(h.stdout, "Waiting for a pending approval on %s...\n", socket)
+	message, err := approval.AnswerSocket(socket, approve)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h.stdout, "%s: %s\n", message, answer)
+	return nil
+}
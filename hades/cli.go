@@ -1,16 +1,21 @@
 package hades
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 
+	"github.com/SoftKiwiGames/hades/hades/approval"
 	"github.com/SoftKiwiGames/hades/hades/executor"
 	"github.com/SoftKiwiGames/hades/hades/inventory"
 	"github.com/SoftKiwiGames/hades/hades/loader"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/selector"
 	"github.com/SoftKiwiGames/hades/hades/ssh"
 	"github.com/spf13/cobra"
 	"github.com/wzshiming/ctc"
@@ -41,7 +46,10 @@ func (h *Hades) Run() {
 	runCmd := h.buildRunCommand()
 	initCmd := h.buildInitCommand()
 	cloudCmd := h.buildCloudCommand()
-	rootCmd.AddCommand(runCmd, initCmd, cloudCmd)
+	rollbackCmd := h.buildRollbackCommand()
+	approveCmd := h.buildApproveCommand()
+	inventoryCmd := h.buildInventoryCommand()
+	rootCmd.AddCommand(runCmd, initCmd, cloudCmd, rollbackCmd, approveCmd, inventoryCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(h.stderr, "%sError:%s %v\n", ctc.ForegroundRed, ctc.Reset, err)
@@ -55,6 +63,7 @@ func (h *Hades) buildRunCommand() *cobra.Command {
 		targets   []string
 		envVars   []string
 		dryRun    bool
+		approvals approvalOptions
 	)
 
 	cmd := &cobra.Command{
@@ -68,86 +77,179 @@ func (h *Hades) buildRunCommand() *cobra.Command {
 				return h.listPlans(configDir)
 			}
 			planName := args[0]
-			return h.runPlan(planName, configDir, targets, envVars, dryRun)
+			return h.runPlan(planName, configDir, splitTargets(targets), envVars, dryRun, approvals)
 		},
 	}
 
 	cmd.Flags().StringVarP(&configDir, "config-dir", "c", ".", "Directory to search for YAML config files (default: current directory)")
-	cmd.Flags().StringSliceVarP(&targets, "target", "t", nil, "Target groups to execute on")
+	cmd.Flags().StringArrayVarP(&targets, "target", "t", nil, "Target groups, hosts or label selectors to execute on")
 	cmd.Flags().StringSliceVarP(&envVars, "env", "e", nil, "Environment variables (KEY=VALUE)")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be executed without running")
+	approvals.addFlags(cmd)
 
 	return cmd
 }
 
-func (h *Hades) runPlan(planName, configDir string, targets, envVars []string, dryRun bool) error {
+func (h *Hades) runPlan(planName, configDir string, targets, envVars []string, dryRun bool, approvals approvalOptions) error {
+	run, err := h.prepareRun(planName, configDir, targets, envVars, true)
+	if err != nil {
+		return err
+	}
+
+	approver, closeApprover, err := h.buildApprover(approvals)
+	if err != nil {
+		return err
+	}
+	defer closeApprover()
+
+	// Cancel on Ctrl-C / SIGTERM so jobs can run their cleanup actions; once
+	// cancelled, a second signal terminates hades immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Confirm dynamic hosts before proceeding
+	if dynamicHosts := run.inv.DynamicHosts(); len(dynamicHosts) > 0 {
+		if err := h.confirmDynamicHosts(ctx, approver, dynamicHosts); err != nil {
+			return err
+		}
+	}
+
+	// Create SSH client
+	sshClient := ssh.NewClient()
+	defer sshClient.Close()
+
+	// Create executor
+	exec := executor.New(sshClient, approver, h.stdout, h.stderr)
+
+	// Execute plan or dry-run
+	if dryRun {
+		return exec.DryRun(ctx, run.file, run.plan, planName, run.inv, targets, run.env)
+	}
+
+	result, err := exec.ExecutePlan(ctx, run.file, run.plan, planName, run.inv, targets, run.env)
+	if err != nil {
+		return fmt.Errorf("execution failed: %w", err)
+	}
+
+	if result.Failed {
+		return fmt.Errorf("plan failed")
+	}
+
+	return nil
+}
+
+// preparedRun holds everything loaded and validated before a plan is executed
+type preparedRun struct {
+	file *schema.File
+	plan *schema.Plan
+	env  map[string]string
+	inv  inventory.Inventory
+}
+
+// prepareRun loads configuration, plan, environment and inventory for planName.
+// When validateEnv is set, the environment is checked against every job
+// contract, for each host the plan's steps (or targets, if set) resolve to.
+func (h *Hades) prepareRun(planName, configDir string, targets, envVars []string, validateEnv bool) (*preparedRun, error) {
 	// Load and merge all YAML files from the config directory
 	file, err := h.loader.LoadDirectory(configDir)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
 
 	// Validate the file structure
 	if err := h.loader.Validate(file); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
 	// Load the plan
 	plan, err := h.loader.LoadPlan(file, planName)
 	if err != nil {
-		return fmt.Errorf("failed to load plan: %w", err)
+		return nil, fmt.Errorf("failed to load plan: %w", err)
 	}
 
 	// Parse environment variables from CLI
 	env, err := h.parseEnvVars(envVars)
 	if err != nil {
-		return fmt.Errorf("failed to parse environment variables: %w", err)
+		return nil, fmt.Errorf("failed to parse environment variables: %w", err)
 	}
 
 	// Expand environment variables (${VAR})
 	expandedEnv, err := h.loader.ExpandEnv(env)
 	if err != nil {
-		return fmt.Errorf("failed to expand environment variables: %w", err)
-	}
-
-	// Validate environment variables against plan
-	if err := loader.ValidatePlanEnv(file, planName, expandedEnv); err != nil {
-		return fmt.Errorf("environment validation failed: %w", err)
+		return nil, fmt.Errorf("failed to expand environment variables: %w", err)
 	}
 
 	// Load inventory from the same config directory
 	inv, err := inventory.LoadDirectory(configDir)
 	if err != nil {
-		return fmt.Errorf("failed to load inventory: %w", err)
+		return nil, fmt.Errorf("failed to load inventory: %w", err)
 	}
 
-	// Confirm dynamic hosts before proceeding
-	if dynamicHosts := inv.DynamicHosts(); len(dynamicHosts) > 0 {
-		if err := h.confirmDynamicHosts(dynamicHosts); err != nil {
-			return err
+	// Validate environment variables against plan, including inventory vars
+	if validateEnv {
+		if err := loader.ValidatePlanEnv(file, planName, expandedEnv, executor.HostVars(inv, targets)); err != nil {
+			return nil, fmt.Errorf("environment validation failed: %w", err)
 		}
 	}
 
+	return &preparedRun{
+		file: file,
+		plan: plan,
+		env:  expandedEnv,
+		inv:  inv,
+	}, nil
+}
+
+func (h *Hades) buildRollbackCommand() *cobra.Command {
+	var (
+		configDir string
+		hosts     []string
+		envVars   []string
+	)
+
+	cmd := &cobra.Command{
+		Use:           "rollback [plan]",
+		Short:         "Switch release actions of a plan back to the previous release",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.rollbackPlan(args[0], configDir, splitTargets(hosts), envVars)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configDir, "config-dir", "c", ".", "Directory to search for YAML config files (default: current directory)")
+	cmd.Flags().StringArrayVar(&hosts, "host", nil, "Hosts, target groups or label selectors to roll back (default: step targets)")
+	cmd.Flags().StringSliceVarP(&envVars, "env", "e", nil, "Environment variables (KEY=VALUE)")
+
+	return cmd
+}
+
+func (h *Hades) rollbackPlan(planName, configDir string, hosts, envVars []string) error {
+	// Rollback only needs the variables used in release paths, not the full job contracts
+	run, err := h.prepareRun(planName, configDir, hosts, envVars, false)
+	if err != nil {
+		return err
+	}
+
 	// Create SSH client
 	sshClient := ssh.NewClient()
 	defer sshClient.Close()
 
-	// Create executor
-	exec := executor.New(sshClient, h.stdout, h.stderr)
-
-	// Execute plan or dry-run
-	ctx := context.Background()
-	if dryRun {
-		return exec.DryRun(ctx, file, plan, planName, inv, targets, expandedEnv)
-	}
+	// Rollback has no approval gates
+	exec := executor.New(sshClient, approval.NewNonInteractive(), h.stdout, h.stderr)
 
-	result, err := exec.ExecutePlan(ctx, file, plan, planName, inv, targets, expandedEnv)
+	result, err := exec.Rollback(context.Background(), run.file, run.plan, planName, run.inv, hosts, run.env)
 	if err != nil {
-		return fmt.Errorf("execution failed: %w", err)
+		return fmt.Errorf("rollback failed: %w", err)
 	}
 
 	if result.Failed {
-		return fmt.Errorf("plan failed")
+		return fmt.Errorf("rollback failed")
 	}
 
 	return nil
@@ -184,7 +286,7 @@ func (h *Hades) listPlans(configDir string) error {
 	return nil
 }
 
-func (h *Hades) confirmDynamicHosts(hosts []ssh.Host) error {
+func (h *Hades) confirmDynamicHosts(ctx context.Context, approver approval.Approver, hosts []ssh.Host) error {
 	fmt.Fprintf(h.stdout, "\n%sDynamic inventory detected %d host(s):%s\n\n", ctc.ForegroundYellow, len(hosts), ctc.Reset)
 
 	nameW := len("NAME")
@@ -199,19 +301,35 @@ func (h *Hades) confirmDynamicHosts(hosts []ssh.Host) error {
 		fmt.Fprintf(h.stdout, "  %-*s  %s\n", nameW, host.Name, host.Address)
 	}
 
-	fmt.Fprintf(h.stdout, "\nProceed? (yes/no): ")
-	scanner := bufio.NewScanner(os.Stdin)
-	if !scanner.Scan() {
-		return fmt.Errorf("aborted")
-	}
-	answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
-	if answer != "yes" && answer != "y" {
+	err := approver.Approve(ctx, approval.Request{Key: "dynamic-hosts", Message: "Proceed with these hosts?"})
+	if errors.Is(err, approval.ErrDeclined) {
 		return fmt.Errorf("aborted by user")
 	}
+	if err != nil {
+		return fmt.Errorf("aborted: %w", err)
+	}
 	fmt.Fprintln(h.stdout)
 	return nil
 }
 
+// splitTargets splits comma-separated target flags; selector expressions are
+// kept whole since they may contain commas themselves
+func splitTargets(flags []string) []string {
+	var targets []string
+	for _, flag := range flags {
+		if selector.IsExpression(flag) {
+			targets = append(targets, flag)
+			continue
+		}
+		for _, name := range strings.Split(flag, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				targets = append(targets, name)
+			}
+		}
+	}
+	return targets
+}
+
 func (h *Hades) parseEnvVars(envVars []string) (map[string]string, error) {
 	env := make(map[string]string)
 	for _, ev := range envVars {
@@ -3,7 +3,6 @@ package hades
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
 
@@ -18,43 +17,43 @@ func (h *Hades) buildCloudCommand() *cobra.Command {
 		Short: "Interact with cloud providers",
 	}
 
-	cmd.AddCommand(h.buildCloudHetznerCommand())
-	cmd.AddCommand(h.buildCloudAWSCommand())
+	for _, p := range cloud.Providers() {
+		cmd.AddCommand(h.buildCloudProviderCommand(p))
+	}
 
 	return cmd
 }
 
-func (h *Hades) buildCloudHetznerCommand() *cobra.Command {
+func (h *Hades) buildCloudProviderCommand(p cloud.Provider) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "hetzner",
-		Short: "Hetzner Cloud",
+		Use:   p.Name(),
+		Short: p.Description(),
 	}
 
-	cmd.AddCommand(h.buildCloudHetznerHostsCommand())
+	cmd.AddCommand(h.buildCloudHostsCommand(p))
 
 	return cmd
 }
 
-func (h *Hades) buildCloudHetznerHostsCommand() *cobra.Command {
-	var token string
-
+// buildCloudHostsCommand lists a provider's instances, with one flag per
+// config field so that the CLI accepts the same settings as hosts.providers
+func (h *Hades) buildCloudHostsCommand(p cloud.Provider) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:           "hosts",
 		Short:         "List cloud instances",
+		Args:          cobra.NoArgs,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if token == "" {
-				token = os.Getenv("HCLOUD_TOKEN")
-			}
-			if token == "" {
-				return fmt.Errorf("token is required (use --token or HCLOUD_TOKEN)")
+			// Unset flags are left to the provider's defaults and env fallbacks
+			values := make(map[string]string)
+			for _, f := range p.Fields() {
+				if cmd.Flags().Changed(f.Name) {
+					values[f.Name], _ = cmd.Flags().GetString(f.Name)
+				}
 			}
 
-			instances, err := cloud.HetznerInstances(
-				context.Background(),
-				cloud.HetznerConfig{Token: token},
-			)
+			instances, err := cloud.Fetch(context.Background(), p.Name(), values, "")
 			if err != nil {
 				return err
 			}
@@ -64,47 +63,14 @@ func (h *Hades) buildCloudHetznerHostsCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&token, "token", "", "Hetzner Cloud API token (default: HCLOUD_TOKEN)")
-
-	return cmd
-}
-
-func (h *Hades) buildCloudAWSCommand() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "aws",
-		Short: "Amazon Web Services",
-	}
-
-	cmd.AddCommand(h.buildCloudAWSHostsCommand())
-
-	return cmd
-}
-
-func (h *Hades) buildCloudAWSHostsCommand() *cobra.Command {
-	var profile, region string
-
-	cmd := &cobra.Command{
-		Use:           "hosts",
-		Short:         "List cloud instances",
-		SilenceUsage:  true,
-		SilenceErrors: true,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			instances, err := cloud.AWSInstances(
-				context.Background(),
-				cloud.AWSConfig{Profile: profile, Region: region},
-			)
-			if err != nil {
-				return err
-			}
-
-			h.printInstances(instances)
-			return nil
-		},
+	for _, f := range p.Fields() {
+		usage := f.Usage
+		if f.Env != "" {
+			usage = fmt.Sprintf("%s (default: %s)", usage, f.Env)
+		}
+		cmd.Flags().String(f.Name, f.Default, usage)
 	}
 
-	cmd.Flags().StringVar(&profile, "profile", "", "AWS profile (default: AWS_PROFILE)")
-	cmd.Flags().StringVar(&region, "region", "", "AWS region (default: AWS_REGION)")
-
 	return cmd
 }
 
@@ -114,58 +80,63 @@ func (h *Hades) printInstances(instances []cloud.CloudInstance) {
 		return
 	}
 
-	// Build rows and compute column widths
-	type row struct {
-		name, ipv4, ipv6, tags string
-	}
-
-	rows := make([]row, len(instances))
-	nameW, ipv4W, ipv6W := len("NAME"), len("IPV4"), len("IPV6")
-
+	rows := make([][]string, len(instances))
 	for i, inst := range instances {
-		r := row{name: inst.Name}
-
-		r.ipv4 = "-"
+		ipv4 := "-"
 		if inst.PublicIPv4 != nil {
-			r.ipv4 = inst.PublicIPv4.String()
+			ipv4 = inst.PublicIPv4.String()
 		}
-		r.ipv6 = "-"
+		ipv6 := "-"
 		if inst.PublicIPv6 != nil {
-			r.ipv6 = inst.PublicIPv6.String()
+			ipv6 = inst.PublicIPv6.String()
 		}
-		r.tags = formatTags(inst.Tags)
+		rows[i] = []string{inst.Name, ipv4, ipv6, formatTags(inst.Tags)}
+	}
 
-		if len(r.name) > nameW {
-			nameW = len(r.name)
-		}
-		if len(r.ipv4) > ipv4W {
-			ipv4W = len(r.ipv4)
-		}
-		if len(r.ipv6) > ipv6W {
-			ipv6W = len(r.ipv6)
-		}
+	h.printTable([]string{"NAME", "IPV4", "IPV6", "TAGS"}, rows, 1)
+}
 
-		rows[i] = r
+// printTable prints rows under a bold header with aligned columns; the column
+// at index highlight (-1 for none) is printed in magenta
+func (h *Hades) printTable(header []string, rows [][]string, highlight int) {
+	widths := make([]int, len(header))
+	for i, title := range header {
+		widths[i] = len(title)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
 	}
 
 	pad := 3
+	last := len(header) - 1
 
 	// header (bold)
-	fmt.Fprintf(h.stdout, "\033[1m%-*s%-*s%-*s%s\033[0m\n",
-		nameW+pad, "NAME",
-		ipv4W+pad, "IPV4",
-		ipv6W+pad, "IPV6",
-		"TAGS",
-	)
-
-	for _, r := range rows {
-		paddedIPv4 := fmt.Sprintf("%-*s", ipv4W+pad, r.ipv4)
-		fmt.Fprintf(h.stdout, "%-*s%s%s%s%-*s%s\n",
-			nameW+pad, r.name,
-			ctc.ForegroundMagenta, paddedIPv4, ctc.Reset,
-			ipv6W+pad, r.ipv6,
-			r.tags,
-		)
+	fmt.Fprint(h.stdout, "\033[1m")
+	for i, title := range header {
+		if i == last {
+			fmt.Fprint(h.stdout, title)
+		} else {
+			fmt.Fprintf(h.stdout, "%-*s", widths[i]+pad, title)
+		}
+	}
+	fmt.Fprint(h.stdout, "\033[0m\n")
+
+	for _, row := range rows {
+		for i, cell := range row {
+			if i != last {
+				cell = fmt.Sprintf("%-*s", widths[i]+pad, cell)
+			}
+			if i == highlight {
+				fmt.Fprintf(h.stdout, "%s%s%s", ctc.ForegroundMagenta, cell, ctc.Reset)
+			} else {
+				fmt.Fprint(h.stdout, cell)
+			}
+		}
+		fmt.Fprintln(h.stdout)
 	}
 }
 
@@ -15,6 +15,26 @@ type AWSConfig struct {
 	Region  string
 }
 
+func init() {
+	Register(awsProvider{})
+}
+
+type awsProvider struct{}
+
+func (awsProvider) Name() string        { return "aws" }
+func (awsProvider) Description() string { return "Amazon Web Services" }
+
+func (awsProvider) Fields() []Field {
+	return []Field{
+		{Name: "profile", Usage: "AWS profile (default: AWS_PROFILE)"},
+		{Name: "region", Usage: "AWS region (default: AWS_REGION)"},
+	}
+}
+
+func (awsProvider) Instances(ctx context.Context, cfg Config) ([]CloudInstance, error) {
+	return AWSInstances(ctx, AWSConfig{Profile: cfg.Get("profile"), Region: cfg.Get("region")})
+}
+
 func AWSInstances(ctx context.Context, cfg AWSConfig) ([]CloudInstance, error) {
 	var opts []func(*config.LoadOptions) error
 	if cfg.Profile != "" {
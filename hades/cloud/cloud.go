@@ -7,4 +7,13 @@ type CloudInstance struct {
 	PublicIPv4 net.IP
 	PublicIPv6 net.IP
 	Tags       map[string]string
+	SSH        *InstanceSSH // Overrides the provider's ssh defaults, if set
+}
+
+// InstanceSSH holds per-instance connection settings; empty fields keep the
+// provider's defaults
+type InstanceSSH struct {
+	User         string `json:"user,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	IdentityFile string `json:"identity_file,omitempty"`
 }
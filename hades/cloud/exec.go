@@ -0,0 +1,148 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// execWaitDelay bounds how long output is read after the command exits, so a
+// child it left in the background cannot hold the pipes open
+const execWaitDelay = time.Second
+
+type ExecConfig struct {
+	Command string        // Run with sh -c
+	Dir     string        // Working directory of the command
+	Timeout time.Duration // Default 30s
+}
+
+func init() {
+	Register(execProvider{})
+}
+
+type execProvider struct{}
+
+func (execProvider) Name() string        { return "exec" }
+func (execProvider) Description() string { return "External inventory script" }
+
+func (execProvider) Fields() []Field {
+	return []Field{
+		// Expanded by the shell, not by hades
+		{Name: "command", Usage: "Command printing hosts as JSON (run with sh -c)", Required: true, Raw: true},
+		{Name: "timeout", Usage: "Time limit for the command", Default: "30s"},
+	}
+}
+
+func (execProvider) Instances(ctx context.Context, cfg Config) ([]CloudInstance, error) {
+	timeout, err := time.ParseDuration(cfg.Get("timeout"))
+	if err != nil {
+		return nil, fmt.Errorf("exec: invalid timeout %q: %w", cfg.Get("timeout"), err)
+	}
+	return ExecInstances(ctx, ExecConfig{Command: cfg.Get("command"), Dir: cfg.Dir, Timeout: timeout})
+}
+
+// execOutput is the JSON an exec provider command prints on stdout: an object
+// with a hosts list, or the list on its own.
+//
+//	{"hosts": [{"name": "web-1", "ipv4": "10.0.0.1", "ipv6": "2001:db8::1",
+//	            "tags": {"role": "web"},
+//	            "ssh": {"user": "deploy", "port": 2222, "identity_file": "~/.ssh/web"}}]}
+//
+// name and at least one address are required; tags and ssh are optional.
+type execOutput struct {
+	Hosts []execHost `json:"hosts"`
+}
+
+type execHost struct {
+	Name string            `json:"name"`
+	IPv4 string            `json:"ipv4"`
+	IPv6 string            `json:"ipv6"`
+	Tags map[string]string `json:"tags"`
+	SSH  *InstanceSSH      `json:"ssh"`
+}
+
+// ExecInstances runs a local command and reads instances from the JSON it
+// prints, so any inventory source can be plugged in with a script.
+func ExecInstances(ctx context.Context, cfg ExecConfig) ([]CloudInstance, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("exec: command is required")
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", cfg.Command)
+	cmd.Dir = cfg.Dir
+	cmd.WaitDelay = execWaitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// ErrWaitDelay means the command succeeded but a background child still
+	// held its output open; everything it printed has been read
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("exec: command timed out after %s", timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("exec: command failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("exec: command failed: %w", err)
+	}
+
+	instances, err := parseExecOutput(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("exec: %w", err)
+	}
+	return instances, nil
+}
+
+func parseExecOutput(data []byte) ([]CloudInstance, error) {
+	var out execOutput
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &out.Hosts); err != nil {
+			return nil, fmt.Errorf("failed to parse output JSON: %w", err)
+		}
+	} else if err := json.Unmarshal(trimmed, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse output JSON: %w", err)
+	}
+
+	instances := make([]CloudInstance, 0, len(out.Hosts))
+	for i, h := range out.Hosts {
+		if h.Name == "" {
+			return nil, fmt.Errorf("hosts[%d]: name is required", i)
+		}
+
+		inst := CloudInstance{Name: h.Name, Tags: h.Tags, SSH: h.SSH}
+		if h.IPv4 != "" {
+			if inst.PublicIPv4 = net.ParseIP(h.IPv4); inst.PublicIPv4 == nil || inst.PublicIPv4.To4() == nil {
+				return nil, fmt.Errorf("host %q: invalid ipv4 %q", h.Name, h.IPv4)
+			}
+		}
+		if h.IPv6 != "" {
+			if inst.PublicIPv6 = net.ParseIP(h.IPv6); inst.PublicIPv6 == nil {
+				return nil, fmt.Errorf("host %q: invalid ipv6 %q", h.Name, h.IPv6)
+			}
+		}
+		if inst.PublicIPv4 == nil && inst.PublicIPv6 == nil {
+			return nil, fmt.Errorf("host %q: ipv4 or ipv6 is required", h.Name)
+		}
+		if inst.Tags == nil {
+			inst.Tags = map[string]string{}
+		}
+
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
@@ -0,0 +1,104 @@
+package cloud
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecInstances(t *testing.T) {
+	dir := t.TempDir()
+	output := `{"hosts": [
+  {"name": "web-1", "ipv4": "10.0.0.1", "tags": {"role": "web"}, "ssh": {"user": "deploy", "port": 2222}},
+  {"name": "db-1", "ipv6": "2001:db8::5"}
+]}`
+	if err := os.WriteFile(filepath.Join(dir, "hosts.json"), []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The command runs in Dir
+	instances, err := ExecInstances(context.Background(), ExecConfig{Command: "cat hosts.json", Dir: dir})
+	if err != nil {
+		t.Fatalf("ExecInstances: %v", err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("Expected 2 instances, got %d", len(instances))
+	}
+
+	web := instances[0]
+	if web.Name != "web-1" || web.PublicIPv4.String() != "10.0.0.1" || web.Tags["role"] != "web" {
+		t.Errorf("Unexpected instance %+v", web)
+	}
+	if want := (&InstanceSSH{User: "deploy", Port: 2222}); !reflect.DeepEqual(web.SSH, want) {
+		t.Errorf("Expected ssh %+v, got %+v", want, web.SSH)
+	}
+
+	db := instances[1]
+	if db.PublicIPv4 != nil || db.PublicIPv6.String() != "2001:db8::5" || db.SSH != nil || db.Tags == nil {
+		t.Errorf("Unexpected instance %+v", db)
+	}
+}
+
+func TestExecInstances_BareList(t *testing.T) {
+	instances, err := ExecInstances(context.Background(), ExecConfig{Command: `echo '[{"name": "a", "ipv4": "10.0.0.1"}]'`})
+	if err != nil {
+		t.Fatalf("ExecInstances: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Name != "a" {
+		t.Fatalf("Unexpected instances %+v", instances)
+	}
+}
+
+func TestExecInstances_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ExecConfig
+		wantErr string
+	}{
+		{"no command", ExecConfig{}, "command is required"},
+		{"command fails", ExecConfig{Command: "echo 'cmdb unreachable' >&2; exit 3"}, "cmdb unreachable"},
+		{"timeout", ExecConfig{Command: "sleep 5", Timeout: 50 * time.Millisecond}, "timed out"},
+		{"not json", ExecConfig{Command: "echo hello"}, "failed to parse output JSON"},
+		{"missing name", ExecConfig{Command: `echo '[{"ipv4": "10.0.0.1"}]'`}, "hosts[0]: name is required"},
+		{"missing address", ExecConfig{Command: `echo '[{"name": "a"}]'`}, "ipv4 or ipv6 is required"},
+		{"invalid address", ExecConfig{Command: `echo '[{"name": "a", "ipv4": "2001:db8::1"}]'`}, "invalid ipv4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExecInstances(context.Background(), tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExecInstances_BackgroundChild(t *testing.T) {
+	// The sleep keeps stdout open after the script exits
+	start := time.Now()
+	instances, err := ExecInstances(context.Background(), ExecConfig{Command: `sleep 30 & echo '[{"name": "a", "ipv4": "10.0.0.1"}]'`})
+	if err != nil {
+		t.Fatalf("ExecInstances: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Name != "a" {
+		t.Fatalf("Unexpected instances %+v", instances)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected to return once the script exits, took %s", elapsed)
+	}
+
+	// A script killed at the timeout does not wait for its children either
+	start = time.Now()
+	_, err = ExecInstances(context.Background(), ExecConfig{Command: "sleep 30 & wait", Timeout: 50 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected to return at the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,36 @@
+package cloud
+
+import "context"
+
+// FakeProvider returns fixed instances and records the configs it was called
+// with, for tests. Register it under a name no real provider uses.
+type FakeProvider struct {
+	ProviderName string
+	Schema       []Field
+	Result       []CloudInstance
+	Err          error
+	Calls        []Config
+}
+
+func (f *FakeProvider) Name() string {
+	if f.ProviderName == "" {
+		return "fake"
+	}
+	return f.ProviderName
+}
+
+func (f *FakeProvider) Description() string {
+	return "Fake provider for tests"
+}
+
+func (f *FakeProvider) Fields() []Field {
+	return f.Schema
+}
+
+func (f *FakeProvider) Instances(ctx context.Context, cfg Config) ([]CloudInstance, error) {
+	f.Calls = append(f.Calls, cfg)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return f.Result, nil
+}
@@ -11,6 +11,25 @@ type HetznerConfig struct {
 	Token string
 }
 
+func init() {
+	Register(hetznerProvider{})
+}
+
+type hetznerProvider struct{}
+
+func (hetznerProvider) Name() string        { return "hetzner" }
+func (hetznerProvider) Description() string { return "Hetzner Cloud" }
+
+func (hetznerProvider) Fields() []Field {
+	return []Field{
+		{Name: "token", Usage: "Hetzner Cloud API token", Env: "HCLOUD_TOKEN", Required: true},
+	}
+}
+
+func (hetznerProvider) Instances(ctx context.Context, cfg Config) ([]CloudInstance, error) {
+	return HetznerInstances(ctx, HetznerConfig{Token: cfg.Get("token")})
+}
+
 func HetznerInstances(ctx context.Context, cfg HetznerConfig) ([]CloudInstance, error) {
 	if cfg.Token == "" {
 		return nil, fmt.Errorf("hetzner: token is required")
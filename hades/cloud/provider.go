@@ -0,0 +1,160 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/utils"
+)
+
+// Provider is a source of instances. Providers register themselves by name;
+// hosts.providers in the inventory and 'hades cloud <name> hosts' both look
+// them up in the registry.
+type Provider interface {
+	Name() string
+	Description() string // Short help for 'hades cloud <name>'
+	Fields() []Field     // Accepted config keys, also the CLI flags
+	Instances(ctx context.Context, cfg Config) ([]CloudInstance, error)
+}
+
+// Field describes one config key of a provider
+type Field struct {
+	Name     string // Key under config: and the --<name> flag
+	Usage    string
+	Default  string
+	Env      string // Environment variable used when the key is not set
+	Required bool
+	Path     bool // Resolved relative to Config.Dir, with ~ expanded
+	Raw      bool // Passed as written, without ${VAR} expansion
+}
+
+// Config is the resolved config a provider runs with
+type Config struct {
+	Values map[string]string
+	Dir    string // Directory of the inventory file; empty for the working directory
+}
+
+func (c Config) Get(key string) string {
+	return c.Values[key]
+}
+
+var providers = make(map[string]Provider)
+
+// Register makes a provider available by name. It panics if the name is
+// taken, as providers register in init.
+func Register(p Provider) {
+	if _, exists := providers[p.Name()]; exists {
+		panic(fmt.Sprintf("cloud: provider %q registered twice", p.Name()))
+	}
+	providers[p.Name()] = p
+}
+
+// Unregister removes a provider, for tests registering a FakeProvider
+func Unregister(name string) {
+	delete(providers, name)
+}
+
+func Get(name string) (Provider, bool) {
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Providers returns the registered providers sorted by name
+func Providers() []Provider {
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Resolve checks values against the provider's fields and applies defaults,
+// environment fallbacks, ${VAR} expansion and path resolution
+func Resolve(p Provider, values map[string]string, dir string) (Config, error) {
+	fields := p.Fields()
+	known := make(map[string]bool, len(fields))
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		known[f.Name] = true
+		names[i] = f.Name
+	}
+
+	for _, key := range sortedKeys(values) {
+		if !known[key] {
+			expected := "none"
+			if len(names) > 0 {
+				expected = strings.Join(names, ", ")
+			}
+			return Config{}, fmt.Errorf("%s: unknown config key %q (expected %s)", p.Name(), key, expected)
+		}
+	}
+
+	cfg := Config{Values: make(map[string]string, len(fields)), Dir: dir}
+	for _, f := range fields {
+		value := values[f.Name]
+		if !f.Raw {
+			value = os.Expand(value, os.Getenv)
+		}
+		if value == "" && f.Env != "" {
+			value = os.Getenv(f.Env)
+		}
+		if value == "" {
+			value = f.Default
+		}
+		if value == "" {
+			if f.Required {
+				if f.Env != "" {
+					return Config{}, fmt.Errorf("%s: %s is required (or set %s)", p.Name(), f.Name, f.Env)
+				}
+				return Config{}, fmt.Errorf("%s: %s is required", p.Name(), f.Name)
+			}
+			continue
+		}
+
+		if f.Path {
+			if !filepath.IsAbs(value) && !strings.HasPrefix(value, "~") && dir != "" {
+				value = filepath.Join(dir, value)
+			}
+			expanded, err := utils.ExpandPath(value)
+			if err != nil {
+				return Config{}, fmt.Errorf("%s: %s: %w", p.Name(), f.Name, err)
+			}
+			value = expanded
+		}
+		cfg.Values[f.Name] = value
+	}
+	return cfg, nil
+}
+
+// Fetch resolves config for the named provider and lists its instances.
+// Relative paths in values are resolved against dir.
+func Fetch(ctx context.Context, name string, values map[string]string, dir string) ([]CloudInstance, error) {
+	p, ok := Get(name)
+	if !ok {
+		names := make([]string, 0, len(providers))
+		for _, p := range Providers() {
+			names = append(names, p.Name())
+		}
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(names, ", "))
+	}
+
+	cfg, err := Resolve(p, values, dir)
+	if err != nil {
+		return nil, err
+	}
+	return p.Instances(ctx, cfg)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("FAKE_TOKEN", "from-env")
+	t.Setenv("STAGE", "prod")
+
+	p := &FakeProvider{Schema: []Field{
+		{Name: "token", Env: "FAKE_TOKEN", Required: true},
+		{Name: "state", Default: "terraform.tfstate", Path: true},
+		{Name: "command", Raw: true},
+		{Name: "region"},
+	}}
+
+	cfg, err := Resolve(p, map[string]string{
+		"command": "echo ${STAGE}",
+		"region":  "eu-${STAGE}",
+	}, "/srv/inventory")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	want := map[string]string{
+		"token":   "from-env",
+		"state":   filepath.Join("/srv/inventory", "terraform.tfstate"),
+		"command": "echo ${STAGE}",
+		"region":  "eu-prod",
+	}
+	if !reflect.DeepEqual(cfg.Values, want) {
+		t.Errorf("Expected %v, got %v", want, cfg.Values)
+	}
+	if cfg.Dir != "/srv/inventory" {
+		t.Errorf("Expected dir /srv/inventory, got %q", cfg.Dir)
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	t.Setenv("FAKE_TOKEN", "")
+	p := &FakeProvider{Schema: []Field{
+		{Name: "token", Env: "FAKE_TOKEN", Required: true},
+	}}
+
+	_, err := Resolve(p, map[string]string{"token": "x", "tokn": "y"}, "")
+	if err == nil || !strings.Contains(err.Error(), `unknown config key "tokn" (expected token)`) {
+		t.Errorf("Expected unknown key error, got %v", err)
+	}
+
+	_, err = Resolve(p, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "token is required (or set FAKE_TOKEN)") {
+		t.Errorf("Expected required error, got %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"aws", "exec", "hetzner", "terraform"} {
+		if _, ok := Get(name); !ok {
+			t.Errorf("Expected built-in provider %q to be registered", name)
+		}
+	}
+
+	fake := &FakeProvider{
+		ProviderName: "test-registry",
+		Schema:       []Field{{Name: "zone"}},
+		Result:       []CloudInstance{{Name: "a"}},
+	}
+	Register(fake)
+	t.Cleanup(func() { Unregister(fake.Name()) })
+
+	instances, err := Fetch(context.Background(), "test-registry", map[string]string{"zone": "a"}, "")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(instances) != 1 || len(fake.Calls) != 1 || fake.Calls[0].Get("zone") != "a" {
+		t.Errorf("Unexpected instances %v, calls %v", instances, fake.Calls)
+	}
+
+	fake.Err = errors.New("api down")
+	if _, err := Fetch(context.Background(), "test-registry", nil, ""); err == nil || err.Error() != "api down" {
+		t.Errorf("Expected provider error, got %v", err)
+	}
+
+	_, err = Fetch(context.Background(), "nope", nil, "")
+	if err == nil || !strings.Contains(err.Error(), `unknown provider "nope" (available: aws, exec, hetzner`) {
+		t.Errorf("Expected unknown provider error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	Register(&FakeProvider{ProviderName: "test-registry"})
+}
@@ -0,0 +1,200 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+type TerraformConfig struct {
+	State string // Path to terraform.tfstate or saved `terraform show -json` output
+}
+
+func init() {
+	Register(terraformProvider{})
+}
+
+type terraformProvider struct{}
+
+func (terraformProvider) Name() string        { return "terraform" }
+func (terraformProvider) Description() string { return "Terraform state (offline)" }
+
+func (terraformProvider) Fields() []Field {
+	return []Field{
+		{Name: "state", Usage: "Path to terraform.tfstate or saved 'terraform show -json' output", Default: "terraform.tfstate", Path: true},
+	}
+}
+
+func (terraformProvider) Instances(ctx context.Context, cfg Config) ([]CloudInstance, error) {
+	return TerraformInstances(ctx, TerraformConfig{State: cfg.Get("state")})
+}
+
+// TerraformInstances reads servers from a Terraform state file without calling
+// Terraform or any cloud API. Supported resources are hcloud_server (labels
+// become tags) and running aws_instance resources (named by their Name tag).
+func TerraformInstances(ctx context.Context, cfg TerraformConfig) ([]CloudInstance, error) {
+	if cfg.State == "" {
+		return nil, fmt.Errorf("terraform: state is required")
+	}
+
+	data, err := os.ReadFile(cfg.State)
+	if err != nil {
+		return nil, fmt.Errorf("terraform: failed to read state: %w", err)
+	}
+
+	resources, err := parseTerraformState(data)
+	if err != nil {
+		return nil, fmt.Errorf("terraform: %s: %w", cfg.State, err)
+	}
+
+	var instances []CloudInstance
+	for _, r := range resources {
+		inst, ok := terraformInstance(r)
+		if ok {
+			instances = append(instances, inst)
+		}
+	}
+	return instances, nil
+}
+
+// terraformResource is one managed resource instance with its attributes
+type terraformResource struct {
+	Type       string
+	Attributes map[string]any
+}
+
+// tfstate is the subset of both state formats used here: the raw state file
+// (version 4) and `terraform show -json` (format_version, values)
+type tfstate struct {
+	Version       int    `json:"version"`
+	FormatVersion string `json:"format_version"`
+	Resources     []struct {
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Instances []struct {
+			Attributes map[string]any `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+	Values *struct {
+		RootModule tfshowModule `json:"root_module"`
+	} `json:"values"`
+}
+
+type tfshowModule struct {
+	Resources []struct {
+		Mode   string         `json:"mode"`
+		Type   string         `json:"type"`
+		Values map[string]any `json:"values"`
+	} `json:"resources"`
+	ChildModules []tfshowModule `json:"child_modules"`
+}
+
+func parseTerraformState(data []byte) ([]terraformResource, error) {
+	var state tfstate
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state JSON: %w", err)
+	}
+
+	var resources []terraformResource
+	switch {
+	case state.FormatVersion != "":
+		if state.Values != nil {
+			resources = collectShowResources(state.Values.RootModule, resources)
+		}
+	case state.Version == 4:
+		for _, r := range state.Resources {
+			if r.Mode != "managed" {
+				continue
+			}
+			for _, inst := range r.Instances {
+				resources = append(resources, terraformResource{Type: r.Type, Attributes: inst.Attributes})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported state version %d (expected 4 or `terraform show -json` output)", state.Version)
+	}
+	return resources, nil
+}
+
+// collectShowResources walks a module of `terraform show -json` output and its
+// child modules
+func collectShowResources(module tfshowModule, resources []terraformResource) []terraformResource {
+	for _, r := range module.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		resources = append(resources, terraformResource{Type: r.Type, Attributes: r.Values})
+	}
+	for _, child := range module.ChildModules {
+		resources = collectShowResources(child, resources)
+	}
+	return resources
+}
+
+func terraformInstance(r terraformResource) (CloudInstance, bool) {
+	attrs := r.Attributes
+	switch r.Type {
+	case "hcloud_server":
+		return CloudInstance{
+			Name:       attrString(attrs, "name"),
+			PublicIPv4: parseIP(attrString(attrs, "ipv4_address")),
+			PublicIPv6: parseIP(attrString(attrs, "ipv6_address")),
+			Tags:       attrMap(attrs, "labels"),
+		}, true
+	case "aws_instance":
+		if state := attrString(attrs, "instance_state"); state != "" && state != "running" {
+			return CloudInstance{}, false
+		}
+		tags := attrMap(attrs, "tags_all")
+		if len(tags) == 0 {
+			tags = attrMap(attrs, "tags")
+		}
+		inst := CloudInstance{
+			Name:       tags["Name"],
+			PublicIPv4: parseIP(attrString(attrs, "public_ip")),
+			Tags:       tags,
+		}
+		if ipv6, ok := attrs["ipv6_addresses"].([]any); ok && len(ipv6) > 0 {
+			if addr, ok := ipv6[0].(string); ok {
+				inst.PublicIPv6 = parseIP(addr)
+			}
+		}
+		return inst, true
+	default:
+		return CloudInstance{}, false
+	}
+}
+
+func attrString(attrs map[string]any, key string) string {
+	s, _ := attrs[key].(string)
+	return s
+}
+
+func attrMap(attrs map[string]any, key string) map[string]string {
+	raw, ok := attrs[key].(map[string]any)
+	if !ok {
+		return map[string]string{}
+	}
+	m := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			m[k] = s
+		}
+	}
+	return m
+}
+
+// parseIP parses an address, ignoring empty values and a prefix length if
+// one is present
+func parseIP(s string) net.IP {
+	if s == "" {
+		return nil
+	}
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
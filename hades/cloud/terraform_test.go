@@ -0,0 +1,163 @@
+package cloud
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tfstateV4 = `{
+  "version": 4,
+  "terraform_version": "1.9.0",
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "hcloud_server",
+      "name": "app",
+      "instances": [
+        {
+          "index_key": 0,
+          "attributes": {
+            "name": "dev-app-01",
+            "ipv4_address": "203.0.113.10",
+            "ipv6_address": "2001:db8::1",
+            "labels": {"cluster": "app", "env": "dev"}
+          }
+        }
+      ]
+    },
+    {
+      "mode": "data",
+      "type": "hcloud_server",
+      "name": "lookup",
+      "instances": [{"attributes": {"name": "ignored"}}]
+    },
+    {
+      "mode": "managed",
+      "type": "hcloud_network",
+      "name": "net",
+      "instances": [{"attributes": {"name": "ignored"}}]
+    }
+  ]
+}`
+
+const tfshowJSON = `{
+  "format_version": "1.0",
+  "values": {
+    "root_module": {
+      "resources": [
+        {
+          "address": "aws_instance.web",
+          "mode": "managed",
+          "type": "aws_instance",
+          "values": {
+            "instance_state": "running",
+            "public_ip": "198.51.100.7",
+            "ipv6_addresses": ["2001:db8::7"],
+            "tags": {"Name": "web-1"},
+            "tags_all": {"Name": "web-1", "env": "prod"}
+          }
+        }
+      ],
+      "child_modules": [
+        {
+          "address": "module.workers",
+          "resources": [
+            {
+              "mode": "managed",
+              "type": "aws_instance",
+              "values": {
+                "instance_state": "stopped",
+                "public_ip": "198.51.100.8",
+                "tags": {"Name": "worker-1"}
+              }
+            },
+            {
+              "mode": "managed",
+              "type": "hcloud_server",
+              "values": {"name": "worker-2", "ipv4_address": "203.0.113.20", "ipv6_address": "", "labels": {}}
+            }
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func writeState(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTerraformInstances_State(t *testing.T) {
+	instances, err := TerraformInstances(context.Background(), TerraformConfig{State: writeState(t, tfstateV4)})
+	if err != nil {
+		t.Fatalf("TerraformInstances: %v", err)
+	}
+	if len(instances) != 1 {
+		t.Fatalf("Expected 1 instance, got %d", len(instances))
+	}
+
+	inst := instances[0]
+	if inst.Name != "dev-app-01" || inst.PublicIPv4.String() != "203.0.113.10" || inst.PublicIPv6.String() != "2001:db8::1" {
+		t.Errorf("Unexpected instance %+v", inst)
+	}
+	if want := map[string]string{"cluster": "app", "env": "dev"}; !reflect.DeepEqual(inst.Tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, inst.Tags)
+	}
+}
+
+func TestTerraformInstances_ShowJSON(t *testing.T) {
+	instances, err := TerraformInstances(context.Background(), TerraformConfig{State: writeState(t, tfshowJSON)})
+	if err != nil {
+		t.Fatalf("TerraformInstances: %v", err)
+	}
+
+	var names []string
+	for _, inst := range instances {
+		names = append(names, inst.Name)
+	}
+	if want := []string{"web-1", "worker-2"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected instances %v, got %v", want, names)
+	}
+
+	web := instances[0]
+	if web.PublicIPv4.String() != "198.51.100.7" || web.PublicIPv6.String() != "2001:db8::7" {
+		t.Errorf("Unexpected addresses %v %v", web.PublicIPv4, web.PublicIPv6)
+	}
+	if web.Tags["env"] != "prod" {
+		t.Errorf("Expected tags_all to be used, got %v", web.Tags)
+	}
+	if instances[1].PublicIPv6 != nil {
+		t.Errorf("Expected no IPv6 for empty address, got %v", instances[1].PublicIPv6)
+	}
+}
+
+func TestTerraformInstances_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     TerraformConfig
+		wantErr string
+	}{
+		{"no state", TerraformConfig{}, "state is required"},
+		{"missing file", TerraformConfig{State: filepath.Join(t.TempDir(), "missing.tfstate")}, "failed to read state"},
+		{"old version", TerraformConfig{State: writeState(t, `{"version": 3}`)}, "unsupported state version 3"},
+		{"not json", TerraformConfig{State: writeState(t, `resources = []`)}, "failed to parse state JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TerraformInstances(context.Background(), tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
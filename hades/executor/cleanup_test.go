@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// cleanupFile returns a file with a job whose actions are the given commands,
+// followed by on_failure and finally commands
+func cleanupFile(actions ...string) *schema.File {
+	runs := func(cmds ...string) []schema.Action {
+		list := make([]schema.Action, len(cmds))
+		for i, cmd := range cmds {
+			run := schema.ActionRun(cmd)
+			list[i] = schema.Action{Run: &run}
+		}
+		return list
+	}
+	return &schema.File{Jobs: map[string]schema.Job{
+		"deploy": {
+			Actions:   runs(actions...),
+			OnFailure: runs("on-failure"),
+			Finally:   runs("finally"),
+		},
+	}}
+}
+
+func runCleanupPlan(t *testing.T, ctx context.Context, client *fakeClient, file *schema.File) *Result {
+	t.Helper()
+	e, _ := newTestExecutor(t, client)
+	plan := &schema.Plan{Steps: []schema.Step{{Job: "deploy", Targets: []string{"web"}}}}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+	result, _ := e.ExecutePlan(ctx, file, plan, "p", inv, nil, nil)
+	return result
+}
+
+func TestRunJob_FinallyAfterSuccess(t *testing.T) {
+	client := &fakeClient{}
+	result := runCleanupPlan(t, context.Background(), client, cleanupFile("migrate", "restart"))
+
+	if result.Failed {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	want := []string{"migrate", "restart", "finally"}
+	if got := client.hostCommands("web-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRunJob_CleanupAfterFailure(t *testing.T) {
+	var failedAction string
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch cmd {
+			case "restart":
+				return fmt.Errorf("exit status 1")
+			case "on-failure":
+				failedAction = env["HADES_FAILED_ACTION"]
+			}
+			return nil
+		},
+	}
+	result := runCleanupPlan(t, context.Background(), client, cleanupFile("migrate", "restart", "verify"))
+
+	if !result.Failed || result.FailedHost != "web-1" {
+		t.Fatalf("Expected web-1 to fail, got %+v", result)
+	}
+	want := []string{"migrate", "restart", "on-failure", "finally"}
+	if got := client.hostCommands("web-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if failedAction != "[1] run" {
+		t.Errorf("Expected HADES_FAILED_ACTION %q, got %q", "[1] run", failedAction)
+	}
+}
+
+func TestRunJob_FinallyAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	cleanupCtxErr := map[string]error{}
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch cmd {
+			case "migrate":
+				// Interrupted mid-action, as by Ctrl-C
+				cancel()
+				return ctx.Err()
+			case "on-failure", "finally":
+				mu.Lock()
+				cleanupCtxErr[cmd] = ctx.Err()
+				mu.Unlock()
+			}
+			return nil
+		},
+	}
+	result := runCleanupPlan(t, ctx, client, cleanupFile("migrate", "restart"))
+
+	if !result.Failed {
+		t.Fatal("Expected the cancelled run to fail")
+	}
+	want := []string{"migrate", "on-failure", "finally"}
+	if got := client.hostCommands("web-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	for cmd, err := range cleanupCtxErr {
+		if err != nil {
+			t.Errorf("Expected %s to run with a live context, got %v", cmd, err)
+		}
+	}
+}
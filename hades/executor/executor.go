@@ -2,14 +2,18 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/SoftKiwiGames/hades/hades/actions"
+	"github.com/SoftKiwiGames/hades/hades/approval"
 	"github.com/SoftKiwiGames/hades/hades/artifacts"
 	"github.com/SoftKiwiGames/hades/hades/inventory"
 	"github.com/SoftKiwiGames/hades/hades/loader"
@@ -26,6 +30,7 @@ import (
 type Executor interface {
 	ExecutePlan(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string) (*Result, error)
 	DryRun(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string) error
+	Rollback(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string) (*Result, error)
 }
 
 type Result struct {
@@ -36,18 +41,52 @@ type Result struct {
 	FailedStep string
 	FailedHost string
 	Error      error
+	Rollback   *RollbackResult // Set when the plan's on_failure ran
+}
+
+// RollbackResult describes the plan's on_failure run after a failed step
+type RollbackResult struct {
+	Name  string   // Job or plan that was run
+	Hosts []string // Hosts it was run against
+	Error error    // Set when the rollback itself failed
+}
+
+// hostError is a job failure on a specific host
+type hostError struct {
+	host string
+	err  error
+}
+
+func (e *hostError) Error() string {
+	return fmt.Sprintf("job failed on host %s: %v", e.host, e.err)
+}
+
+func (e *hostError) Unwrap() error {
+	return e.err
 }
 
 type executor struct {
 	sshClient ssh.Client
+	approver  approval.Approver
 	stdout    io.Writer
 	stderr    io.Writer
 	ui        *ui.Output
 }
 
-func New(sshClient ssh.Client, stdout, stderr io.Writer) Executor {
+// withOutput returns a copy of the executor that writes console output to
+// stdout and stderr instead
+func (e *executor) withOutput(stdout, stderr io.Writer) *executor {
+	c := *e
+	c.stdout = stdout
+	c.stderr = stderr
+	c.ui = ui.NewOutput(stdout, stderr)
+	return &c
+}
+
+func New(sshClient ssh.Client, approver approval.Approver, stdout, stderr io.Writer) Executor {
 	return &executor{
 		sshClient: sshClient,
+		approver:  approver,
 		stdout:    stdout,
 		stderr:    stderr,
 		ui:        ui.NewOutput(stdout, stderr),
@@ -76,133 +115,190 @@ func (e *executor) ExecutePlan(ctx context.Context, file *schema.File, plan *sch
 
 	e.ui.PlanStarted(planName, result.RunID)
 
-	// Execute each step sequentially
-	for i, step := range plan.Steps {
-		// Determine which targets to use: CLI overrides YAML
-		stepTargets := step.Targets
-		if len(targets) > 0 {
-			stepTargets = targets
-		}
+	// Hosts each step has started work on, for on_failure rollbacks
+	touched := make([][]ssh.Host, len(plan.Steps))
 
-		// Resolve all targets and deduplicate hosts
-		uniqueHosts := make(map[string]ssh.Host) // keyed by host name
-		for _, targetName := range stepTargets {
-			hosts, err := inv.ResolveTarget(targetName)
-			if err != nil {
-				result.Failed = true
-				result.FailedStep = step.Name
-				result.Error = fmt.Errorf("failed to resolve target %q: %w", targetName, err)
-				return result, result.Error
+	failed := -1
+	if plan.UsesNeeds() {
+		failed, err = e.executeGraph(ctx, file, plan, planName, inv, targets, env, result.RunID, artifactMgr, registryMgr, touched)
+	} else if plan.Strategy == schema.StrategyPipeline {
+		failed, err = e.executePipeline(ctx, file, plan, planName, inv, targets, env, result.RunID, artifactMgr, registryMgr, touched)
+	} else {
+		// Execute each step sequentially, matrix entries up to their
+		// matrix_parallelism at a time
+		for i := 0; i < len(plan.Steps); {
+			if n := matrixRun(plan.Steps, i); n > 1 {
+				if failed, err = e.executeMatrix(ctx, file, plan, planName, i, n, inv, targets, env, result.RunID, artifactMgr, registryMgr, touched); err != nil {
+					break
+				}
+				i += n
+				continue
 			}
-			// Add hosts to unique set
-			for _, host := range hosts {
-				uniqueHosts[host.Name] = host
+			touched[i], err = e.executeStep(ctx, file, plan, planName, i, inv, targets, env, result.RunID, artifactMgr, registryMgr)
+			if err != nil {
+				failed = i
+				break
 			}
+			i++
 		}
+	}
 
-		// Convert map to slice
-		var allHosts []ssh.Host
-		for _, host := range uniqueHosts {
-			allHosts = append(allHosts, host)
+	if err != nil {
+		result.Failed = true
+		result.FailedStep = plan.Steps[failed].ID()
+		var hostErr *hostError
+		if errors.As(err, &hostErr) {
+			result.FailedHost = hostErr.host
 		}
+		result.Error = err
 
-		// Apply limit if specified (canary)
-		if step.Limit > 0 && step.Limit < len(allHosts) {
-			allHosts = allHosts[:step.Limit]
+		if plan.OnFailure != nil {
+			result.Rollback = e.rollbackPlan(ctx, file, plan, planName, inv, targets, env, result, failed, touched, artifactMgr, registryMgr)
 		}
 
-		totalHosts := len(allHosts)
+		result.EndTime = time.Now()
+		e.ui.PlanFailed(result.FailedStep, result.FailedHost, result.Error)
+		if result.Rollback != nil {
+			e.ui.RollbackSummary(result.Rollback.Name, len(result.Rollback.Hosts), result.Rollback.Error)
+		}
+		return result, result.Error
+	}
 
-		e.ui.StepProgress(i+1, len(plan.Steps), step.Name)
-		e.ui.Info("  Job: %s", step.Job)
-		targetsStr := strings.Join(stepTargets, ", ")
-		e.ui.Info("  Targets: %s", targetsStr)
-		fmt.Fprintf(e.stdout, "  Hosts: %d\n", totalHosts)
-		fmt.Fprintf(e.stdout, "  Status: %s□%s Started\n", ctc.ForegroundYellow, ctc.Reset)
-		fmt.Fprintf(e.stdout, "  Started: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+	result.EndTime = time.Now()
+	e.ui.PlanCompleted(result.EndTime.Sub(result.StartTime))
 
-		// Load job once for this step
-		job, err := e.loadJob(file, step.Job)
-		if err != nil {
-			result.Failed = true
-			result.FailedStep = step.Name
-			result.Error = err
-			return result, result.Error
-		}
+	return result, nil
+}
 
-		// Merge env with priority: CLI > step > plan > job defaults
-		stepEnv := make(map[string]string)
+// preparedStep is a plan step with its targets, hosts, job, env and tunnel
+// resolved
+type preparedStep struct {
+	targets []string
+	hosts   []ssh.Host
+	job     *schema.Job
+	env     map[string]string
+	tunnel  *tunnelSpec
+}
 
-		// Start with plan-level env
-		for k, v := range plan.Env {
-			stepEnv[k] = v
-		}
+// prepareStep resolves what a step runs and where, and registers the job's
+// artifacts
+func (e *executor) prepareStep(file *schema.File, plan *schema.Plan, step *schema.Step, inv inventory.Inventory, targets []string, env map[string]string, artifactMgr artifacts.Manager) (*preparedStep, error) {
+	// Determine which targets to use: CLI overrides YAML
+	stepTargets := step.Targets
+	if len(targets) > 0 {
+		stepTargets = targets
+	}
 
-		// Step env overrides plan
-		for k, v := range step.Env {
-			stepEnv[k] = v
-		}
+	// Resolve all targets and deduplicate hosts
+	hosts, err := resolveHosts(inv, stepTargets)
+	if err != nil {
+		return nil, err
+	}
 
-		// CLI overrides everything
-		for k, v := range env {
-			stepEnv[k] = v
-		}
+	// Apply limit if specified (canary)
+	if step.Limit > 0 && step.Limit < len(hosts) {
+		hosts = hosts[:step.Limit]
+	}
 
-		// Merge with job defaults
-		mergedEnv := loader.MergeEnv(job, stepEnv)
+	// Load job once for this step
+	job, err := e.loadJob(file, step.Job)
+	if err != nil {
+		return nil, err
+	}
 
-		// Register artifacts for this job (loaded lazily when accessed)
-		e.loadArtifacts(job, artifactMgr)
+	// Resolve the tunnel host once; the local jobs open forwards through it
+	tunnel, err := resolveTunnel(inv, step, job)
+	if err != nil {
+		return nil, err
+	}
 
-		// Execute all unique hosts
-		// Parse rollout strategy
-		strategy, err := rollout.ParseStrategy(step.Parallelism, len(allHosts))
-		if err != nil {
-			result.Failed = true
-			result.FailedStep = step.Name
-			result.Error = fmt.Errorf("invalid parallelism: %w", err)
-			return result, result.Error
-		}
-		strategy.Limit = step.Limit
+	// Register artifacts for this job (loaded lazily when accessed)
+	e.loadArtifacts(file, job, artifactMgr)
+
+	return &preparedStep{
+		targets: stepTargets,
+		hosts:   hosts,
+		job:     job,
+		// Host vars and job defaults are merged in per host
+		env:    mergeStepEnv(plan, step, env),
+		tunnel: tunnel,
+	}, nil
+}
 
-		// Create batches based on strategy
-		batches := strategy.CreateBatches(allHosts)
+// executeStep runs one plan step and returns the hosts it started work on,
+// including those of a failed batch
+func (e *executor) executeStep(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, i int, inv inventory.Inventory, targets []string, env map[string]string, runID string, artifactMgr artifacts.Manager, registryMgr registry.Manager) ([]ssh.Host, error) {
+	step := plan.Steps[i]
 
-		// Use first target name for logging (legacy compatibility)
-		targetName := stepTargets[0]
+	prepared, err := e.prepareStep(file, plan, &step, inv, targets, env, artifactMgr)
+	if err != nil {
+		return nil, err
+	}
 
-		// Execute batches sequentially, hosts within batch in parallel
-		for batchIdx, batch := range batches {
-			if len(batches) > 1 {
-				fmt.Fprintf(e.stdout, "  Batch %d/%d (%d hosts)\n", batchIdx+1, len(batches), len(batch))
-			}
+	e.ui.StepProgress(i+1, len(plan.Steps), step.Name)
+	e.ui.Info("  Job: %s", step.Job)
+	targetsStr := strings.Join(prepared.targets, ", ")
+	e.ui.Info("  Targets: %s", targetsStr)
+	fmt.Fprintf(e.stdout, "  Hosts: %d\n", len(prepared.hosts))
+	if prepared.tunnel != nil {
+		e.ui.Info("  Tunnel: %s via %s", prepared.tunnel.remote, prepared.tunnel.host.Name)
+	}
+	fmt.Fprintf(e.stdout, "  Status: %s□%s Started\n", ctc.ForegroundYellow, ctc.Reset)
+	fmt.Fprintf(e.stdout, "  Started: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	// Execute all unique hosts
+	// Parse rollout strategy
+	parallelism := step.Parallelism
+	if parallelism == "" {
+		parallelism = plan.Parallelism
+	}
+	strategy, err := rollout.ParseStrategy(parallelism, len(prepared.hosts))
+	if err != nil {
+		return nil, fmt.Errorf("invalid parallelism: %w", err)
+	}
+	strategy.Limit = step.Limit
 
-			// Execute batch in parallel
-			if err := e.executeBatch(ctx, job, step.Job, result.RunID, planName, targetName, batch, mergedEnv, artifactMgr, registryMgr); err != nil {
-				result.Failed = true
-				result.FailedStep = step.Name
-				result.Error = err
-				fmt.Fprintf(e.stderr, "\n  Status: %s■%s Failed\n\n", ctc.ForegroundRed, ctc.Reset)
-				result.EndTime = time.Now()
-				return result, result.Error
-			}
+	// Create batches based on strategy
+	batches := strategy.CreateBatches(prepared.hosts)
 
-			if len(batches) > 1 {
-				fmt.Fprintf(e.stdout, "  ✓ Batch %d/%d completed\n", batchIdx+1, len(batches))
-			}
+	// Use first target name for logging (legacy compatibility)
+	targetName := prepared.targets[0]
+
+	// Execute batches sequentially, hosts within batch in parallel
+	var touched []ssh.Host
+	for batchIdx, batch := range batches {
+		if len(batches) > 1 {
+			fmt.Fprintf(e.stdout, "  Batch %d/%d (%d hosts)\n", batchIdx+1, len(batches), len(batch))
 		}
 
-		// Step completion
-		fmt.Fprintf(e.stdout, "\n  Status: %s■%s Completed\n\n", ctc.ForegroundGreen, ctc.Reset)
-	}
+		// Approval gates are answered once per batch, not once per host
+		scope := fmt.Sprintf("Step %d/%d", i+1, len(plan.Steps))
+		if step.Name != "" {
+			scope = fmt.Sprintf("Step %q", step.Name)
+		}
+		if len(batches) > 1 {
+			scope += fmt.Sprintf(", batch %d/%d", batchIdx+1, len(batches))
+		}
+		gate := approval.NewGate(e.approver, scope)
 
-	result.EndTime = time.Now()
-	e.ui.PlanCompleted(result.EndTime.Sub(result.StartTime))
+		// Execute batch in parallel
+		touched = append(touched, batch...)
+		if err := e.executeBatch(ctx, file, prepared.job, step.Job, runID, planName, targetName, batch, prepared.env, artifactMgr, registryMgr, gate, prepared.tunnel); err != nil {
+			fmt.Fprintf(e.stderr, "\n  Status: %s■%s Failed\n\n", ctc.ForegroundRed, ctc.Reset)
+			return touched, err
+		}
 
-	return result, nil
+		if len(batches) > 1 {
+			fmt.Fprintf(e.stdout, "  ✓ Batch %d/%d completed\n", batchIdx+1, len(batches))
+		}
+	}
+
+	// Step completion
+	fmt.Fprintf(e.stdout, "\n  Status: %s■%s Completed\n\n", ctc.ForegroundGreen, ctc.Reset)
+	return touched, nil
 }
 
-func (e *executor) executeBatch(ctx context.Context, job *schema.Job, jobName string, runID string, plan string, target string, hosts []ssh.Host, env map[string]string, artifactMgr artifacts.Manager, registryMgr registry.Manager) error {
+func (e *executor) executeBatch(ctx context.Context, file *schema.File, job *schema.Job, jobName string, runID string, plan string, target string, hosts []ssh.Host, env map[string]string, artifactMgr artifacts.Manager, registryMgr registry.Manager, gate approval.Approver, tunnel *tunnelSpec) error {
 	// Use channels to coordinate parallel execution
 	type result struct {
 		host ssh.Host
@@ -218,7 +314,7 @@ func (e *executor) executeBatch(ctx context.Context, job *schema.Job, jobName st
 		go func(h ssh.Host) {
 			defer wg.Done()
 
-			err := e.executeJob(ctx, job, jobName, runID, plan, target, h, env, artifactMgr, registryMgr)
+			err := e.executeJob(ctx, file, job, jobName, runID, plan, target, h, env, artifactMgr, registryMgr, gate, tunnel)
 
 			if err != nil {
 				fmt.Fprintf(e.stderr, "[%s] %s◆%s Job %q: failed - %v\n", h.Name, ctc.ForegroundRed, ctc.Reset, jobName, err)
@@ -240,14 +336,14 @@ func (e *executor) executeBatch(ctx context.Context, job *schema.Job, jobName st
 	for res := range resultChan {
 		if res.err != nil {
 			// Abort on first failure
-			return fmt.Errorf("job failed on host %s: %w", res.host.Name, res.err)
+			return &hostError{host: res.host.Name, err: res.err}
 		}
 	}
 
 	return nil
 }
 
-func (e *executor) executeJob(ctx context.Context, job *schema.Job, jobName string, runID string, plan string, target string, host ssh.Host, env map[string]string, artifactMgr artifacts.Manager, registryMgr registry.Manager) error {
+func (e *executor) executeJob(ctx context.Context, file *schema.File, job *schema.Job, jobName string, runID string, plan string, target string, host ssh.Host, env map[string]string, artifactMgr artifacts.Manager, registryMgr registry.Manager, gate approval.Approver, tunnel *tunnelSpec) error {
 	// Create logger for this host
 	hostLogger, err := logger.New(runID, plan, host.Name, e.stdout, e.stderr)
 	if err != nil {
@@ -256,15 +352,26 @@ func (e *executor) executeJob(ctx context.Context, job *schema.Job, jobName stri
 	defer hostLogger.Close()
 
 	// Determine which client to use: local or SSH
-	var client ssh.Client
-	if job.Local {
-		client = ssh.NewLocalClient(job.SourceDir)
-	} else {
-		client = e.sshClient
-	}
+	client := e.clientFor(job)
+
+	// Merge env with priority: provided > host vars > job defaults
+	env = loader.HostEnv(job, host.Vars, env)
 
 	// Create runtime context with logger writers and console writers
 	runtime := types.NewRuntime(client, artifactMgr, registryMgr, runID, plan, target, host, env, hostLogger.Stdout(), hostLogger.Stderr(), e.stdout, e.stderr, job.SourceDir)
+	runtime.Approver = gate
+
+	// Open the tunnel before the guard so guards can probe through it too
+	if tunnel != nil {
+		fwd, release, err := tunnel.open(ctx, e.sshClient)
+		if err != nil {
+			return fmt.Errorf("failed to open tunnel to %s via %s: %w", tunnel.remote, tunnel.host.Name, err)
+		}
+		defer release()
+
+		runtime.Env["HADES_TUNNEL_PORT"] = strconv.Itoa(fwd.Port())
+		fmt.Fprintf(hostLogger.Stdout(), "Tunnel: 127.0.0.1:%d -> %s via %s\n", fwd.Port(), tunnel.remote, tunnel.host.Name)
+	}
 
 	// Evaluate guard condition first (before showing job starting)
 	if job.Guard != nil {
@@ -283,37 +390,128 @@ func (e *executor) executeJob(ctx context.Context, job *schema.Job, jobName stri
 	// Console: Job starting (only if guard passed or no guard)
 	fmt.Fprintf(e.stdout, "[%s] %s◇%s Job %q: starting\n", host.Name, ctc.ForegroundYellow, ctc.Reset, jobName)
 
-	// Execute each action sequentially
-	for i, actionSchema := range job.Actions {
+	return e.runJob(ctx, file, job, jobName, runtime, hostLogger, "")
+}
+
+// runJob executes a job's actions, then the handlers they notified if all of
+// them succeeded, then its on_failure actions if one of them failed, then its
+// finally actions. Cleanup runs even when ctx is cancelled so that a drained
+// host or maintenance flag is not left behind; the job's own error is returned
+// in preference to a cleanup error.
+func (e *executor) runJob(ctx context.Context, file *schema.File, job *schema.Job, jobPath string, runtime *types.Runtime, hostLogger *logger.Logger, prefix string) error {
+	notified := make(map[string]bool)
+	err := e.runActions(ctx, file, job.Actions, jobPath, runtime, hostLogger, prefix, notified)
+	if err == nil {
+		err = e.runHandlers(ctx, file, job, jobPath, runtime, hostLogger, prefix, notified)
+	}
+	if len(job.OnFailure) == 0 && len(job.Finally) == 0 {
+		return err
+	}
+
+	cleanupCtx := context.WithoutCancel(ctx)
+	host := runtime.Host.Name
+
+	if err != nil && len(job.OnFailure) > 0 {
+		fmt.Fprintf(e.stdout, "[%s] %s◇%s Job %q: running on_failure actions\n", host, ctc.ForegroundYellow, ctc.Reset, jobPath)
+		runtime.Env["HADES_FAILED_ACTION"] = runtime.ActionDesc
+		if cleanupErr := e.runActions(cleanupCtx, file, job.OnFailure, jobPath+" (on_failure)", runtime, hostLogger, prefix+"on_failure.", nil); cleanupErr != nil {
+			fmt.Fprintf(e.stderr, "[%s] %s◇%s Job %q: on_failure failed - %v\n", host, ctc.ForegroundRed, ctc.Reset, jobPath, cleanupErr)
+		}
+		delete(runtime.Env, "HADES_FAILED_ACTION")
+	}
+
+	if len(job.Finally) > 0 {
+		fmt.Fprintf(e.stdout, "[%s] %s◇%s Job %q: running finally actions\n", host, ctc.ForegroundYellow, ctc.Reset, jobPath)
+		if cleanupErr := e.runActions(cleanupCtx, file, job.Finally, jobPath+" (finally)", runtime, hostLogger, prefix+"finally.", nil); cleanupErr != nil {
+			fmt.Fprintf(e.stderr, "[%s] %s◇%s Job %q: finally failed - %v\n", host, ctc.ForegroundRed, ctc.Reset, jobPath, cleanupErr)
+			if err == nil {
+				err = fmt.Errorf("finally: %w", cleanupErr)
+			}
+		}
+	}
+
+	return err
+}
+
+// runHandlers runs the notified handlers of a job once each, in the order
+// they are declared
+func (e *executor) runHandlers(ctx context.Context, file *schema.File, job *schema.Job, jobPath string, runtime *types.Runtime, hostLogger *logger.Logger, prefix string, notified map[string]bool) error {
+	var handlers []schema.Action
+	var names []string
+	for _, handler := range job.Handlers {
+		if notified[handler.Name] {
+			handlers = append(handlers, handler)
+			names = append(names, handler.Name)
+		}
+	}
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(e.stdout, "[%s] %s◇%s Job %q: running handlers (%s)\n", runtime.Host.Name, ctc.ForegroundYellow, ctc.Reset, jobPath, strings.Join(names, ", "))
+	return e.runActions(ctx, file, handlers, jobPath+" (handlers)", runtime, hostLogger, prefix+"handlers.", nil)
+}
+
+// runActions executes a list of actions sequentially. Included jobs run
+// through here too, with jobPath showing the nesting ("deploy > install-caddy")
+// and prefix numbering their actions after the include ("[2.0]"). Handlers
+// notified by actions that changed something are added to notified, if set.
+func (e *executor) runActions(ctx context.Context, file *schema.File, actionList []schema.Action, jobPath string, runtime *types.Runtime, hostLogger *logger.Logger, prefix string, notified map[string]bool) error {
+	host := runtime.Host
+
+	for i, actionSchema := range actionList {
+		// Stop starting new actions once the run is cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// Get action type for delimiter
 		actionType := getActionType(&actionSchema)
 
 		// Format action description for console
-		actionDesc := fmt.Sprintf("[%d] %s", i, actionType)
+		index := fmt.Sprintf("%s%d", prefix, i)
+		actionDesc := fmt.Sprintf("[%s] %s", index, actionType)
 		if actionSchema.Name != "" {
-			actionDesc = fmt.Sprintf("[%d] %s (%s)", i, actionType, actionSchema.Name)
+			actionDesc = fmt.Sprintf("[%s] %s (%s)", index, actionType, actionSchema.Name)
+		} else if actionSchema.IncludeJob != nil {
+			actionDesc = fmt.Sprintf("[%s] %s (%s)", index, actionType, actionSchema.IncludeJob.Job)
 		}
 
 		// Set action description in runtime for use by actions
 		runtime.ActionDesc = actionDesc
 
 		// Write delimiter to log (with optional name)
-		if err := hostLogger.WriteJobDelimiter(jobName, actionType, actionSchema.Name, i); err != nil {
+		if err := hostLogger.WriteJobDelimiter(jobPath, actionType, actionSchema.Name, i); err != nil {
 			return fmt.Errorf("failed to write log delimiter: %w", err)
 		}
 
 		// Console: Action starting
 		fmt.Fprintf(e.stdout, "[%s] %s◌%s Action %s: in progress\n", host.Name, ctc.ForegroundYellow, ctc.Reset, actionDesc)
 
+		if actionSchema.IncludeJob != nil {
+			if err := e.includeJob(ctx, file, actionSchema.IncludeJob, jobPath, runtime, hostLogger, index+"."); err != nil {
+				fmt.Fprintf(e.stderr, "[%s] %s●%s Action %s: failed - %v\n", host.Name, ctc.ForegroundRed, ctc.Reset, actionDesc, err)
+				return fmt.Errorf("action %s failed: %w", index, err)
+			}
+			fmt.Fprintf(e.stdout, "[%s] %s●%s Action %s: completed\n", host.Name, ctc.ForegroundGreen, ctc.Reset, actionDesc)
+			continue
+		}
+
 		action, err := e.createAction(&actionSchema, hostLogger)
 		if err != nil {
-			return fmt.Errorf("action %d: %w", i, err)
+			return fmt.Errorf("action %s: %w", index, err)
 		}
 
+		runtime.Skipped = false
 		if err := action.Execute(ctx, runtime); err != nil {
 			// Console: Action failed
 			fmt.Fprintf(e.stderr, "[%s] %s●%s Action %s: failed - %v\n", host.Name, ctc.ForegroundRed, ctc.Reset, actionDesc, err)
-			return fmt.Errorf("action %d failed: %w", i, err)
+			return fmt.Errorf("action %s failed: %w", index, err)
+		}
+		if notified != nil && !runtime.Skipped {
+			for _, name := range actionSchema.Notify {
+				notified[name] = true
+			}
 		}
 
 		// Console: Action completed
@@ -323,6 +521,62 @@ func (e *executor) executeJob(ctx context.Context, job *schema.Job, jobName stri
 	return nil
 }
 
+// includeJob runs an included job's actions on the caller's host, with the
+// env built from the caller's variables and the include's overrides
+func (e *executor) includeJob(ctx context.Context, file *schema.File, inc *schema.ActionIncludeJob, callerPath string, caller *types.Runtime, hostLogger *logger.Logger, prefix string) error {
+	included, err := e.loadJob(file, inc.Job)
+	if err != nil {
+		return err
+	}
+	jobPath := callerPath + " > " + inc.Job
+
+	runtime, err := includeRuntime(included, inc, caller)
+	if err != nil {
+		return err
+	}
+	runtime.SSHClient = e.clientFor(included)
+
+	if included.Guard != nil {
+		result, err := actions.EvaluateGuard(ctx, included.Guard, runtime)
+		if err != nil {
+			return fmt.Errorf("guard evaluation failed: %w", err)
+		}
+		if !result.Pass {
+			fmt.Fprintf(e.stdout, "[%s] %s◇%s Job %q: skipped (guard failed)\n", runtime.Host.Name, ctc.ForegroundBlue, ctc.Reset, jobPath)
+			return nil
+		}
+	}
+
+	return e.runJob(ctx, file, included, jobPath, runtime, hostLogger, prefix)
+}
+
+// includeRuntime derives the runtime of an included job from its caller's.
+// HADES_* built-ins carry over; other variables follow the included job's
+// own contract.
+func includeRuntime(included *schema.Job, inc *schema.ActionIncludeJob, caller *types.Runtime) (*types.Runtime, error) {
+	overrides := make(map[string]string)
+	for name, value := range inc.Env {
+		overrides[name] = actions.ExpandEnvVars(value, caller.Env)
+	}
+
+	provided := loader.IncludeEnv(included, caller.Env, overrides)
+	if err := loader.ValidateEnvContract(included, provided); err != nil {
+		return nil, fmt.Errorf("include_job %q: %w", inc.Job, err)
+	}
+
+	env := loader.MergeEnv(included, provided)
+	for name, value := range caller.Env {
+		if strings.HasPrefix(name, "HADES_") {
+			env[name] = value
+		}
+	}
+
+	runtime := *caller
+	runtime.Env = env
+	runtime.SourceDir = included.SourceDir
+	return &runtime, nil
+}
+
 func (e *executor) createAction(actionSchema *schema.Action, planLogger *logger.Logger) (actions.Action, error) {
 	if actionSchema.Run != nil {
 		return actions.NewRunAction(actionSchema.Run), nil
@@ -351,6 +605,36 @@ func (e *executor) createAction(actionSchema *schema.Action, planLogger *logger.
 	if actionSchema.Gpg != nil {
 		return actions.NewGpgAction(actionSchema.Gpg), nil
 	}
+	if actionSchema.Download != nil {
+		return actions.NewDownloadAction(actionSchema.Download), nil
+	}
+	if actionSchema.Unarchive != nil {
+		return actions.NewUnarchiveAction(actionSchema.Unarchive), nil
+	}
+	if actionSchema.Release != nil {
+		return actions.NewReleaseAction(actionSchema.Release), nil
+	}
+	if actionSchema.Service != nil {
+		return actions.NewServiceAction(actionSchema.Service), nil
+	}
+	if actionSchema.Package != nil {
+		return actions.NewPackageAction(actionSchema.Package), nil
+	}
+	if actionSchema.LineInFile != nil {
+		return actions.NewLineInFileAction(actionSchema.LineInFile), nil
+	}
+	if actionSchema.BlockInFile != nil {
+		return actions.NewBlockInFileAction(actionSchema.BlockInFile), nil
+	}
+	if actionSchema.User != nil {
+		return actions.NewUserAction(actionSchema.User), nil
+	}
+	if actionSchema.AuthorizedKey != nil {
+		return actions.NewAuthorizedKeyAction(actionSchema.AuthorizedKey), nil
+	}
+	if actionSchema.WaitFor != nil {
+		return actions.NewWaitForAction(actionSchema.WaitFor), nil
+	}
 
 	return nil, fmt.Errorf("no action type specified")
 }
@@ -384,10 +668,212 @@ func getActionType(actionSchema *schema.Action) string {
 	if actionSchema.Gpg != nil {
 		return "gpg"
 	}
+	if actionSchema.Download != nil {
+		return "download"
+	}
+	if actionSchema.Unarchive != nil {
+		return "unarchive"
+	}
+	if actionSchema.Release != nil {
+		return "release"
+	}
+	if actionSchema.Service != nil {
+		return "service"
+	}
+	if actionSchema.Package != nil {
+		return "package"
+	}
+	if actionSchema.LineInFile != nil {
+		return "lineinfile"
+	}
+	if actionSchema.BlockInFile != nil {
+		return "blockinfile"
+	}
+	if actionSchema.User != nil {
+		return "user"
+	}
+	if actionSchema.AuthorizedKey != nil {
+		return "authorized_key"
+	}
+	if actionSchema.WaitFor != nil {
+		return "wait_for"
+	}
+	if actionSchema.IncludeJob != nil {
+		return "include_job"
+	}
 	return "unknown"
 }
 
-func (e *executor) loadArtifacts(job *schema.Job, artifactMgr artifacts.Manager) {
+// resolveHosts resolves target names to a deduplicated host list, keeping the
+// order in which hosts were first seen
+func resolveHosts(inv inventory.Inventory, targets []string) ([]ssh.Host, error) {
+	var hosts []ssh.Host
+	index := make(map[string]int)
+	from := make(map[string]map[string]string) // Target each host var came from
+	for _, targetName := range targets {
+		targetHosts, err := inv.ResolveTarget(targetName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve target %q: %w", targetName, err)
+		}
+		for _, host := range targetHosts {
+			i, seen := index[host.Name]
+			if !seen {
+				index[host.Name] = len(hosts)
+				from[host.Name] = make(map[string]string, len(host.Vars))
+				for name := range host.Vars {
+					from[host.Name][name] = targetName
+				}
+				hosts = append(hosts, host)
+				continue
+			}
+
+			// A host in several targets gets the vars of all of them
+			vars := maps.Clone(hosts[i].Vars)
+			if vars == nil {
+				vars = make(map[string]string)
+			}
+			for name, value := range host.Vars {
+				if existing, ok := vars[name]; ok {
+					if existing != value {
+						return nil, fmt.Errorf("host %q gets conflicting values for var %q from targets %q and %q", host.Name, name, from[host.Name][name], targetName)
+					}
+					continue
+				}
+				vars[name] = value
+				from[host.Name][name] = targetName
+			}
+			hosts[i].Vars = vars
+		}
+	}
+	return hosts, nil
+}
+
+// HostVars returns a loader.HostVarsFunc resolving the hosts of a step the
+// same way a run does: CLI targets override the step's, then limit applies
+func HostVars(inv inventory.Inventory, targets []string) loader.HostVarsFunc {
+	return func(step *schema.Step) (map[string]map[string]string, error) {
+		stepTargets := step.Targets
+		if len(targets) > 0 {
+			stepTargets = targets
+		}
+		hosts, err := resolveHosts(inv, stepTargets)
+		if err != nil {
+			return nil, err
+		}
+		if step.Limit > 0 && step.Limit < len(hosts) {
+			hosts = hosts[:step.Limit]
+		}
+
+		vars := make(map[string]map[string]string, len(hosts))
+		for _, host := range hosts {
+			vars[host.Name] = host.Vars
+		}
+		return vars, nil
+	}
+}
+
+// tunnelSpec is a tunnel directive with its host resolved from the inventory
+type tunnelSpec struct {
+	host      ssh.Host
+	remote    string
+	localPort int
+
+	// A fixed local port can only be bound once, so the jobs of a step
+	// running at the same time share one forward
+	mu    sync.Mutex
+	fwd   *ssh.Forward
+	users int
+}
+
+// open returns a forward for one job and a func releasing it. Without a fixed
+// local port each job gets its own; otherwise the forward is shared and closed
+// when the last job using it releases it.
+func (t *tunnelSpec) open(ctx context.Context, client ssh.Client) (*ssh.Forward, func(), error) {
+	if t.localPort == 0 {
+		fwd, err := client.Forward(ctx, t.host, t.remote, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fwd, func() { fwd.Close() }, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fwd == nil {
+		fwd, err := client.Forward(ctx, t.host, t.remote, t.localPort)
+		if err != nil {
+			return nil, nil, err
+		}
+		t.fwd = fwd
+	}
+	t.users++
+
+	fwd := t.fwd
+	return fwd, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.users--; t.users == 0 {
+			t.fwd.Close()
+			t.fwd = nil
+		}
+	}, nil
+}
+
+// resolveTunnel returns the tunnel for a step; a step tunnel overrides the
+// job's own. It returns nil when neither defines one.
+func resolveTunnel(inv inventory.Inventory, step *schema.Step, job *schema.Job) (*tunnelSpec, error) {
+	tunnel := job.Tunnel
+	if step.Tunnel != nil {
+		tunnel = step.Tunnel
+	}
+	if tunnel == nil {
+		return nil, nil
+	}
+
+	for _, host := range inv.AllHosts() {
+		if host.Name == tunnel.Host {
+			return &tunnelSpec{host: host, remote: tunnel.Remote, localPort: tunnel.LocalPort}, nil
+		}
+	}
+	return nil, fmt.Errorf("tunnel host %q not found in inventory", tunnel.Host)
+}
+
+// mergeStepEnv merges env with priority: matrix values > CLI > step > plan
+func mergeStepEnv(plan *schema.Plan, step *schema.Step, cliEnv map[string]string) map[string]string {
+	stepEnv := make(map[string]string)
+
+	// Start with plan-level env
+	for k, v := range plan.Env {
+		stepEnv[k] = v
+	}
+
+	// Step env overrides plan
+	for k, v := range step.Env {
+		stepEnv[k] = v
+	}
+
+	// CLI overrides step and plan
+	for k, v := range cliEnv {
+		stepEnv[k] = v
+	}
+
+	// A matrix entry's values tell it apart from the other entries
+	for k, v := range step.MatrixEnv {
+		stepEnv[k] = v
+	}
+
+	return stepEnv
+}
+
+// clientFor returns the client used to run a job: local or SSH
+func (e *executor) clientFor(job *schema.Job) ssh.Client {
+	if job.Local {
+		return ssh.NewLocalClient(job.SourceDir)
+	}
+	return e.sshClient
+}
+
+func (e *executor) loadArtifacts(file *schema.File, job *schema.Job, artifactMgr artifacts.Manager) {
 	// Register artifacts defined in the job (loaded lazily on first access)
 	for name, artifact := range job.Artifacts {
 		path := artifact.Path
@@ -396,6 +882,17 @@ func (e *executor) loadArtifacts(job *schema.Job, artifactMgr artifacts.Manager)
 		}
 		artifactMgr.Register(name, path)
 	}
+
+	// Included jobs may copy their own artifacts; include cycles are rejected
+	// by the loader, so this recursion terminates
+	for _, action := range job.AllActions() {
+		if action.IncludeJob == nil {
+			continue
+		}
+		if included, ok := file.Jobs[action.IncludeJob.Job]; ok {
+			e.loadArtifacts(file, &included, artifactMgr)
+		}
+	}
 }
 
 func (e *executor) loadJob(file *schema.File, name string) (*schema.Job, error) {
@@ -418,6 +915,26 @@ func (e *executor) DryRun(ctx context.Context, file *schema.File, plan *schema.P
 
 	e.ui.DryRunHeader(planName)
 
+	if plan.Strategy == schema.StrategyPipeline {
+		parallelism := plan.Parallelism
+		if parallelism == "" {
+			parallelism = "all hosts"
+		}
+		fmt.Fprintf(e.stdout, "Strategy: pipeline (in flight: %s)\n\n", parallelism)
+	}
+
+	if plan.OnFailure != nil {
+		rollback := "job " + plan.OnFailure.Job
+		if plan.OnFailure.Plan != "" {
+			rollback = "plan " + plan.OnFailure.Plan
+		}
+		scope := plan.OnFailure.Scope
+		if scope == "" {
+			scope = "step"
+		}
+		fmt.Fprintf(e.stdout, "On failure: %s (scope: %s)\n\n", rollback, scope)
+	}
+
 	// Iterate steps
 	for i, step := range plan.Steps {
 		// Determine which targets to use: CLI overrides YAML
@@ -428,25 +945,18 @@ func (e *executor) DryRun(ctx context.Context, file *schema.File, plan *schema.P
 
 		fmt.Fprintf(e.stdout, "Step %d: %s\n", i+1, step.Name)
 		fmt.Fprintf(e.stdout, "  Job: %s\n", step.Job)
+		if len(step.Needs) > 0 {
+			fmt.Fprintf(e.stdout, "  Needs: %s\n", strings.Join(step.Needs, ", "))
+		}
+		if step.MatrixParallel > 1 {
+			fmt.Fprintf(e.stdout, "  Matrix: %s (up to %d entries at a time)\n", step.MatrixOf, step.MatrixParallel)
+		}
 		fmt.Fprintf(e.stdout, "  Targets: %s\n", strings.Join(stepTargets, ", "))
 
 		// Resolve hosts and deduplicate
-		uniqueHosts := make(map[string]ssh.Host) // keyed by host name
-		for _, targetName := range stepTargets {
-			targetHosts, err := inv.ResolveTarget(targetName)
-			if err != nil {
-				return fmt.Errorf("failed to resolve target %q: %w", targetName, err)
-			}
-			// Add hosts to unique set
-			for _, host := range targetHosts {
-				uniqueHosts[host.Name] = host
-			}
-		}
-
-		// Convert map to slice
-		var hosts []ssh.Host
-		for _, host := range uniqueHosts {
-			hosts = append(hosts, host)
+		hosts, err := resolveHosts(inv, stepTargets)
+		if err != nil {
+			return err
 		}
 
 		if step.Limit > 0 && step.Limit < len(hosts) {
@@ -459,46 +969,38 @@ func (e *executor) DryRun(ctx context.Context, file *schema.File, plan *schema.P
 			return err
 		}
 
-		// Merge env with priority: CLI > step > plan > job defaults
-		stepEnv := make(map[string]string)
-
-		// Start with plan-level env
-		for k, v := range plan.Env {
-			stepEnv[k] = v
-		}
+		// Merge env with priority: matrix values > CLI > step > plan; host vars
+		// and job defaults are merged in per host, for the variables the job
+		// declares
+		stepEnv := mergeStepEnv(plan, &step, env)
 
-		// Step env overrides plan
-		for k, v := range step.Env {
-			stepEnv[k] = v
+		tunnel, err := resolveTunnel(inv, &step, job)
+		if err != nil {
+			return err
 		}
-
-		// CLI overrides everything
-		for k, v := range env {
-			stepEnv[k] = v
+		if tunnel != nil {
+			fmt.Fprintf(e.stdout, "  Tunnel: %s via %s\n", tunnel.remote, tunnel.host.Name)
 		}
 
-		// Merge with job defaults
-		mergedEnv := loader.MergeEnv(job, stepEnv)
-
 		// Show actions for each host
 		for _, host := range hosts {
-			// Determine which client to use: local or SSH
-			var client ssh.Client
-			if job.Local {
-				client = ssh.NewLocalClient(job.SourceDir)
-			} else {
-				client = e.sshClient
+			mergedEnv := loader.HostEnv(job, host.Vars, stepEnv)
+			if tunnel != nil {
+				// A free port is only chosen once the forward is open
+				mergedEnv["HADES_TUNNEL_PORT"] = "<auto>"
+				if tunnel.localPort > 0 {
+					mergedEnv["HADES_TUNNEL_PORT"] = strconv.Itoa(tunnel.localPort)
+				}
 			}
 
+			// Determine which client to use: local or SSH
+			client := e.clientFor(job)
+
 			runtime := types.NewRuntime(client, artifactMgr, registryMgr, "dry-run", planName, stepTargets[0], host, mergedEnv, e.stdout, e.stderr, e.stdout, e.stderr, job.SourceDir)
 
 			fmt.Fprintf(e.stdout, "\n  [%s]\n", host.Name)
-			for _, actionSchema := range job.Actions {
-				action, err := e.createAction(&actionSchema, nil)
-				if err != nil {
-					return err
-				}
-				fmt.Fprintf(e.stdout, "    - %s\n", action.DryRun(ctx, runtime))
+			if err := e.dryRunJob(ctx, file, job, runtime, "    "); err != nil {
+				return err
 			}
 		}
 
@@ -507,3 +1009,65 @@ func (e *executor) DryRun(ctx context.Context, file *schema.File, plan *schema.P
 
 	return nil
 }
+
+// dryRunJob prints a job's actions followed by its on_failure and finally
+// sections
+func (e *executor) dryRunJob(ctx context.Context, file *schema.File, job *schema.Job, runtime *types.Runtime, indent string) error {
+	if err := e.dryRunActions(ctx, file, job.Actions, runtime, indent); err != nil {
+		return err
+	}
+	if len(job.Handlers) > 0 {
+		fmt.Fprintf(e.stdout, "%shandlers (when notified):\n", indent)
+		if err := e.dryRunActions(ctx, file, job.Handlers, runtime, indent+"  "); err != nil {
+			return err
+		}
+	}
+	if len(job.OnFailure) > 0 {
+		fmt.Fprintf(e.stdout, "%son_failure:\n", indent)
+		if err := e.dryRunActions(ctx, file, job.OnFailure, runtime, indent+"  "); err != nil {
+			return err
+		}
+	}
+	if len(job.Finally) > 0 {
+		fmt.Fprintf(e.stdout, "%sfinally:\n", indent)
+		if err := e.dryRunActions(ctx, file, job.Finally, runtime, indent+"  "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dryRunActions prints a list of actions, indenting included jobs under their
+// include_job action
+func (e *executor) dryRunActions(ctx context.Context, file *schema.File, actionList []schema.Action, runtime *types.Runtime, indent string) error {
+	for _, actionSchema := range actionList {
+		if actionSchema.IncludeJob != nil {
+			fmt.Fprintf(e.stdout, "%s- include_job: %s\n", indent, actionSchema.IncludeJob.Job)
+
+			included, err := e.loadJob(file, actionSchema.IncludeJob.Job)
+			if err != nil {
+				return err
+			}
+			child, err := includeRuntime(included, actionSchema.IncludeJob, runtime)
+			if err != nil {
+				return err
+			}
+			child.SSHClient = e.clientFor(included)
+			if err := e.dryRunJob(ctx, file, included, child, indent+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+
+		action, err := e.createAction(&actionSchema, nil)
+		if err != nil {
+			return err
+		}
+		desc := action.DryRun(ctx, runtime)
+		if len(actionSchema.Notify) > 0 {
+			desc += fmt.Sprintf(" (notify: %s)", strings.Join(actionSchema.Notify, ", "))
+		}
+		fmt.Fprintf(e.stdout, "%s- %s\n", indent, desc)
+	}
+	return nil
+}
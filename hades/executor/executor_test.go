@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// fakeClient is an ssh.Client that records the commands run on each host.
+// run, if set, handles each command; commands succeed otherwise.
+type fakeClient struct {
+	mu       sync.Mutex
+	commands []fakeCommand
+	run      func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error
+}
+
+type fakeCommand struct {
+	host string
+	cmd  string
+	env  map[string]string
+	at   time.Time
+}
+
+func (c *fakeClient) Connect(ctx context.Context, host ssh.Host) (ssh.Session, error) {
+	return &fakeSession{client: c, host: host}, nil
+}
+
+func (c *fakeClient) Forward(ctx context.Context, host ssh.Host, remoteAddr string, localPort int) (*ssh.Forward, error) {
+	return nil, fmt.Errorf("forward not supported")
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+// hostCommands returns the commands run on host, in order
+func (c *fakeClient) hostCommands(host string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var cmds []string
+	for _, cmd := range c.commands {
+		if cmd.host == host {
+			cmds = append(cmds, cmd.cmd)
+		}
+	}
+	return cmds
+}
+
+// find returns the first recorded command on host containing substr
+func (c *fakeClient) find(host, substr string) (fakeCommand, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cmd := range c.commands {
+		if cmd.host == host && strings.Contains(cmd.cmd, substr) {
+			return cmd, true
+		}
+	}
+	return fakeCommand{}, false
+}
+
+type fakeSession struct {
+	client *fakeClient
+	host   ssh.Host
+	env    map[string]string
+}
+
+func (s *fakeSession) Run(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
+	s.client.mu.Lock()
+	s.client.commands = append(s.client.commands, fakeCommand{host: s.host.Name, cmd: cmd, env: s.env, at: time.Now()})
+	run := s.client.run
+	s.client.mu.Unlock()
+
+	if run != nil {
+		return run(ctx, s.host, cmd, s.env, stdout)
+	}
+	return nil
+}
+
+func (s *fakeSession) CopyFile(ctx context.Context, content io.Reader, remotePath string, mode uint32) error {
+	return nil
+}
+
+func (s *fakeSession) ReadFile(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (s *fakeSession) SetEnv(env map[string]string) {
+	s.env = env
+}
+
+func (s *fakeSession) Close() error {
+	return nil
+}
+
+// fakeInventory resolves targets to fixed host lists, and host names to hosts
+type fakeInventory struct {
+	hosts   map[string]ssh.Host
+	targets map[string][]string
+}
+
+func newFakeInventory(targets map[string][]string) *fakeInventory {
+	inv := &fakeInventory{hosts: make(map[string]ssh.Host), targets: targets}
+	for _, names := range targets {
+		for _, name := range names {
+			inv.hosts[name] = ssh.Host{Name: name, Address: "127.0.0.1"}
+		}
+	}
+	return inv
+}
+
+func (i *fakeInventory) ResolveTarget(name string) ([]ssh.Host, error) {
+	if names, ok := i.targets[name]; ok {
+		hosts := make([]ssh.Host, len(names))
+		for j, n := range names {
+			hosts[j] = i.hosts[n]
+		}
+		return hosts, nil
+	}
+	if host, ok := i.hosts[name]; ok {
+		return []ssh.Host{host}, nil
+	}
+	return nil, fmt.Errorf("target or host %q not found in inventory", name)
+}
+
+func (i *fakeInventory) AllHosts() []ssh.Host {
+	var hosts []ssh.Host
+	for _, h := range i.hosts {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+func (i *fakeInventory) DynamicHosts() []ssh.Host {
+	return nil
+}
+
+func (i *fakeInventory) Targets() map[string][]string {
+	return i.targets
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writers
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newTestExecutor returns an executor using client, running in a temporary
+// directory so that run logs do not end up in the source tree
+func newTestExecutor(t *testing.T, client ssh.Client) (*executor, *syncBuffer) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	out := &syncBuffer{}
+	return New(client, nil, out, out).(*executor), out
+}
+
+// targetVarsInventory adds per-target vars to the hosts of a fakeInventory
+type targetVarsInventory struct {
+	*fakeInventory
+	vars map[string]map[string]string
+}
+
+func (i *targetVarsInventory) ResolveTarget(name string) ([]ssh.Host, error) {
+	hosts, err := i.fakeInventory.ResolveTarget(name)
+	for j := range hosts {
+		hosts[j].Vars = i.vars[name]
+	}
+	return hosts, err
+}
@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/SoftKiwiGames/hades/hades/artifacts"
+	"github.com/SoftKiwiGames/hades/hades/inventory"
+	"github.com/SoftKiwiGames/hades/hades/registry"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/wzshiming/ctc"
+)
+
+// executeGraph runs a plan whose steps declare needs. A step starts as soon as
+// every step it needs has completed, so independent steps run in parallel. A
+// failed step stops its dependents; steps that do not depend on it still run.
+// Matrix entries run at most matrix_parallelism at a time, and no new entries
+// of a matrix start after one of them fails. Each step's output is printed as
+// one block when it finishes. It returns the index of the first failed step
+// and its error, or -1 and nil.
+func (e *executor) executeGraph(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string, runID string, artifactMgr artifacts.Manager, registryMgr registry.Manager, touched [][]ssh.Host) (int, error) {
+	index := make(map[string]int)
+	for i, step := range plan.Steps {
+		index[step.ID()] = i
+	}
+
+	// Count unmet needs per step and record who waits on whom
+	pending := make([]int, len(plan.Steps))
+	dependents := make([][]int, len(plan.Steps))
+	for i, step := range plan.Steps {
+		pending[i] = len(step.Needs)
+		for _, need := range step.Needs {
+			dependents[index[need]] = append(dependents[index[need]], i)
+		}
+	}
+
+	type stepDone struct {
+		idx   int
+		hosts []ssh.Host
+		out   *stepOutput
+		err   error
+	}
+	done := make(chan stepDone)
+	running := 0
+
+	run := func(i int) {
+		running++
+		fmt.Fprintf(e.stdout, "%s□%s Step %q: started\n", ctc.ForegroundYellow, ctc.Reset, plan.Steps[i].ID())
+		go func() {
+			out := &stepOutput{}
+			stepExec := e.withOutput(out.writer(false), out.writer(true))
+			hosts, err := stepExec.executeStep(ctx, file, plan, planName, i, inv, targets, env, runID, artifactMgr, registryMgr)
+			done <- stepDone{idx: i, hosts: hosts, out: out, err: err}
+		}()
+	}
+
+	// Matrix entries become ready together; at most matrix_parallelism of
+	// them run at once and the rest wait, in order, for a slot
+	inFlight := make(map[string]int)
+	waiting := make(map[string][]int)
+	start := func(i int) {
+		if matrix := plan.Steps[i].MatrixOf; matrix != "" {
+			if inFlight[matrix] >= max(plan.Steps[i].MatrixParallel, 1) {
+				waiting[matrix] = append(waiting[matrix], i)
+				return
+			}
+			inFlight[matrix]++
+		}
+		run(i)
+	}
+
+	// skip reports the steps that will never run because of a failed step
+	skipped := make([]bool, len(plan.Steps))
+	var skip func(i int, reason string)
+	skip = func(i int, reason string) {
+		for _, dep := range dependents[i] {
+			if skipped[dep] {
+				continue
+			}
+			skipped[dep] = true
+			fmt.Fprintf(e.stdout, "%s□%s Step %q: skipped (%s)\n", ctc.ForegroundBlue, ctc.Reset, plan.Steps[dep].ID(), reason)
+			skip(dep, reason)
+		}
+	}
+
+	for i := range plan.Steps {
+		if pending[i] == 0 {
+			start(i)
+		}
+	}
+
+	failed := -1
+	var firstErr error
+	for running > 0 {
+		d := <-done
+		running--
+
+		d.out.flush(e.stdout, e.stderr)
+		touched[d.idx] = d.hosts
+
+		id := plan.Steps[d.idx].ID()
+		if matrix := plan.Steps[d.idx].MatrixOf; matrix != "" {
+			inFlight[matrix]--
+			queue := waiting[matrix]
+			switch {
+			case len(queue) == 0:
+			case d.err != nil || ctx.Err() != nil:
+				// As in a sequential plan, no new entries start after a failure
+				reason := fmt.Sprintf("matrix entry %q failed", id)
+				if d.err == nil {
+					reason = "cancelled"
+				}
+				for _, i := range queue {
+					skipped[i] = true
+					fmt.Fprintf(e.stdout, "%s□%s Step %q: skipped (%s)\n", ctc.ForegroundBlue, ctc.Reset, plan.Steps[i].ID(), reason)
+					skip(i, reason)
+				}
+				waiting[matrix] = nil
+			default:
+				waiting[matrix] = queue[1:]
+				start(queue[0])
+			}
+		}
+
+		if d.err != nil {
+			if failed < 0 {
+				failed = d.idx
+				firstErr = d.err
+			}
+			skip(d.idx, fmt.Sprintf("needs failed step %q", id))
+			continue
+		}
+
+		for _, dep := range dependents[d.idx] {
+			pending[dep]--
+			if pending[dep] > 0 || skipped[dep] {
+				continue
+			}
+			if ctx.Err() != nil {
+				skipped[dep] = true
+				fmt.Fprintf(e.stdout, "%s□%s Step %q: skipped (cancelled)\n", ctc.ForegroundBlue, ctc.Reset, plan.Steps[dep].ID())
+				skip(dep, "cancelled")
+				continue
+			}
+			start(dep)
+		}
+	}
+
+	return failed, firstErr
+}
+
+// stepOutput buffers the console output of a step running alongside others,
+// keeping the order of writes and which stream each went to
+type stepOutput struct {
+	mu     sync.Mutex
+	chunks []outputChunk
+}
+
+type outputChunk struct {
+	stderr bool
+	data   []byte
+}
+
+func (o *stepOutput) writer(stderr bool) io.Writer {
+	return &stepWriter{out: o, stderr: stderr}
+}
+
+// flush writes the buffered output to the real console streams
+func (o *stepOutput) flush(stdout, stderr io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, chunk := range o.chunks {
+		if chunk.stderr {
+			stderr.Write(chunk.data)
+		} else {
+			stdout.Write(chunk.data)
+		}
+	}
+	o.chunks = nil
+}
+
+type stepWriter struct {
+	out    *stepOutput
+	stderr bool
+}
+
+func (w *stepWriter) Write(p []byte) (int, error) {
+	w.out.mu.Lock()
+	defer w.out.mu.Unlock()
+	w.out.chunks = append(w.out.chunks, outputChunk{stderr: w.stderr, data: append([]byte(nil), p...)})
+	return len(p), nil
+}
@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// commandJobs returns one job per command, running just that command
+func commandJobs(cmds ...string) map[string]schema.Job {
+	jobs := make(map[string]schema.Job, len(cmds))
+	for _, cmd := range cmds {
+		run := schema.ActionRun(cmd)
+		jobs[cmd] = schema.Job{Actions: []schema.Action{{Run: &run}}}
+	}
+	return jobs
+}
+
+func TestExecuteGraph_FailureSkipsDependents(t *testing.T) {
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			if cmd == "build" {
+				return fmt.Errorf("exit status 1")
+			}
+			return nil
+		},
+	}
+	e, out := newTestExecutor(t, client)
+
+	file := &schema.File{Jobs: commandJobs("build", "deploy", "lint", "report")}
+	plan := &schema.Plan{Steps: []schema.Step{
+		{Job: "build", Targets: []string{"web"}},
+		{Job: "deploy", Targets: []string{"web"}, Needs: []string{"build"}},
+		{Job: "lint", Targets: []string{"web"}},
+		{Job: "report", Targets: []string{"web"}, Needs: []string{"lint"}},
+	}}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+	result, _ := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil)
+	if !result.Failed || result.FailedStep != "build" {
+		t.Fatalf("Expected step build to fail, got %+v", result)
+	}
+
+	ran := make(map[string]bool)
+	for _, cmd := range client.hostCommands("web-1") {
+		ran[cmd] = true
+	}
+	want := map[string]bool{"build": true, "lint": true, "report": true}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("Expected only independent steps to run, got %v", ran)
+	}
+	if !strings.Contains(out.String(), `Step "deploy": skipped (needs failed step "build")`) {
+		t.Errorf("Expected deploy to be reported as skipped:\n%s", out)
+	}
+}
+
+func TestExecuteGraph_BufferedOutput(t *testing.T) {
+	fastDone := make(chan struct{})
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch cmd {
+			case "fast":
+				close(fastDone)
+			case "slow":
+				// Still running while the fast step finishes and is printed
+				<-fastDone
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil
+		},
+	}
+	e, out := newTestExecutor(t, client)
+
+	file := &schema.File{Jobs: commandJobs("fast", "slow", "verify")}
+	plan := &schema.Plan{Steps: []schema.Step{
+		{Job: "slow", Targets: []string{"s"}},
+		{Job: "fast", Targets: []string{"f"}},
+		{Job: "verify", Targets: []string{"f"}, Needs: []string{"slow", "fast"}},
+	}}
+	inv := newFakeInventory(map[string][]string{"s": {"s-1"}, "f": {"f-1"}})
+
+	if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil); err != nil {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+
+	// Each step's lines are printed as one block, fast first as it finished first
+	var hosts []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		for _, host := range []string{"s-1", "f-1"} {
+			if strings.HasPrefix(line, "["+host+"]") && (len(hosts) == 0 || hosts[len(hosts)-1] != host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	want := []string{"f-1", "s-1", "f-1"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("Expected host blocks %v, got %v:\n%s", want, hosts, out)
+	}
+}
+
+func TestExecuteGraph_Diamond(t *testing.T) {
+	// eu and us only finish once both have started, so they must run in parallel
+	var started sync.WaitGroup
+	started.Add(2)
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			if cmd == "eu" || cmd == "us" {
+				started.Done()
+				wait := make(chan struct{})
+				go func() {
+					started.Wait()
+					close(wait)
+				}()
+				select {
+				case <-wait:
+				case <-time.After(5 * time.Second):
+					return fmt.Errorf("%s ran alone", cmd)
+				}
+			}
+			return nil
+		},
+	}
+	e, _ := newTestExecutor(t, client)
+
+	file := &schema.File{Jobs: commandJobs("build", "eu", "us", "verify")}
+	plan := &schema.Plan{Steps: []schema.Step{
+		{Job: "build", Targets: []string{"web"}},
+		{Job: "eu", Targets: []string{"web"}, Needs: []string{"build"}},
+		{Job: "us", Targets: []string{"web"}, Needs: []string{"build"}},
+		{Job: "verify", Targets: []string{"web"}, Needs: []string{"eu", "us"}},
+	}}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+	if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil); err != nil {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+
+	cmds := client.hostCommands("web-1")
+	if len(cmds) != 4 || cmds[0] != "build" || cmds[3] != "verify" {
+		t.Errorf("Expected build first and verify last, each once, got %v", cmds)
+	}
+}
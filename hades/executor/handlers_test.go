@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+func TestRunJob_Handlers(t *testing.T) {
+	tests := []struct {
+		name    string
+		failOn  string
+		wantRun []string
+		wantNot []string
+	}{
+		{name: "notified once", wantRun: []string{"reload-app"}, wantNot: []string{"restart-db"}},
+		{name: "action failure", failOn: "migrate", wantNot: []string{"reload-app", "restart-db"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{
+				run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+					if strings.Contains(cmd, "systemctl is-active") {
+						io.WriteString(stdout, "active\nenabled\n")
+					}
+					if tt.failOn != "" && cmd == tt.failOn {
+						return fmt.Errorf("exit status 1")
+					}
+					return nil
+				},
+			}
+			e, _ := newTestExecutor(t, client)
+
+			configure := schema.ActionRun("configure")
+			migrate := schema.ActionRun("migrate")
+			reload := schema.ActionRun("reload-app")
+			restart := schema.ActionRun("restart-db")
+			file := &schema.File{Jobs: map[string]schema.Job{
+				"deploy": {
+					Actions: []schema.Action{
+						{Service: &schema.ActionService{Name: "db", State: "started"}, Notify: []string{"restart db"}},
+						{Run: &configure, Notify: []string{"reload app"}},
+						{Run: &migrate, Notify: []string{"reload app"}},
+					},
+					Handlers: []schema.Action{
+						{Name: "restart db", Run: &restart},
+						{Name: "reload app", Run: &reload},
+					},
+				},
+			}}
+			plan := &schema.Plan{Steps: []schema.Step{{Job: "deploy", Targets: []string{"web"}}}}
+			inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+			result, _ := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil)
+			if result.Failed != (tt.failOn != "") {
+				t.Fatalf("Expected failed=%v, got %v (%v)", tt.failOn != "", result.Failed, result.Error)
+			}
+
+			cmds := client.hostCommands("web-1")
+			for _, want := range tt.wantRun {
+				if n := count(cmds, want); n != 1 {
+					t.Errorf("Expected %q to run once, ran %d times: %v", want, n, cmds)
+				}
+			}
+			for _, not := range tt.wantNot {
+				if n := count(cmds, not); n != 0 {
+					t.Errorf("Expected %q not to run, ran %d times: %v", not, n, cmds)
+				}
+			}
+		})
+	}
+}
+
+func count(cmds []string, cmd string) int {
+	n := 0
+	for _, c := range cmds {
+		if c == cmd {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveHosts_TargetVars(t *testing.T) {
+	inv := &targetVarsInventory{
+		fakeInventory: newFakeInventory(map[string][]string{
+			"web": {"web-1", "web-2"},
+			"eu":  {"web-1"},
+			"us":  {"web-1"},
+		}),
+		vars: map[string]map[string]string{
+			"web": {"ROLE": "web"},
+			"eu":  {"REGION": "eu", "ROLE": "web"},
+			"us":  {"REGION": "us"},
+		},
+	}
+
+	hosts, err := resolveHosts(inv, []string{"web", "eu"})
+	if err != nil {
+		t.Fatalf("resolveHosts: %v", err)
+	}
+	if len(hosts) != 2 || hosts[0].Name != "web-1" {
+		t.Fatalf("Expected web-1 and web-2 once each, got %v", hosts)
+	}
+	if want := map[string]string{"ROLE": "web", "REGION": "eu"}; !reflect.DeepEqual(hosts[0].Vars, want) {
+		t.Errorf("Expected the vars of both targets %v, got %v", want, hosts[0].Vars)
+	}
+	if want := map[string]string{"ROLE": "web"}; !reflect.DeepEqual(inv.vars["web"], want) {
+		t.Errorf("Expected target vars to be left untouched, got %v", inv.vars["web"])
+	}
+
+	_, err = resolveHosts(inv, []string{"eu", "us"})
+	if err == nil || !strings.Contains(err.Error(), `host "web-1" gets conflicting values for var "REGION" from targets "eu" and "us"`) {
+		t.Errorf("Expected conflicting REGION error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SoftKiwiGames/hades/hades/artifacts"
+	"github.com/SoftKiwiGames/hades/hades/inventory"
+	"github.com/SoftKiwiGames/hades/hades/registry"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/wzshiming/ctc"
+)
+
+// matrixRun returns how many steps starting at first are entries of one
+// matrix step that run several at a time, or 1 for any other step
+func matrixRun(steps []schema.Step, first int) int {
+	step := steps[first]
+	if step.MatrixOf == "" || step.MatrixParallel <= 1 {
+		return 1
+	}
+	n := 1
+	for first+n < len(steps) && steps[first+n].MatrixOf == step.MatrixOf {
+		n++
+	}
+	return n
+}
+
+// executeMatrix runs the n matrix entries starting at step first of a
+// sequential plan, up to matrix_parallelism at a time and in order. After a
+// failure no new entries start; entries in flight finish. Each entry's output
+// is printed as one block when it finishes. It returns the index of the first
+// failed entry and its error, or -1 and nil.
+func (e *executor) executeMatrix(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, first, n int, inv inventory.Inventory, targets []string, env map[string]string, runID string, artifactMgr artifacts.Manager, registryMgr registry.Manager, touched [][]ssh.Host) (int, error) {
+	type entryDone struct {
+		idx   int
+		hosts []ssh.Host
+		out   *stepOutput
+		err   error
+	}
+	done := make(chan entryDone)
+	width := plan.Steps[first].MatrixParallel
+
+	e.ui.Info("Matrix %q: %d entries, up to %d at a time", plan.Steps[first].MatrixOf, n, width)
+	fmt.Fprintln(e.stdout)
+
+	failed := -1
+	var firstErr error
+	next, running := first, 0
+	for {
+		for running < width && next < first+n && failed < 0 && ctx.Err() == nil {
+			i := next
+			next++
+			running++
+			fmt.Fprintf(e.stdout, "%s□%s Step %q: started\n", ctc.ForegroundYellow, ctc.Reset, plan.Steps[i].ID())
+			go func() {
+				out := &stepOutput{}
+				entryExec := e.withOutput(out.writer(false), out.writer(true))
+				hosts, err := entryExec.executeStep(ctx, file, plan, planName, i, inv, targets, env, runID, artifactMgr, registryMgr)
+				done <- entryDone{idx: i, hosts: hosts, out: out, err: err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		d.out.flush(e.stdout, e.stderr)
+		touched[d.idx] = d.hosts
+		if d.err != nil && failed < 0 {
+			failed = d.idx
+			firstErr = d.err
+		}
+	}
+
+	for i := next; i < first+n; i++ {
+		fmt.Fprintf(e.stdout, "%s□%s Step %q: skipped\n", ctc.ForegroundBlue, ctc.Reset, plan.Steps[i].ID())
+	}
+	if failed < 0 && next < first+n {
+		return next, ctx.Err()
+	}
+	return failed, firstErr
+}
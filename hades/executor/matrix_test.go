@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// matrixPlan returns a sequential plan with one matrix entry per service, as
+// the loader expands them, followed by a verify step. In a graph plan the
+// entries and verify need the same steps the loader gives them.
+func matrixPlan(graph bool, parallelism int, services ...string) (*schema.File, *schema.Plan) {
+	deploy := schema.ActionRun("deploy ${SERVICE}")
+	verify := schema.ActionRun("verify")
+	file := &schema.File{Jobs: map[string]schema.Job{
+		"deploy": {Env: map[string]schema.Env{"SERVICE": {}}, Actions: []schema.Action{{Run: &deploy}}},
+		"verify": {Actions: []schema.Action{{Run: &verify}}},
+	}}
+
+	plan := &schema.Plan{}
+	for _, service := range services {
+		plan.Steps = append(plan.Steps, schema.Step{
+			Name:           fmt.Sprintf("deploy[SERVICE=%s]", service),
+			Job:            "deploy",
+			Targets:        []string{"web"},
+			MatrixOf:       "deploy",
+			MatrixParallel: parallelism,
+			MatrixEnv:      map[string]string{"SERVICE": service},
+		})
+	}
+	verifyStep := schema.Step{Job: "verify", Targets: []string{"web"}}
+	if graph {
+		for _, step := range plan.Steps {
+			verifyStep.Needs = append(verifyStep.Needs, step.ID())
+		}
+	}
+	plan.Steps = append(plan.Steps, verifyStep)
+	return file, plan
+}
+
+func TestExecuteMatrix_Parallelism(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		t.Run(fmt.Sprintf("graph=%t", graph), func(t *testing.T) {
+			var (
+				mu          sync.Mutex
+				running     int
+				maxParallel int
+			)
+			client := &fakeClient{
+				run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+					mu.Lock()
+					running++
+					maxParallel = max(maxParallel, running)
+					mu.Unlock()
+
+					time.Sleep(20 * time.Millisecond)
+
+					mu.Lock()
+					running--
+					mu.Unlock()
+					return nil
+				},
+			}
+			e, _ := newTestExecutor(t, client)
+
+			file, plan := matrixPlan(graph, 2, "api", "worker", "cron", "mail")
+			inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+			if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil); err != nil {
+				t.Fatalf("Expected success, got %v", result.Error)
+			}
+			if maxParallel != 2 {
+				t.Errorf("Expected 2 entries at a time, got %d", maxParallel)
+			}
+			cmds := client.hostCommands("web-1")
+			if len(cmds) != 5 || cmds[4] != "verify" {
+				t.Errorf("Expected verify after all entries, got %v", cmds)
+			}
+		})
+	}
+}
+
+func TestExecuteMatrix_FailureStopsPlan(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		t.Run(fmt.Sprintf("graph=%t", graph), func(t *testing.T) {
+			client := &fakeClient{
+				run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+					switch cmd {
+					case "deploy api":
+						return fmt.Errorf("exit status 1")
+					case "deploy worker":
+						time.Sleep(50 * time.Millisecond)
+					}
+					return nil
+				},
+			}
+			e, _ := newTestExecutor(t, client)
+
+			file, plan := matrixPlan(graph, 2, "api", "worker", "cron", "mail")
+			inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+			result, _ := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil)
+			if !result.Failed || result.FailedStep != "deploy[SERVICE=api]" {
+				t.Fatalf("Expected the api entry to fail, got %+v", result)
+			}
+
+			// The entry in flight finishes; later entries and steps never start
+			want := map[string]bool{"deploy api": true, "deploy worker": true}
+			ran := make(map[string]bool)
+			for _, cmd := range client.hostCommands("web-1") {
+				ran[cmd] = true
+			}
+			if !reflect.DeepEqual(ran, want) {
+				t.Errorf("Expected %v, got %v", want, ran)
+			}
+		})
+	}
+}
+
+func TestExecuteMatrix_ValuesOverrideCLI(t *testing.T) {
+	client := &fakeClient{}
+	e, _ := newTestExecutor(t, client)
+
+	file, plan := matrixPlan(false, 1, "api", "worker")
+	inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+	if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, map[string]string{"SERVICE": "x"}); err != nil {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	want := []string{"deploy api", "deploy worker", "verify"}
+	if got := client.hostCommands("web-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,184 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SoftKiwiGames/hades/hades/approval"
+	"github.com/SoftKiwiGames/hades/hades/artifacts"
+	"github.com/SoftKiwiGames/hades/hades/inventory"
+	"github.com/SoftKiwiGames/hades/hades/loader"
+	"github.com/SoftKiwiGames/hades/hades/registry"
+	"github.com/SoftKiwiGames/hades/hades/rollout"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// rollbackPlan runs the plan's on_failure job or plan against the hosts the
+// failed run touched. touched holds the hosts of each step, nil for steps that
+// did not run, and failed indexes the failed step. It runs to completion even
+// if ctx was cancelled.
+func (e *executor) rollbackPlan(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string, result *Result, failed int, touched [][]ssh.Host, artifactMgr artifacts.Manager, registryMgr registry.Manager) *RollbackResult {
+	onFailure := plan.OnFailure
+	ctx = context.WithoutCancel(ctx)
+
+	hosts := rollbackHosts(onFailure.Scope, failed, touched)
+	rollback := &RollbackResult{Name: onFailure.Job}
+	kind := "job"
+	if onFailure.Plan != "" {
+		rollback.Name = onFailure.Plan
+		kind = "plan"
+	}
+	for _, host := range hosts {
+		rollback.Hosts = append(rollback.Hosts, host.Name)
+	}
+
+	e.ui.Section(fmt.Sprintf("On failure: %s %q", kind, rollback.Name))
+	if len(hosts) == 0 {
+		e.ui.Info("No hosts were touched, nothing to roll back")
+		return rollback
+	}
+	e.ui.Info("Hosts: %d", len(hosts))
+
+	// Env priority: CLI > on_failure > plan; the failure details are built-ins
+	provided := make(map[string]string)
+	for k, v := range plan.Env {
+		provided[k] = v
+	}
+	for k, v := range onFailure.Env {
+		provided[k] = v
+	}
+	for k, v := range env {
+		provided[k] = v
+	}
+	failure := map[string]string{
+		"HADES_FAILED_STEP": result.FailedStep,
+		"HADES_FAILED_HOST": result.FailedHost,
+	}
+
+	// HADES_TARGET follows the failed step
+	failedStep := plan.Steps[failed]
+	targetName := ""
+	if len(targets) > 0 {
+		targetName = targets[0]
+	} else if len(failedStep.Targets) > 0 {
+		targetName = failedStep.Targets[0]
+	}
+
+	// Host vars and job defaults are merged in per host
+	jobEnv := make(map[string]string, len(provided)+len(failure))
+	for k, v := range provided {
+		jobEnv[k] = v
+	}
+	for k, v := range failure {
+		jobEnv[k] = v
+	}
+
+	if onFailure.Plan != "" {
+		rollbackPlan, ok := file.Plans[onFailure.Plan]
+		if !ok {
+			rollback.Error = fmt.Errorf("plan %q not found", onFailure.Plan)
+			return rollback
+		}
+		// Every step of the rollback plan runs against the touched hosts
+		hostVars := func(*schema.Step) (map[string]map[string]string, error) {
+			vars := make(map[string]map[string]string, len(hosts))
+			for _, host := range hosts {
+				vars[host.Name] = host.Vars
+			}
+			return vars, nil
+		}
+		if err := loader.ValidatePlanEnv(file, onFailure.Plan, provided, hostVars); err != nil {
+			rollback.Error = err
+			return rollback
+		}
+		rollback.Error = e.rollbackSteps(ctx, file, &rollbackPlan, onFailure.Plan, inv, hosts, jobEnv, result.RunID, planName, targetName, artifactMgr, registryMgr)
+		return rollback
+	}
+
+	job, err := e.loadJob(file, onFailure.Job)
+	if err != nil {
+		rollback.Error = err
+		return rollback
+	}
+	for _, host := range hosts {
+		if err := loader.ValidateEnvContract(job, loader.HostEnv(job, host.Vars, provided)); err != nil {
+			rollback.Error = fmt.Errorf("host %q: %w", host.Name, err)
+			return rollback
+		}
+	}
+
+	tunnel, err := resolveTunnel(inv, &schema.Step{}, job)
+	if err != nil {
+		rollback.Error = err
+		return rollback
+	}
+	e.loadArtifacts(file, job, artifactMgr)
+
+	fmt.Fprintln(e.stdout)
+	gate := approval.NewGate(e.approver, fmt.Sprintf("On failure %q", onFailure.Job))
+	rollback.Error = e.executeBatch(ctx, file, job, onFailure.Job, result.RunID, planName, targetName, hosts, jobEnv, artifactMgr, registryMgr, gate, tunnel)
+	return rollback
+}
+
+// rollbackSteps runs the steps of a rollback plan in order against hosts, as
+// part of the failed run: same run ID, logs and artifacts. Step limit and
+// parallelism apply; targets and needs are ignored.
+func (e *executor) rollbackSteps(ctx context.Context, file *schema.File, plan *schema.Plan, name string, inv inventory.Inventory, hosts []ssh.Host, env map[string]string, runID, planName, targetName string, artifactMgr artifacts.Manager, registryMgr registry.Manager) error {
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+
+		job, err := e.loadJob(file, step.Job)
+		if err != nil {
+			return err
+		}
+		tunnel, err := resolveTunnel(inv, step, job)
+		if err != nil {
+			return err
+		}
+		e.loadArtifacts(file, job, artifactMgr)
+
+		parallelism := step.Parallelism
+		if parallelism == "" {
+			parallelism = plan.Parallelism
+		}
+		strategy, err := rollout.ParseStrategy(parallelism, len(hosts))
+		if err != nil {
+			return fmt.Errorf("step %s: invalid parallelism: %w", step.ID(), err)
+		}
+		strategy.Limit = step.Limit
+
+		fmt.Fprintln(e.stdout)
+		e.ui.Info("Step %d/%d: %s", i+1, len(plan.Steps), step.ID())
+		gate := approval.NewGate(e.approver, fmt.Sprintf("On failure %q, step %d/%d", name, i+1, len(plan.Steps)))
+		stepEnv := mergeStepEnv(plan, step, env)
+		for _, batch := range strategy.CreateBatches(hosts) {
+			if err := e.executeBatch(ctx, file, job, step.Job, runID, planName, targetName, batch, stepEnv, artifactMgr, registryMgr, gate, tunnel); err != nil {
+				return fmt.Errorf("step %s: %w", step.ID(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// rollbackHosts returns the hosts of the failed step, or of every step run so
+// far for scope "all", without duplicates
+func rollbackHosts(scope string, failed int, touched [][]ssh.Host) []ssh.Host {
+	steps := touched[failed : failed+1]
+	if scope == "all" {
+		steps = touched
+	}
+
+	var hosts []ssh.Host
+	seen := make(map[string]bool)
+	for _, stepHosts := range steps {
+		for _, host := range stepHosts {
+			if seen[host.Name] {
+				continue
+			}
+			seen[host.Name] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
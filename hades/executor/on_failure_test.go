@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// restoreRun records the env each host ran the "restore" command with
+type restoreRun struct {
+	mu   sync.Mutex
+	envs map[string]map[string]string
+}
+
+func (r *restoreRun) hosts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hosts []string
+	for host := range r.envs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// runFailingPlan runs a two-step plan whose second step fails on db-1, with
+// onFailure as the plan's rollback
+func runFailingPlan(t *testing.T, onFailure *schema.PlanOnFailure) (*Result, *restoreRun, string) {
+	t.Helper()
+	restored := &restoreRun{envs: make(map[string]map[string]string)}
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch cmd {
+			case "migrate":
+				return fmt.Errorf("exit status 1")
+			case "restore":
+				copied := make(map[string]string, len(env))
+				for k, v := range env {
+					copied[k] = v
+				}
+				restored.mu.Lock()
+				restored.envs[host.Name] = copied
+				restored.mu.Unlock()
+			}
+			return nil
+		},
+	}
+	e, out := newTestExecutor(t, client)
+
+	deploy := schema.ActionRun("deploy")
+	migrate := schema.ActionRun("migrate")
+	restore := schema.ActionRun("restore")
+	file := &schema.File{
+		Jobs: map[string]schema.Job{
+			"deploy":  {Actions: []schema.Action{{Run: &deploy}}},
+			"migrate": {Actions: []schema.Action{{Run: &migrate}}},
+			"restore": {
+				Env:     map[string]schema.Env{"REGION": {}, "VERSION": {}},
+				Actions: []schema.Action{{Run: &restore}},
+			},
+		},
+		Plans: map[string]schema.Plan{
+			"cleanup": {Steps: []schema.Step{{Job: "restore"}}},
+		},
+	}
+	plan := &schema.Plan{
+		Env: map[string]string{"VERSION": "v1"},
+		Steps: []schema.Step{
+			{Name: "app", Job: "deploy", Targets: []string{"web"}},
+			{Name: "db", Job: "migrate", Targets: []string{"db"}},
+		},
+		OnFailure: onFailure,
+	}
+
+	inv := newFakeInventory(map[string][]string{"web": {"web-1"}, "db": {"db-1"}})
+	for name, region := range map[string]string{"web-1": "eu", "db-1": "us"} {
+		host := inv.hosts[name]
+		host.Vars = map[string]string{"REGION": region}
+		inv.hosts[name] = host
+	}
+
+	result, _ := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil)
+	if !result.Failed || result.Rollback == nil {
+		t.Fatalf("Expected a failed run with a rollback, got %+v", result)
+	}
+	if result.Rollback.Error != nil {
+		t.Fatalf("Expected rollback to succeed, got %v", result.Rollback.Error)
+	}
+	return result, restored, out.String()
+}
+
+func TestRollbackPlan_JobScope(t *testing.T) {
+	result, restored, _ := runFailingPlan(t, &schema.PlanOnFailure{Job: "restore"})
+
+	if got := restored.hosts(); !reflect.DeepEqual(got, []string{"db-1"}) {
+		t.Fatalf("Expected restore on the failed step's hosts only, got %v", got)
+	}
+	env := restored.envs["db-1"]
+	if env["REGION"] != "us" || env["VERSION"] != "v1" {
+		t.Errorf("Expected host vars and plan env, got %v", env)
+	}
+	if env["HADES_RUN_ID"] != result.RunID {
+		t.Errorf("Expected run ID %q, got %q", result.RunID, env["HADES_RUN_ID"])
+	}
+}
+
+func TestRollbackPlan_AllScope(t *testing.T) {
+	_, restored, _ := runFailingPlan(t, &schema.PlanOnFailure{Job: "restore", Scope: "all"})
+
+	if got := restored.hosts(); !reflect.DeepEqual(got, []string{"db-1", "web-1"}) {
+		t.Fatalf("Expected restore on every touched host, got %v", got)
+	}
+	if region := restored.envs["web-1"]["REGION"]; region != "eu" {
+		t.Errorf("Expected web-1 vars, got REGION=%q", region)
+	}
+}
+
+func TestRollbackPlan_PlanScope(t *testing.T) {
+	result, restored, out := runFailingPlan(t, &schema.PlanOnFailure{Plan: "cleanup", Scope: "all"})
+
+	if got := restored.hosts(); !reflect.DeepEqual(got, []string{"db-1", "web-1"}) {
+		t.Fatalf("Expected the rollback plan on every touched host, got %v", got)
+	}
+	for host, env := range restored.envs {
+		if env["HADES_RUN_ID"] != result.RunID {
+			t.Errorf("%s: expected run ID %q, got %q", host, result.RunID, env["HADES_RUN_ID"])
+		}
+		if env["HADES_FAILED_STEP"] != "db" || env["HADES_FAILED_HOST"] != "db-1" {
+			t.Errorf("%s: expected failure details, got %v", host, env)
+		}
+	}
+	if region := restored.envs["db-1"]["REGION"]; region != "us" {
+		t.Errorf("Expected db-1 vars, got REGION=%q", region)
+	}
+	if n := strings.Count(out, "Run ID:"); n != 1 {
+		t.Errorf("Expected one plan header, got %d:\n%s", n, out)
+	}
+}
@@ -0,0 +1,143 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/SoftKiwiGames/hades/hades/approval"
+	"github.com/SoftKiwiGames/hades/hades/artifacts"
+	"github.com/SoftKiwiGames/hades/hades/inventory"
+	"github.com/SoftKiwiGames/hades/hades/registry"
+	"github.com/SoftKiwiGames/hades/hades/rollout"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/wzshiming/ctc"
+)
+
+// executePipeline runs a plan with strategy pipeline: each host moves through
+// the steps on its own, skipping steps that do not target it, while the plan's
+// parallelism caps how many hosts are in flight. After a failure no new hosts
+// start and hosts in flight stop once their current step ends. It returns the
+// index of the first failed step and its error, or -1 and nil.
+func (e *executor) executePipeline(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string, runID string, artifactMgr artifacts.Manager, registryMgr registry.Manager, touched [][]ssh.Host) (int, error) {
+	// Resolve every step up front so configuration errors surface before any
+	// host is touched
+	steps := make([]*preparedStep, len(plan.Steps))
+	stepHosts := make([]map[string]bool, len(plan.Steps))
+	gates := make([]approval.Approver, len(plan.Steps))
+	var hosts []ssh.Host
+	seen := make(map[string]bool)
+
+	for i := range plan.Steps {
+		step := plan.Steps[i]
+		prepared, err := e.prepareStep(file, plan, &step, inv, targets, env, artifactMgr)
+		if err != nil {
+			return i, err
+		}
+		steps[i] = prepared
+
+		stepHosts[i] = make(map[string]bool)
+		for _, host := range prepared.hosts {
+			stepHosts[i][host.Name] = true
+			if !seen[host.Name] {
+				seen[host.Name] = true
+				hosts = append(hosts, host)
+			}
+		}
+
+		// Approval gates are answered once per step for the whole pipeline
+		scope := fmt.Sprintf("Step %d/%d", i+1, len(plan.Steps))
+		if step.Name != "" {
+			scope = fmt.Sprintf("Step %q", step.Name)
+		}
+		gates[i] = approval.NewGate(e.approver, scope)
+	}
+
+	strategy, err := rollout.ParseStrategy(plan.Parallelism, len(hosts))
+	if err != nil {
+		return 0, fmt.Errorf("invalid parallelism: %w", err)
+	}
+	inFlight := strategy.Parallelism
+	if inFlight < 1 {
+		inFlight = 1
+	}
+
+	e.ui.Info("Strategy: pipeline (%d hosts, up to %d in flight)", len(hosts), inFlight)
+	for i, prepared := range steps {
+		e.ui.Info("  Step %d/%d: %s (job: %s, targets: %s, hosts: %d)", i+1, len(steps), plan.Steps[i].ID(), plan.Steps[i].Job, strings.Join(prepared.targets, ", "), len(prepared.hosts))
+	}
+	fmt.Fprintln(e.stdout)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		stopped  bool
+		failed   = -1
+		firstErr error
+	)
+	slots := make(chan struct{}, inFlight)
+
+	runHost := func(host ssh.Host) {
+		defer wg.Done()
+		defer func() { <-slots }()
+
+		for i, prepared := range steps {
+			if !stepHosts[i][host.Name] {
+				continue
+			}
+
+			mu.Lock()
+			if stopped {
+				mu.Unlock()
+				fmt.Fprintf(e.stdout, "[%s] %s□%s Pipeline stopped before step %q\n", host.Name, ctc.ForegroundBlue, ctc.Reset, plan.Steps[i].ID())
+				return
+			}
+			touched[i] = append(touched[i], host)
+			mu.Unlock()
+
+			jobName := plan.Steps[i].Job
+			fmt.Fprintf(e.stdout, "[%s] %s□%s Step %d/%d: %s\n", host.Name, ctc.ForegroundYellow, ctc.Reset, i+1, len(steps), plan.Steps[i].ID())
+
+			err := e.executeJob(ctx, file, prepared.job, jobName, runID, planName, prepared.targets[0], host, prepared.env, artifactMgr, registryMgr, gates[i], prepared.tunnel)
+			if err != nil {
+				fmt.Fprintf(e.stderr, "[%s] %s◆%s Job %q: failed - %v\n", host.Name, ctc.ForegroundRed, ctc.Reset, jobName, err)
+				mu.Lock()
+				if failed < 0 {
+					failed = i
+					firstErr = &hostError{host: host.Name, err: err}
+				}
+				stopped = true
+				mu.Unlock()
+				return
+			}
+			fmt.Fprintf(e.stdout, "[%s] %s◆%s Job %q: completed\n", host.Name, ctc.ForegroundGreen, ctc.Reset, jobName)
+		}
+
+		fmt.Fprintf(e.stdout, "[%s] %s■%s Pipeline completed\n", host.Name, ctc.ForegroundGreen, ctc.Reset)
+	}
+
+	for _, host := range hosts {
+		slots <- struct{}{} // Wait for a free slot
+
+		mu.Lock()
+		halt := stopped || ctx.Err() != nil
+		mu.Unlock()
+		if halt {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go runHost(host)
+	}
+	wg.Wait()
+
+	if failed >= 0 {
+		fmt.Fprintf(e.stderr, "\n  Status: %s■%s Failed\n\n", ctc.ForegroundRed, ctc.Reset)
+	} else {
+		fmt.Fprintf(e.stdout, "\n  Status: %s■%s Completed\n\n", ctc.ForegroundGreen, ctc.Reset)
+	}
+	return failed, firstErr
+}
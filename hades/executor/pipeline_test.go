@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+func TestExecutePipeline_HostsProgressIndependently(t *testing.T) {
+	// web-1 stays in its first step until web-2 has reached the second
+	web2Upgraded := make(chan struct{})
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch {
+			case host.Name == "web-1" && cmd == "drain":
+				select {
+				case <-web2Upgraded:
+				case <-time.After(5 * time.Second):
+					return fmt.Errorf("web-2 was held back")
+				}
+			case host.Name == "web-2" && cmd == "upgrade":
+				close(web2Upgraded)
+			}
+			return nil
+		},
+	}
+	e, _ := newTestExecutor(t, client)
+
+	file := &schema.File{Jobs: commandJobs("drain", "upgrade", "canary-check")}
+	plan := &schema.Plan{
+		Strategy:    schema.StrategyPipeline,
+		Parallelism: "2",
+		Steps: []schema.Step{
+			{Job: "drain", Targets: []string{"web"}},
+			{Job: "upgrade", Targets: []string{"web"}},
+			{Job: "canary-check", Targets: []string{"web-1"}},
+		},
+	}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1", "web-2"}})
+
+	if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil); err != nil {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+
+	want := map[string][]string{
+		"web-1": {"drain", "upgrade", "canary-check"},
+		"web-2": {"drain", "upgrade"},
+	}
+	for host, cmds := range want {
+		if got := client.hostCommands(host); !reflect.DeepEqual(got, cmds) {
+			t.Errorf("%s: expected %v, got %v", host, cmds, got)
+		}
+	}
+}
+
+func TestExecutePipeline_Parallelism(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		running     int
+		maxParallel int
+	)
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			mu.Lock()
+			running++
+			maxParallel = max(maxParallel, running)
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		},
+	}
+	e, _ := newTestExecutor(t, client)
+
+	file := &schema.File{Jobs: commandJobs("upgrade")}
+	plan := &schema.Plan{
+		Strategy:    schema.StrategyPipeline,
+		Parallelism: "50%",
+		Steps:       []schema.Step{{Job: "upgrade", Targets: []string{"web"}}},
+	}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1", "web-2", "web-3", "web-4"}})
+
+	if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil); err != nil {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	if maxParallel != 2 {
+		t.Errorf("Expected 2 hosts in flight, got %d", maxParallel)
+	}
+}
+
+func TestExecutePipeline_FailureStopsOtherHosts(t *testing.T) {
+	web2Started := make(chan struct{})
+	web1Failed := make(chan struct{})
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch {
+			case host.Name == "web-1" && cmd == "drain":
+				<-web2Started
+				close(web1Failed)
+				return fmt.Errorf("exit status 1")
+			case host.Name == "web-2" && cmd == "drain":
+				// Finish the current step only after web-1 has failed
+				close(web2Started)
+				<-web1Failed
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil
+		},
+	}
+	e, out := newTestExecutor(t, client)
+
+	file := &schema.File{Jobs: commandJobs("drain", "upgrade")}
+	plan := &schema.Plan{
+		Strategy:    schema.StrategyPipeline,
+		Parallelism: "2",
+		Steps: []schema.Step{
+			{Job: "drain", Targets: []string{"web"}},
+			{Job: "upgrade", Targets: []string{"web"}},
+		},
+	}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1", "web-2", "web-3"}})
+
+	result, _ := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil)
+	if !result.Failed || result.FailedHost != "web-1" || result.FailedStep != "drain" {
+		t.Fatalf("Expected drain to fail on web-1, got %+v", result)
+	}
+
+	// web-2 finishes the step it is in but does not move on; web-3 never starts
+	if got := client.hostCommands("web-2"); !reflect.DeepEqual(got, []string{"drain"}) {
+		t.Errorf("web-2: expected only drain, got %v", got)
+	}
+	if got := client.hostCommands("web-3"); len(got) != 0 {
+		t.Errorf("web-3: expected nothing to run, got %v", got)
+	}
+	if !strings.Contains(out.String(), `Pipeline stopped before step "upgrade"`) {
+		t.Errorf("Expected web-2 to report the stop:\n%s", out)
+	}
+}
@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SoftKiwiGames/hades/hades/actions"
+	"github.com/SoftKiwiGames/hades/hades/artifacts"
+	"github.com/SoftKiwiGames/hades/hades/inventory"
+	"github.com/SoftKiwiGames/hades/hades/loader"
+	"github.com/SoftKiwiGames/hades/hades/logger"
+	"github.com/SoftKiwiGames/hades/hades/registry"
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/types"
+	"github.com/wzshiming/ctc"
+)
+
+// Rollback flips the current symlink of every release action in the plan back
+// to the previous release directory on each targeted host
+func (e *executor) Rollback(ctx context.Context, file *schema.File, plan *schema.Plan, planName string, inv inventory.Inventory, targets []string, env map[string]string) (*Result, error) {
+	result := &Result{
+		StartTime: time.Now(),
+		RunID:     "hades-rollback-" + time.Now().Format("20060102-150405"),
+	}
+
+	registryMgr, err := registry.NewManager(file.Registries)
+	if err != nil {
+		result.Failed = true
+		result.Error = fmt.Errorf("failed to initialize registries: %w", err)
+		return result, result.Error
+	}
+	artifactMgr := artifacts.NewManager()
+
+	e.ui.Header(fmt.Sprintf("Rollback: %s", planName))
+	e.ui.Info("Run ID: %s", result.RunID)
+	e.ui.Info("Started: %s\n", time.Now().Format(time.RFC3339))
+
+	// Each host/path pair is rolled back once, even if several steps release to it
+	done := make(map[string]bool)
+	releases := false
+	for _, step := range plan.Steps {
+		if job, ok := file.Jobs[step.Job]; ok && hasRelease(file, &job) {
+			releases = true
+		}
+	}
+	if !releases {
+		result.Failed = true
+		result.Error = fmt.Errorf("plan %q has no release actions", planName)
+		return result, result.Error
+	}
+
+	for _, step := range plan.Steps {
+		job, err := e.loadJob(file, step.Job)
+		if err != nil {
+			result.Failed = true
+			result.Error = err
+			return result, result.Error
+		}
+		if !hasRelease(file, job) {
+			continue
+		}
+
+		stepTargets := step.Targets
+		if len(targets) > 0 {
+			stepTargets = targets
+		}
+
+		stepEnv := mergeStepEnv(plan, &step, env)
+
+		hosts, err := resolveHosts(inv, stepTargets)
+		if err != nil {
+			result.Failed = true
+			result.FailedStep = step.Name
+			result.Error = err
+			return result, result.Error
+		}
+
+		for _, host := range hosts {
+			hostLogger, err := logger.New(result.RunID, planName, host.Name, e.stdout, e.stderr)
+			if err != nil {
+				return result, fmt.Errorf("failed to initialize logger for host %s: %w", host.Name, err)
+			}
+
+			mergedEnv := loader.HostEnv(job, host.Vars, stepEnv)
+			runtime := types.NewRuntime(e.clientFor(job), artifactMgr, registryMgr, result.RunID, planName, stepTargets[0], host, mergedEnv, hostLogger.Stdout(), hostLogger.Stderr(), e.stdout, e.stderr, job.SourceDir)
+
+			found, err := e.hostReleases(file, job, runtime)
+			if err != nil {
+				hostLogger.Close()
+				result.Failed = true
+				result.FailedStep = step.Name
+				result.FailedHost = host.Name
+				result.Error = err
+				return result, result.Error
+			}
+
+			for _, release := range found {
+				base := actions.ExpandEnvVars(release.action.Path, release.runtime.Env)
+				if strings.Contains(base, "${") {
+					hostLogger.Close()
+					result.Failed = true
+					result.FailedStep = step.Name
+					result.Error = fmt.Errorf("release path %q references undefined variables (pass them with -e)", release.action.Path)
+					return result, result.Error
+				}
+
+				key := host.Name + ":" + base
+				if done[key] {
+					continue
+				}
+				done[key] = true
+
+				from, to, err := actions.RollbackRelease(ctx, release.runtime, base)
+				if err != nil {
+					fmt.Fprintf(e.stderr, "[%s] %s●%s Rollback %s: failed - %v\n", host.Name, ctc.ForegroundRed, ctc.Reset, base, err)
+					if !result.Failed {
+						result.Failed = true
+						result.FailedStep = step.Name
+						result.FailedHost = host.Name
+						result.Error = fmt.Errorf("rollback failed on host %s: %w", host.Name, err)
+					}
+					continue
+				}
+				fmt.Fprintf(e.stdout, "[%s] %s●%s Rollback %s: %s -> %s\n", host.Name, ctc.ForegroundGreen, ctc.Reset, base, from, to)
+			}
+			hostLogger.Close()
+		}
+	}
+
+	result.EndTime = time.Now()
+
+	if result.Failed {
+		return result, result.Error
+	}
+
+	fmt.Fprintln(e.stdout)
+	e.ui.Success("Rollback completed successfully")
+	e.ui.Info("Duration: %s", result.EndTime.Sub(result.StartTime))
+	return result, nil
+}
+
+// hostRelease is a release action with the runtime of the job declaring it
+type hostRelease struct {
+	action  *schema.ActionRelease
+	runtime *types.Runtime
+}
+
+// hostReleases collects the release actions of a job, including its
+// on_failure and finally actions and included jobs, which run with their own
+// env
+func (e *executor) hostReleases(file *schema.File, job *schema.Job, runtime *types.Runtime) ([]hostRelease, error) {
+	var found []hostRelease
+	for _, action := range job.AllActions() {
+		switch {
+		case action.Release != nil:
+			found = append(found, hostRelease{action: action.Release, runtime: runtime})
+		case action.IncludeJob != nil:
+			included, err := e.loadJob(file, action.IncludeJob.Job)
+			if err != nil {
+				return nil, err
+			}
+			if !hasRelease(file, included) {
+				continue
+			}
+			includedRuntime, err := includeRuntime(included, action.IncludeJob, runtime)
+			if err != nil {
+				return nil, err
+			}
+			nested, err := e.hostReleases(file, included, includedRuntime)
+			if err != nil {
+				return nil, err
+			}
+			found = append(found, nested...)
+		}
+	}
+	return found, nil
+}
+
+// hasRelease reports whether a job or a job it includes has a release action;
+// include cycles are rejected by the loader
+func hasRelease(file *schema.File, job *schema.Job) bool {
+	for _, action := range job.AllActions() {
+		if action.Release != nil {
+			return true
+		}
+		if action.IncludeJob != nil {
+			if included, ok := file.Jobs[action.IncludeJob.Job]; ok && hasRelease(file, &included) {
+				return true
+			}
+		}
+	}
+	return false
+}
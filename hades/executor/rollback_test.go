@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+func TestRollback_NestedReleases(t *testing.T) {
+	client := &fakeClient{
+		run: func(ctx context.Context, host ssh.Host, cmd string, env map[string]string, stdout io.Writer) error {
+			switch {
+			case strings.HasPrefix(cmd, "readlink "):
+				io.WriteString(stdout, "releases/r2\n")
+			case strings.HasPrefix(cmd, "ls -1 "), strings.HasPrefix(cmd, "cat "):
+				io.WriteString(stdout, "r1\nr2\n")
+			}
+			return nil
+		},
+	}
+	e, _ := newTestExecutor(t, client)
+
+	run := schema.ActionRun("true")
+	file := &schema.File{
+		Jobs: map[string]schema.Job{
+			"deploy": {
+				Env: map[string]schema.Env{"APP": {}},
+				Actions: []schema.Action{
+					{Run: &run},
+					{IncludeJob: &schema.ActionIncludeJob{Job: "ship", Env: map[string]string{"DIR": "/opt/${APP}"}}},
+				},
+				Finally: []schema.Action{{Release: &schema.ActionRelease{Path: "/srv/assets", Src: "assets.tar.gz", Extract: true}}},
+			},
+			"ship": {
+				Env:     map[string]schema.Env{"DIR": {}},
+				Actions: []schema.Action{{Release: &schema.ActionRelease{Path: "${DIR}", Src: "app"}}},
+			},
+		},
+	}
+	plan := &schema.Plan{Steps: []schema.Step{{Name: "deploy", Job: "deploy", Targets: []string{"web"}}}}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1"}})
+
+	result, err := e.Rollback(context.Background(), file, plan, "p", inv, nil, map[string]string{"APP": "shop"})
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if result.Failed {
+		t.Fatalf("Expected rollback to succeed, got %v", result.Error)
+	}
+
+	for _, base := range []string{"/opt/shop", "/srv/assets"} {
+		want := "ln -sfn 'releases/r1' '" + base + "/.current.tmp'"
+		if _, ok := client.find("web-1", want); !ok {
+			t.Errorf("Expected %s to be switched to r1, got %v", base, client.hostCommands("web-1"))
+		}
+	}
+}
+
+func TestRollback_NoReleases(t *testing.T) {
+	e, _ := newTestExecutor(t, &fakeClient{})
+
+	run := schema.ActionRun("true")
+	file := &schema.File{Jobs: map[string]schema.Job{"deploy": {Actions: []schema.Action{{Run: &run}}}}}
+	plan := &schema.Plan{Steps: []schema.Step{{Job: "deploy", Targets: []string{"web"}}}}
+
+	_, err := e.Rollback(context.Background(), file, plan, "p", newFakeInventory(nil), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "has no release actions") {
+		t.Fatalf("Expected no release actions error, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+)
+
+// forwardClient opens real local forwards and counts them
+type forwardClient struct {
+	*fakeClient
+	mu     sync.Mutex
+	opened int
+}
+
+func (c *forwardClient) Forward(ctx context.Context, host ssh.Host, remoteAddr string, localPort int) (*ssh.Forward, error) {
+	fwd, err := ssh.NewLocalClient("").Forward(ctx, host, remoteAddr, localPort)
+	if err == nil {
+		c.mu.Lock()
+		c.opened++
+		c.mu.Unlock()
+	}
+	return fwd, err
+}
+
+func TestExecuteStep_SharedTunnelPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	client := &forwardClient{fakeClient: &fakeClient{}}
+	e, _ := newTestExecutor(t, client)
+
+	// Both hosts run at once and hold the tunnel while they run
+	probe := schema.ActionRun(`sleep 0.2 && test "$HADES_TUNNEL_PORT" = ` + strconv.Itoa(port))
+	file := &schema.File{Jobs: map[string]schema.Job{
+		"migrate": {Local: true, Actions: []schema.Action{{Run: &probe}}},
+	}}
+	plan := &schema.Plan{Steps: []schema.Step{{
+		Job:     "migrate",
+		Targets: []string{"web"},
+		Tunnel:  &schema.Tunnel{Host: "db-1", Remote: "10.0.0.5:5432", LocalPort: port},
+	}}}
+	inv := newFakeInventory(map[string][]string{"web": {"web-1", "web-2"}, "db": {"db-1"}})
+
+	if result, err := e.ExecutePlan(context.Background(), file, plan, "p", inv, nil, nil); err != nil {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+	if client.opened != 1 {
+		t.Errorf("Expected one shared forward, got %d", client.opened)
+	}
+
+	// The forward is closed once the step is done
+	listener, err = net.Listen("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected the local port to be free again: %v", err)
+	}
+	listener.Close()
+}
@@ -33,7 +33,6 @@ func (h *Hades) runInit() error {
 		{filename: "hades/example/plans.hades.yaml", content: plansTemplate},
 		{filename: "hades/example/jobs.hades.yaml", content: jobsTemplate},
 		{filename: "hades/example/tpl/sample", content: serverTemplate},
-		{filename: "hades/example/tpl/apt-caddy", content: aptCaddyTemplate},
 		{filename: "hades/example/tpl/Caddyfile", content: caddyfileTemplate},
 	}
 
@@ -78,17 +77,6 @@ while true; do
 done
 `
 
-var aptCaddyTemplate = `
-# Source: Caddy
-# Site: https://github.com/caddyserver/caddy
-# Repository: Caddy / stable
-# Description: Fast, multi-platform web server with automatic HTTPS
-
-deb [signed-by=/usr/share/keyrings/caddy-stable-archive-keyring.gpg] https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main
-
-deb-src [signed-by=/usr/share/keyrings/caddy-stable-archive-keyring.gpg] https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main
-`
-
 var caddyfileTemplate = `# This file was generated during hades run: {{.Env.HADES_RUN_ID}}
 # Do not edit manually.
 
@@ -130,41 +118,33 @@ var jobsTemplate = `jobs:
       if: "! which caddy"
     actions:
       - name: Install deps
-        run: |
-          apt install -y \
-            vim \
-            wget \
-            curl \
-            unzip \
-            debian-keyring debian-archive-keyring \
-            apt-transport-https
-
-      - name: GPG
-        gpg:
-          src: https://dl.cloudsmith.io/public/caddy/stable/gpg.key
-          path: /usr/share/keyrings/caddy-stable-archive-keyring.gpg
-          dearmor: true
-
-      - name: Configure apt
-        copy:
-          src: tpl/apt-caddy.list
-          dst: /etc/apt/sources.list.d/caddy.list
-          mode: 0644
-
-      - name: Update permissions
-        run: |
-          set -e
-          chmod o+r /usr/share/keyrings/caddy-stable-archive-keyring.gpg
-          chmod o+r /etc/apt/sources.list.d/caddy.list
+        package:
+          names:
+            - vim
+            - wget
+            - curl
+            - unzip
+            - debian-keyring
+            - debian-archive-keyring
+            - apt-transport-https
+          update_cache: true
 
       - name: Install
-        run: |
-          set -e
-          apt update
-          apt install caddy
+        package:
+          names: [caddy]
+          repository:
+            name: caddy-stable
+            key: https://dl.cloudsmith.io/public/caddy/stable/gpg.key
+            dearmor: true
+            source: |
+              deb https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main
+              deb-src https://dl.cloudsmith.io/public/caddy/stable/deb/debian any-version main
 
       - name: Start and enable service
-        run: systemctl enable --now caddy
+        service:
+          name: caddy
+          state: started
+          enabled: true
 
   update-caddy:
     env:
@@ -174,9 +154,15 @@ var jobsTemplate = `jobs:
         template:
           src: tpl/Caddyfile
           dst: /etc/caddy/Caddyfile
+        notify: [reload caddy]
 
-      - name: Reload config
-        run: systemctl reload caddy
+    # Handlers run once after the actions, only if an action notifying them
+    # changed something
+    handlers:
+      - name: reload caddy
+        service:
+          name: caddy
+          state: reloaded
 
   build:
     local: true
@@ -218,7 +204,9 @@ var jobsTemplate = `jobs:
         ln -sfn /app/releases/${TAG} /app/current
 
     # - name: Restart
-    # run: systemctl restart app
+    #   service:
+    #     name: app
+    #     state: restarted
 `
 
 var plansTemplate = `plans:
@@ -0,0 +1,302 @@
+package hades
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/inventory"
+	"github.com/SoftKiwiGames/hades/hades/ssh"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// inventoryHost is a host as printed by 'hades inventory'
+type inventoryHost struct {
+	Name    string            `json:"name" yaml:"name"`
+	Address string            `json:"address" yaml:"address"`
+	User    string            `json:"user,omitempty" yaml:"user,omitempty"`
+	Port    int               `json:"port" yaml:"port"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Source  string            `json:"source" yaml:"source"`
+}
+
+// inventoryTarget is a target group with its entries as defined and the hosts
+// they resolve to
+type inventoryTarget struct {
+	Name    string   `json:"name" yaml:"name"`
+	Entries []string `json:"entries" yaml:"entries"`
+	Hosts   []string `json:"hosts" yaml:"hosts"`
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// inventoryHostDetails adds a host's vars: its own, and the merged vars it
+// gets through each target it belongs to
+type inventoryHostDetails struct {
+	inventoryHost `yaml:",inline"`
+	Vars          map[string]string            `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Targets       map[string]map[string]string `json:"targets,omitempty" yaml:"targets,omitempty"`
+}
+
+func (h *Hades) buildInventoryCommand() *cobra.Command {
+	var configDir, output string
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Inspect hosts and targets",
+	}
+
+	cmd.PersistentFlags().StringVarP(&configDir, "config-dir", "c", ".", "Directory to search for YAML config files (default: current directory)")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "Output format: table, json or yaml")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:           "list",
+		Short:         "List hosts with their address, labels and source",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.inventoryList(configDir, output)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:           "targets",
+		Short:         "List targets and the hosts they resolve to",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.inventoryTargets(configDir, output)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:           "show [host]",
+		Short:         "Show a host with its merged vars",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.inventoryShow(configDir, output, args[0])
+		},
+	})
+
+	return cmd
+}
+
+// loadInventory checks the output format before loading, so that a typo does
+// not wait on cloud providers first
+func (h *Hades) loadInventory(configDir, output string) (inventory.Inventory, error) {
+	switch output {
+	case "table", "json", "yaml":
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected table, json or yaml)", output)
+	}
+
+	inv, err := inventory.LoadDirectory(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inventory: %w", err)
+	}
+	return inv, nil
+}
+
+func (h *Hades) inventoryList(configDir, output string) error {
+	inv, err := h.loadInventory(configDir, output)
+	if err != nil {
+		return err
+	}
+
+	hosts := sortedHosts(inv.AllHosts())
+	list := make([]inventoryHost, len(hosts))
+	for i, host := range hosts {
+		list[i] = toInventoryHost(host)
+	}
+
+	if output != "table" {
+		return h.printStructured(output, list)
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(h.stdout, "No hosts found.")
+		return nil
+	}
+
+	rows := make([][]string, len(list))
+	for i, host := range list {
+		rows[i] = []string{host.Name, host.Address, orDash(host.User), strconv.Itoa(host.Port), orDash(formatTags(host.Labels)), host.Source}
+	}
+	h.printTable([]string{"NAME", "ADDRESS", "USER", "PORT", "LABELS", "SOURCE"}, rows, 1)
+	return nil
+}
+
+func (h *Hades) inventoryTargets(configDir, output string) error {
+	inv, err := h.loadInventory(configDir, output)
+	if err != nil {
+		return err
+	}
+
+	list := resolveTargets(inv)
+	if output != "table" {
+		return h.printStructured(output, list)
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(h.stdout, "No targets found.")
+		return nil
+	}
+
+	rows := make([][]string, len(list))
+	for i, target := range list {
+		members := strings.Join(target.Hosts, ", ")
+		if target.Error != "" {
+			members = "error: " + target.Error
+		}
+		rows[i] = []string{target.Name, strconv.Itoa(len(target.Hosts)), members}
+	}
+	h.printTable([]string{"TARGET", "COUNT", "HOSTS"}, rows, -1)
+	return nil
+}
+
+func (h *Hades) inventoryShow(configDir, output, name string) error {
+	inv, err := h.loadInventory(configDir, output)
+	if err != nil {
+		return err
+	}
+
+	var details *inventoryHostDetails
+	for _, host := range inv.AllHosts() {
+		if host.Name == name {
+			details = &inventoryHostDetails{inventoryHost: toInventoryHost(host), Vars: host.Vars}
+		}
+	}
+	if details == nil {
+		return fmt.Errorf("host %q not found in inventory", name)
+	}
+
+	// Vars depend on the target a host is resolved through
+	targets := inv.Targets()
+	for _, target := range sortedKeys(targets) {
+		hosts, err := inv.ResolveTarget(target)
+		if err != nil {
+			continue
+		}
+		for _, host := range hosts {
+			if host.Name != name {
+				continue
+			}
+			if details.Targets == nil {
+				details.Targets = make(map[string]map[string]string)
+			}
+			vars := host.Vars
+			if vars == nil {
+				vars = map[string]string{}
+			}
+			details.Targets[target] = vars
+		}
+	}
+
+	if output != "table" {
+		return h.printStructured(output, details)
+	}
+
+	fmt.Fprintf(h.stdout, "Host:     %s\n", details.Name)
+	fmt.Fprintf(h.stdout, "Address:  %s\n", details.Address)
+	fmt.Fprintf(h.stdout, "User:     %s\n", orDash(details.User))
+	fmt.Fprintf(h.stdout, "Port:     %d\n", details.Port)
+	fmt.Fprintf(h.stdout, "Labels:   %s\n", orDash(formatTags(details.Labels)))
+	fmt.Fprintf(h.stdout, "Source:   %s\n", details.Source)
+	fmt.Fprintf(h.stdout, "Targets:  %s\n", orDash(strings.Join(sortedKeys(details.Targets), ", ")))
+
+	h.printVars("Vars", details.Vars)
+	for _, target := range sortedKeys(details.Targets) {
+		h.printVars(fmt.Sprintf("Vars via target %q", target), details.Targets[target])
+	}
+	return nil
+}
+
+func (h *Hades) printVars(title string, vars map[string]string) {
+	fmt.Fprintf(h.stdout, "\n%s:\n", title)
+	if len(vars) == 0 {
+		fmt.Fprintln(h.stdout, "  -")
+		return
+	}
+	for _, key := range sortedKeys(vars) {
+		fmt.Fprintf(h.stdout, "  %s=%s\n", key, vars[key])
+	}
+}
+
+// printStructured prints v as JSON or YAML
+func (h *Hades) printStructured(output string, v any) error {
+	if output == "json" {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(h.stdout, string(data))
+		return nil
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(h.stdout, string(data))
+	return nil
+}
+
+// resolveTargets resolves every target, keeping resolution errors per target
+// so that one broken target does not hide the others
+func resolveTargets(inv inventory.Inventory) []inventoryTarget {
+	targets := inv.Targets()
+	list := make([]inventoryTarget, 0, len(targets))
+	for _, name := range sortedKeys(targets) {
+		target := inventoryTarget{Name: name, Entries: targets[name], Hosts: []string{}}
+		hosts, err := inv.ResolveTarget(name)
+		if err != nil {
+			target.Error = err.Error()
+		}
+		for _, host := range hosts {
+			target.Hosts = append(target.Hosts, host.Name)
+		}
+		list = append(list, target)
+	}
+	return list
+}
+
+func toInventoryHost(host ssh.Host) inventoryHost {
+	port := host.Port
+	if port == 0 {
+		port = 22
+	}
+	return inventoryHost{
+		Name:    host.Name,
+		Address: host.Address,
+		User:    host.User,
+		Port:    port,
+		Labels:  host.Labels,
+		Source:  host.Source,
+	}
+}
+
+func sortedHosts(hosts []ssh.Host) []ssh.Host {
+	sorted := append([]ssh.Host(nil), hosts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
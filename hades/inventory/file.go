@@ -3,9 +3,13 @@ package inventory
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/SoftKiwiGames/hades/hades/selector"
 	"github.com/SoftKiwiGames/hades/hades/ssh"
 	"github.com/SoftKiwiGames/hades/hades/utils"
 	"gopkg.in/yaml.v3"
@@ -14,20 +18,48 @@ import (
 type fileInventory struct {
 	hosts        []ssh.Host
 	targets      map[string][]string
+	targetVars   map[string]map[string]string
 	dynamicHosts []ssh.Host
 }
 
 type inventoryFile struct {
-	Hosts          map[string]hostDef  `yaml:"hosts"`
-	Targets        map[string][]string `yaml:"targets"`
-	HostsProviders []Provider          `yaml:"hosts.providers"`
+	Hosts          map[string]hostDef   `yaml:"hosts"`
+	Targets        map[string]targetDef `yaml:"targets"`
+	HostsProviders []Provider           `yaml:"hosts.providers"`
 }
 
 type hostDef struct {
-	Addr         string `yaml:"addr"`
-	User         string `yaml:"user"`
-	IdentityFile string `yaml:"identity_file"`
-	Port         int    `yaml:"port"`
+	Addr         string            `yaml:"addr"`
+	User         string            `yaml:"user"`
+	IdentityFile string            `yaml:"identity_file"`
+	Port         int               `yaml:"port"`
+	Vars         map[string]string `yaml:"vars"`
+	Labels       map[string]string `yaml:"labels"`
+}
+
+// targetDef is a target group: either a bare list of entries (hosts, other
+// targets, selectors or exclusions) or a mapping with hosts and group vars
+type targetDef struct {
+	Hosts []string          `yaml:"hosts"`
+	Vars  map[string]string `yaml:"vars"`
+}
+
+func (t *targetDef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode(&t.Hosts)
+	}
+	type plain targetDef
+	return node.Decode((*plain)(t))
+}
+
+// validateVars rejects inventory vars that would shadow HADES_* built-ins
+func validateVars(kind, name string, vars map[string]string) error {
+	for key := range vars {
+		if strings.HasPrefix(key, "HADES_") {
+			return fmt.Errorf("%s %q cannot define HADES_* vars: %s", kind, name, key)
+		}
+	}
+	return nil
 }
 
 func LoadFile(path string) (Inventory, error) {
@@ -47,18 +79,33 @@ func LoadFile(path string) (Inventory, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to expand identity_file for host %q: %w", name, err)
 		}
+		if err := validateVars("host", name, h.Vars); err != nil {
+			return nil, err
+		}
 		hostMap[name] = ssh.Host{
 			Name:    name,
 			Address: h.Addr,
 			User:    h.User,
 			KeyPath: keyPath,
 			Port:    h.Port,
+			Vars:    h.Vars,
+			Labels:  h.Labels,
+			Source:  path,
 		}
 	}
 
-	targets := file.Targets
-	if targets == nil {
-		targets = make(map[string][]string)
+	targets := make(map[string][]string)
+	targetVars := make(map[string]map[string]string)
+	for name, t := range file.Targets {
+		if err := validateVars("target", name, t.Vars); err != nil {
+			return nil, err
+		}
+		targets[name] = t.Hosts
+		targetVars[name] = t.Vars
+	}
+
+	for i := range file.HostsProviders {
+		file.HostsProviders[i].dir = filepath.Dir(path)
 	}
 
 	var dynamicHosts []ssh.Host
@@ -78,6 +125,7 @@ func LoadFile(path string) (Inventory, error) {
 	return &fileInventory{
 		hosts:        hosts,
 		targets:      targets,
+		targetVars:   targetVars,
 		dynamicHosts: dynamicHosts,
 	}, nil
 }
@@ -87,6 +135,7 @@ func LoadFile(path string) (Inventory, error) {
 func LoadDirectory(rootPath string) (Inventory, error) {
 	allHosts := make(map[string]ssh.Host)
 	allTargets := make(map[string][]string)
+	allTargetVars := make(map[string]map[string]string)
 	var allProviders []Provider
 
 	err := filepath.WalkDir(rootPath, func(path string, d os.DirEntry, err error) error {
@@ -124,25 +173,42 @@ func LoadDirectory(rootPath string) (Inventory, error) {
 			if err != nil {
 				return fmt.Errorf("failed to expand identity_file for host %q in %s: %w", name, path, err)
 			}
+			if err := validateVars("host", name, h.Vars); err != nil {
+				return fmt.Errorf("%w in %s", err, path)
+			}
+			source, err := filepath.Rel(rootPath, path)
+			if err != nil {
+				source = path
+			}
 			allHosts[name] = ssh.Host{
 				Name:    name,
 				Address: h.Addr,
 				User:    h.User,
 				KeyPath: keyPath,
 				Port:    h.Port,
+				Vars:    h.Vars,
+				Labels:  h.Labels,
+				Source:  source,
 			}
 		}
 
 		// Merge targets
-		for name, hostList := range file.Targets {
+		for name, t := range file.Targets {
 			if _, exists := allTargets[name]; exists {
 				return fmt.Errorf("duplicate target %q found in %s", name, path)
 			}
-			allTargets[name] = hostList
+			if err := validateVars("target", name, t.Vars); err != nil {
+				return fmt.Errorf("%w in %s", err, path)
+			}
+			allTargets[name] = t.Hosts
+			allTargetVars[name] = t.Vars
 		}
 
 		// Collect providers
-		allProviders = append(allProviders, file.HostsProviders...)
+		for _, p := range file.HostsProviders {
+			p.dir = filepath.Dir(path)
+			allProviders = append(allProviders, p)
+		}
 
 		return nil
 	})
@@ -169,11 +235,16 @@ func LoadDirectory(rootPath string) (Inventory, error) {
 	return &fileInventory{
 		hosts:        hosts,
 		targets:      allTargets,
+		targetVars:   allTargetVars,
 		dynamicHosts: dynamicHosts,
 	}, nil
 }
 
 func (f *fileInventory) ResolveTarget(name string) ([]ssh.Host, error) {
+	if selector.IsExpression(name) {
+		return f.selectHosts(name)
+	}
+
 	// Build map of hosts by name for quick lookup
 	hostMap := make(map[string]ssh.Host)
 	for _, h := range f.hosts {
@@ -181,18 +252,8 @@ func (f *fileInventory) ResolveTarget(name string) ([]ssh.Host, error) {
 	}
 
 	// First, try to resolve as a target group
-	hostNames, ok := f.targets[name]
-	if ok {
-		// Resolve host names to Host objects
-		var hosts []ssh.Host
-		for _, name := range hostNames {
-			host, ok := hostMap[name]
-			if !ok {
-				return nil, fmt.Errorf("host %q referenced in target but not defined", name)
-			}
-			hosts = append(hosts, host)
-		}
-		return hosts, nil
+	if _, ok := f.targets[name]; ok {
+		return f.resolveGroup(name, hostMap, nil)
 	}
 
 	// If not a target group, try to resolve as an individual host
@@ -205,6 +266,145 @@ func (f *fileInventory) ResolveTarget(name string) ([]ssh.Host, error) {
 	return nil, fmt.Errorf("target or host %q not found in inventory", name)
 }
 
+// resolveGroup returns the hosts of a target group. Entries are host names,
+// other targets (resolved recursively), selector expressions, or exclusions
+// ("!name" or "-name") removing a host or target's hosts from the result.
+// Hosts appear once, in order of first inclusion. stack holds the targets
+// being resolved, to detect cycles.
+func (f *fileInventory) resolveGroup(name string, hostMap map[string]ssh.Host, stack []string) ([]ssh.Host, error) {
+	for i, outer := range stack {
+		if outer == name {
+			return nil, fmt.Errorf("target cycle: %s -> %s", strings.Join(stack[i:], " -> "), name)
+		}
+	}
+	stack = append(stack, name)
+
+	group := newGroupMembers()
+	excluded := make(map[string]bool)
+	for _, entry := range f.targets[name] {
+		if selector.IsExpression(entry) {
+			members, err := f.selectHosts(entry)
+			if err != nil {
+				return nil, fmt.Errorf("target %q: %w", name, err)
+			}
+			if err := group.add(entry, members); err != nil {
+				return nil, fmt.Errorf("target %q: %w", name, err)
+			}
+			continue
+		}
+
+		exclude := strings.HasPrefix(entry, "!") || strings.HasPrefix(entry, "-")
+		if exclude {
+			entry = entry[1:]
+		}
+		members, err := f.resolveEntry(name, entry, hostMap, stack)
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			for _, host := range members {
+				excluded[host.Name] = true
+			}
+			continue
+		}
+		if err := group.add(entry, members); err != nil {
+			return nil, fmt.Errorf("target %q: %w", name, err)
+		}
+	}
+
+	// Exclusions apply regardless of where they are listed; host vars
+	// override the vars of nested targets, which override this target's
+	result := make([]ssh.Host, 0, len(group.hosts))
+	for _, host := range group.hosts {
+		if excluded[host.Name] {
+			continue
+		}
+		host.Vars = mergeVars(f.targetVars[name], host.Vars)
+		result = append(result, host)
+	}
+	return result, nil
+}
+
+// resolveEntry resolves one entry of target group as a nested target or host
+func (f *fileInventory) resolveEntry(group, entry string, hostMap map[string]ssh.Host, stack []string) ([]ssh.Host, error) {
+	if _, ok := f.targets[entry]; ok {
+		return f.resolveGroup(entry, hostMap, stack)
+	}
+	host, ok := hostMap[entry]
+	if !ok {
+		return nil, fmt.Errorf("host or target %q referenced in target %q but not defined", entry, group)
+	}
+	return []ssh.Host{host}, nil
+}
+
+// groupMembers collects the hosts of a target group, once each in order of
+// first inclusion. A host included through several entries gets the vars of
+// all of them.
+type groupMembers struct {
+	hosts []ssh.Host
+	index map[string]int
+	from  map[string]map[string]string // Entry each host var came from
+}
+
+func newGroupMembers() *groupMembers {
+	return &groupMembers{index: make(map[string]int), from: make(map[string]map[string]string)}
+}
+
+// add includes the hosts of entry. The same var with different values from
+// two entries is an error, as it is for a host in several targets of a step.
+func (g *groupMembers) add(entry string, hosts []ssh.Host) error {
+	for _, host := range hosts {
+		i, seen := g.index[host.Name]
+		if !seen {
+			g.index[host.Name] = len(g.hosts)
+			g.from[host.Name] = make(map[string]string, len(host.Vars))
+			for name := range host.Vars {
+				g.from[host.Name][name] = entry
+			}
+			g.hosts = append(g.hosts, host)
+			continue
+		}
+
+		vars := maps.Clone(g.hosts[i].Vars)
+		if vars == nil {
+			vars = make(map[string]string)
+		}
+		for name, value := range host.Vars {
+			if existing, ok := vars[name]; ok {
+				if existing != value {
+					return fmt.Errorf("host %q gets conflicting values for var %q from targets %q and %q", host.Name, name, g.from[host.Name][name], entry)
+				}
+				continue
+			}
+			vars[name] = value
+			g.from[host.Name][name] = entry
+		}
+		g.hosts[i].Vars = vars
+	}
+	return nil
+}
+
+// selectHosts returns the hosts whose labels match a selector expression,
+// sorted by name so that limit picks the same hosts every run
+func (f *fileInventory) selectHosts(expr string) ([]ssh.Host, error) {
+	var hosts []ssh.Host
+	for _, host := range f.hosts {
+		match, errs := selector.Eval(expr, host.Labels)
+		if errs != nil {
+			return nil, fmt.Errorf("selector %q: %w", expr, errs)
+		}
+		if match {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("selector %q matches no hosts", expr)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts, nil
+}
+
 func (f *fileInventory) AllHosts() []ssh.Host {
 	return f.hosts
 }
@@ -212,3 +412,26 @@ func (f *fileInventory) AllHosts() []ssh.Host {
 func (f *fileInventory) DynamicHosts() []ssh.Host {
 	return f.dynamicHosts
 }
+
+func (f *fileInventory) Targets() map[string][]string {
+	targets := make(map[string][]string, len(f.targets))
+	for name, entries := range f.targets {
+		targets[name] = append([]string(nil), entries...)
+	}
+	return targets
+}
+
+// mergeVars returns group vars overridden by host vars, or nil if both are empty
+func mergeVars(group, host map[string]string) map[string]string {
+	if len(group) == 0 {
+		return host
+	}
+	merged := make(map[string]string, len(group)+len(host))
+	for k, v := range group {
+		merged[k] = v
+	}
+	for k, v := range host {
+		merged[k] = v
+	}
+	return merged
+}
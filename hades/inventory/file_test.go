@@ -0,0 +1,381 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/cloud"
+)
+
+func writeInventory(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "inventory.hades.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadDirectory_Vars(t *testing.T) {
+	dir := writeInventory(t, `
+hosts:
+  app-1:
+    addr: 10.0.0.1
+    vars:
+      PORT: "8081"
+  app-2:
+    addr: 10.0.0.2
+  db-1:
+    addr: 10.0.0.3
+targets:
+  app:
+    hosts: [app-1, app-2]
+    vars:
+      PORT: "8080"
+      REGION: eu
+  db: [db-1]
+`)
+
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	hosts, err := inv.ResolveTarget("app")
+	if err != nil {
+		t.Fatalf("ResolveTarget: %v", err)
+	}
+	want := []map[string]string{
+		{"PORT": "8081", "REGION": "eu"},
+		{"PORT": "8080", "REGION": "eu"},
+	}
+	for i, host := range hosts {
+		if !reflect.DeepEqual(host.Vars, want[i]) {
+			t.Errorf("%s: expected vars %v, got %v", host.Name, want[i], host.Vars)
+		}
+	}
+
+	// Resolved by name, a host only has its own vars
+	hosts, err = inv.ResolveTarget("app-1")
+	if err != nil {
+		t.Fatalf("ResolveTarget: %v", err)
+	}
+	if want := map[string]string{"PORT": "8081"}; !reflect.DeepEqual(hosts[0].Vars, want) {
+		t.Errorf("Expected vars %v, got %v", want, hosts[0].Vars)
+	}
+
+	hosts, err = inv.ResolveTarget("db")
+	if err != nil || len(hosts) != 1 || hosts[0].Name != "db-1" {
+		t.Fatalf("Expected bare target list to resolve to db-1, got %v (%v)", hosts, err)
+	}
+	if hosts[0].Source != "inventory.hades.yaml" {
+		t.Errorf("Expected source relative to the directory, got %q", hosts[0].Source)
+	}
+
+	wantTargets := map[string][]string{"app": {"app-1", "app-2"}, "db": {"db-1"}}
+	if got := inv.Targets(); !reflect.DeepEqual(got, wantTargets) {
+		t.Errorf("Expected targets %v, got %v", wantTargets, got)
+	}
+}
+
+func TestLoadDirectory_BuiltinVars(t *testing.T) {
+	dir := writeInventory(t, `
+hosts:
+  app-1:
+    addr: 10.0.0.1
+targets:
+  app:
+    hosts: [app-1]
+    vars:
+      HADES_HOST_NAME: other
+`)
+
+	_, err := LoadDirectory(dir)
+	if err == nil || !strings.Contains(err.Error(), "HADES_HOST_NAME") {
+		t.Fatalf("Expected HADES_* vars error, got %v", err)
+	}
+}
+
+func TestResolveTarget_Selector(t *testing.T) {
+	dir := writeInventory(t, `
+hosts:
+  web-2:
+    addr: 10.0.0.2
+    labels: {role: web, region: eu}
+  web-1:
+    addr: 10.0.0.1
+    labels: {role: web, region: eu}
+  web-3:
+    addr: 10.0.0.3
+    labels: {role: web, region: us}
+  db-1:
+    addr: 10.0.0.4
+    labels: {role: db, region: eu}
+`)
+
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	hosts, err := inv.ResolveTarget(`role == "web" && region == "eu"`)
+	if err != nil {
+		t.Fatalf("ResolveTarget: %v", err)
+	}
+	var names []string
+	for _, host := range hosts {
+		names = append(names, host.Name)
+	}
+	if want := []string{"web-1", "web-2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	if _, err := inv.ResolveTarget(`role == "cache"`); err == nil || !strings.Contains(err.Error(), "matches no hosts") {
+		t.Errorf("Expected no match error, got %v", err)
+	}
+	if _, err := inv.ResolveTarget(`name =~ "["`); err == nil || !strings.Contains(err.Error(), "invalid regex") {
+		t.Errorf("Expected regex error, got %v", err)
+	}
+}
+
+func TestResolveTarget_Nested(t *testing.T) {
+	dir := writeInventory(t, `
+hosts:
+  web-1: {addr: 10.0.0.1}
+  web-2: {addr: 10.0.0.2, vars: {PORT: "8082"}}
+  db-1: {addr: 10.0.0.3}
+  db-2: {addr: 10.0.0.4}
+targets:
+  prod-web:
+    hosts: [web-1, web-2]
+    vars: {PORT: "8080", TIER: web}
+  prod-db: [db-1, db-2]
+  prod:
+    hosts: [prod-web, prod-db, web-1, "!db-2"]
+    vars: {TIER: prod, ENV: production}
+  prod-no-db: [prod, -prod-db]
+  loop-a: [loop-b]
+  loop-b: [web-1, loop-a]
+  broken: [prod-web, missing]
+`)
+
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	names := func(target string) []string {
+		t.Helper()
+		hosts, err := inv.ResolveTarget(target)
+		if err != nil {
+			t.Fatalf("ResolveTarget(%q): %v", target, err)
+		}
+		var names []string
+		for _, host := range hosts {
+			names = append(names, host.Name)
+		}
+		return names
+	}
+
+	if got, want := names("prod"), []string{"web-1", "web-2", "db-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prod: expected %v, got %v", want, got)
+	}
+	if got, want := names("prod-no-db"), []string{"web-1", "web-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prod-no-db: expected %v, got %v", want, got)
+	}
+
+	// Host vars > nested target vars > outer target vars
+	hosts, _ := inv.ResolveTarget("prod")
+	wantVars := map[string]string{"PORT": "8082", "TIER": "web", "ENV": "production"}
+	if !reflect.DeepEqual(hosts[1].Vars, wantVars) {
+		t.Errorf("Expected vars %v, got %v", wantVars, hosts[1].Vars)
+	}
+
+	if _, err := inv.ResolveTarget("loop-a"); err == nil || !strings.Contains(err.Error(), "target cycle: loop-a -> loop-b -> loop-a") {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+	if _, err := inv.ResolveTarget("broken"); err == nil || !strings.Contains(err.Error(), `"missing" referenced in target "broken"`) {
+		t.Errorf("Expected undefined entry error, got %v", err)
+	}
+}
+
+func TestResolveTarget_OverlappingNested(t *testing.T) {
+	dir := writeInventory(t, `
+hosts:
+  h1: {addr: 10.0.0.1}
+  h2: {addr: 10.0.0.2}
+targets:
+  web:
+    hosts: [h1, h2]
+    vars: {PORT: "80"}
+  eu:
+    hosts: [h1]
+    vars: {REGION: eu}
+  alt:
+    hosts: [h1]
+    vars: {PORT: "8080"}
+  prod: [web, eu]
+  broken: [web, alt]
+`)
+
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	// A host in two nested targets gets the vars of both
+	hosts, err := inv.ResolveTarget("prod")
+	if err != nil {
+		t.Fatalf("ResolveTarget(prod): %v", err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("Expected h1 and h2 once each, got %v", hosts)
+	}
+	if want := map[string]string{"PORT": "80", "REGION": "eu"}; !reflect.DeepEqual(hosts[0].Vars, want) {
+		t.Errorf("Expected vars %v, got %v", want, hosts[0].Vars)
+	}
+
+	_, err = inv.ResolveTarget("broken")
+	if err == nil || !strings.Contains(err.Error(), `host "h1" gets conflicting values for var "PORT" from targets "web" and "alt"`) {
+		t.Errorf("Expected conflicting PORT error, got %v", err)
+	}
+}
+
+func TestLoadDirectory_TerraformProvider(t *testing.T) {
+	dir := writeInventory(t, `
+hosts.providers:
+  - provider: terraform
+    config:
+      state: infra/terraform.tfstate
+    selector: cluster == "app"
+    targets: [app]
+    ssh:
+      user: root
+`)
+	state := `{"version": 4, "resources": [{"mode": "managed", "type": "hcloud_server", "instances": [
+  {"attributes": {"name": "app-1", "ipv4_address": "203.0.113.10", "labels": {"cluster": "app"}}},
+  {"attributes": {"name": "db-1", "ipv4_address": "203.0.113.11", "labels": {"cluster": "db"}}}
+]}]}`
+	if err := os.MkdirAll(filepath.Join(dir, "infra"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "infra", "terraform.tfstate"), []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The state path is relative to the inventory file, not the working directory
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	hosts, err := inv.ResolveTarget("app")
+	if err != nil {
+		t.Fatalf("ResolveTarget: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "app-1" || hosts[0].Address != "203.0.113.10" || hosts[0].User != "root" {
+		t.Fatalf("Unexpected hosts %+v", hosts)
+	}
+	if hosts[0].Labels["cluster"] != "app" || hosts[0].Source != "provider:terraform" {
+		t.Errorf("Expected labels and source from the provider, got %+v", hosts[0])
+	}
+
+	if _, err := inv.ResolveTarget(`cluster == "app"`); err != nil {
+		t.Errorf("Expected selector to match the terraform host, got %v", err)
+	}
+}
+
+func TestLoadDirectory_ExecProvider(t *testing.T) {
+	dir := writeInventory(t, `
+hosts.providers:
+  - provider: exec
+    config:
+      command: ./cmdb.sh
+    targets: [cmdb]
+    ssh:
+      user: root
+      port: 22
+`)
+	script := `#!/bin/sh
+echo '{"hosts": [
+  {"name": "pve-1", "ipv4": "10.0.0.1", "tags": {"role": "hypervisor"}},
+  {"name": "pve-2", "ipv4": "10.0.0.2", "ssh": {"user": "admin", "port": 2222}}
+]}'
+`
+	if err := os.WriteFile(filepath.Join(dir, "cmdb.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	hosts, err := inv.ResolveTarget("cmdb")
+	if err != nil {
+		t.Fatalf("ResolveTarget: %v", err)
+	}
+	got := make(map[string]string)
+	for _, host := range hosts {
+		got[host.Name] = fmt.Sprintf("%s@%s:%d", host.User, host.Address, host.Port)
+	}
+	want := map[string]string{"pve-1": "root@10.0.0.1:22", "pve-2": "admin@10.0.0.2:2222"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestLoadDirectory_FakeProvider(t *testing.T) {
+	fake := &cloud.FakeProvider{
+		ProviderName: "test-inventory",
+		Schema:       []cloud.Field{{Name: "catalog", Path: true}, {Name: "zone", Required: true}},
+		Result: []cloud.CloudInstance{
+			{Name: "a", PublicIPv4: net.ParseIP("10.0.0.1"), Tags: map[string]string{"zone": "eu"}},
+			{Name: "b", PublicIPv4: net.ParseIP("10.0.0.2"), Tags: map[string]string{"zone": "us"}},
+		},
+	}
+	cloud.Register(fake)
+	t.Cleanup(func() { cloud.Unregister(fake.Name()) })
+
+	dir := writeInventory(t, `
+hosts.providers:
+  - provider: test-inventory
+    config:
+      catalog: hosts.json
+      zone: eu
+    selector: zone == "eu"
+    targets: [eu]
+`)
+
+	inv, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+	if got := fake.Calls[0].Get("catalog"); got != filepath.Join(dir, "hosts.json") {
+		t.Errorf("Expected catalog relative to the inventory file, got %q", got)
+	}
+
+	hosts, err := inv.ResolveTarget("eu")
+	if err != nil {
+		t.Fatalf("ResolveTarget: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "a" || hosts[0].Source != "provider:test-inventory" {
+		t.Errorf("Unexpected hosts %+v", hosts)
+	}
+
+	// Config is validated against the provider's fields
+	dir = writeInventory(t, `
+hosts.providers:
+  - provider: test-inventory
+    config:
+      zones: eu
+`)
+	if _, err := LoadDirectory(dir); err == nil || !strings.Contains(err.Error(), `unknown config key "zones"`) {
+		t.Errorf("Expected unknown config key error, got %v", err)
+	}
+}
@@ -6,4 +6,7 @@ type Inventory interface {
 	ResolveTarget(name string) ([]ssh.Host, error)
 	AllHosts() []ssh.Host
 	DynamicHosts() []ssh.Host
+	// Targets returns the entries of each target group as defined, including
+	// hosts added by providers
+	Targets() map[string][]string
 }
@@ -6,6 +6,8 @@ type Provider struct {
 	Selector string            `yaml:"selector"`
 	Targets  []string          `yaml:"targets"`
 	SSH      ProviderSSH       `yaml:"ssh"`
+
+	dir string // Directory of the inventory file, for relative paths in config
 }
 
 type ProviderSSH struct {
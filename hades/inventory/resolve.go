@@ -3,7 +3,6 @@ package inventory
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/SoftKiwiGames/hades/hades/cloud"
 	"github.com/SoftKiwiGames/hades/hades/selector"
@@ -54,23 +53,11 @@ func resolveProviders(ctx context.Context, providers []Provider, hosts map[strin
 	return dynamic, nil
 }
 
-func expandEnv(s string) string {
-	return os.Expand(s, os.Getenv)
-}
-
+// fetchInstances lists a provider's instances through the cloud registry,
+// which also validates its config; relative paths are resolved against the
+// inventory file that declared the provider
 func fetchInstances(ctx context.Context, p Provider) ([]cloud.CloudInstance, error) {
-	switch p.Provider {
-	case "hetzner":
-		token := expandEnv(p.Config["token"])
-		return cloud.HetznerInstances(ctx, cloud.HetznerConfig{Token: token})
-	case "aws":
-		return cloud.AWSInstances(ctx, cloud.AWSConfig{
-			Profile: p.Config["profile"],
-			Region:  p.Config["region"],
-		})
-	default:
-		return nil, fmt.Errorf("unknown provider %q", p.Provider)
-	}
+	return cloud.Fetch(ctx, p.Provider, p.Config, p.dir)
 }
 
 func instanceToHost(inst cloud.CloudInstance, p Provider) (ssh.Host, error) {
@@ -86,10 +73,25 @@ func instanceToHost(inst cloud.CloudInstance, p Provider) (ssh.Host, error) {
 		Address: addr,
 		User:    p.SSH.User,
 		Port:    p.SSH.Port,
+		Labels:  inst.Tags,
+		Source:  "provider:" + p.Provider,
+	}
+
+	identityFile := p.SSH.IdentityFile
+	if inst.SSH != nil {
+		if inst.SSH.User != "" {
+			host.User = inst.SSH.User
+		}
+		if inst.SSH.Port != 0 {
+			host.Port = inst.SSH.Port
+		}
+		if inst.SSH.IdentityFile != "" {
+			identityFile = inst.SSH.IdentityFile
+		}
 	}
 
-	if p.SSH.IdentityFile != "" {
-		keyPath, err := utils.ExpandPath(p.SSH.IdentityFile)
+	if identityFile != "" {
+		keyPath, err := utils.ExpandPath(identityFile)
 		if err != nil {
 			return ssh.Host{}, fmt.Errorf("failed to expand identity_file: %w", err)
 		}
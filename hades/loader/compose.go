@@ -0,0 +1,314 @@
+package loader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+)
+
+// flattenPlans replaces steps that reference another plan with that plan's
+// steps, recursively, and expands matrix steps, so that validation and
+// execution only see plain job steps. Included steps are named
+// "<step>/<inner step>", matrix entries "<step>[KEY=value]".
+func flattenPlans(plans map[string]schema.Plan, jobs map[string]schema.Job) error {
+	names := make([]string, 0, len(plans))
+	for name := range plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flat := make(map[string][]schema.Step, len(plans))
+	for _, name := range names {
+		steps, err := flattenPlan(plans, jobs, name, nil)
+		if err != nil {
+			return fmt.Errorf("plan %q: %w", name, err)
+		}
+		flat[name] = steps
+	}
+
+	for name, steps := range flat {
+		plan := plans[name]
+		plan.Steps = steps
+		plans[name] = plan
+	}
+	return nil
+}
+
+// flattenPlan returns the steps of a plan with included plans expanded.
+// stack holds the plans being expanded, to detect recursion.
+func flattenPlan(plans map[string]schema.Plan, jobs map[string]schema.Job, name string, stack []string) ([]schema.Step, error) {
+	for i, outer := range stack {
+		if outer == name {
+			return nil, fmt.Errorf("plan recursion: %s -> %s", strings.Join(stack[i:], " -> "), name)
+		}
+	}
+	stack = append(stack, name)
+	plan := plans[name]
+
+	// Each step becomes a group: itself, or the flattened steps of its plan
+	groups := make([][]schema.Step, len(plan.Steps))
+	dag := plan.UsesNeeds()
+	for i, step := range plan.Steps {
+		if step.Plan == "" && step.Matrix == nil {
+			if step.MatrixParallelism != 0 {
+				return nil, fmt.Errorf("step %q: matrix_parallelism requires a matrix", step.ID())
+			}
+			groups[i] = []schema.Step{step}
+			continue
+		}
+
+		if step.Matrix != nil {
+			if step.Plan != "" {
+				return nil, fmt.Errorf("step %q: matrix applies to job steps only", step.ID())
+			}
+			if plan.Strategy == schema.StrategyPipeline && step.MatrixParallelism > 1 {
+				return nil, fmt.Errorf("step %q: matrix_parallelism cannot be used in a pipeline plan, hosts already move through the entries independently", step.ID())
+			}
+			entries, err := expandMatrix(step, jobs)
+			if err != nil {
+				return nil, fmt.Errorf("step %q: %w", step.ID(), err)
+			}
+			groups[i] = entries
+			continue
+		}
+
+		if step.Job != "" {
+			return nil, fmt.Errorf("step %q: set either 'job' or 'plan', not both", step.ID())
+		}
+		if step.Parallelism != "" || step.Limit != 0 || step.Tunnel != nil {
+			return nil, fmt.Errorf("step %q: parallelism, limit and tunnel apply to job steps only", step.ID())
+		}
+		inner, ok := plans[step.Plan]
+		if !ok {
+			return nil, fmt.Errorf("step %q references non-existent plan %q", step.ID(), step.Plan)
+		}
+		// Only the including plan's on_failure and strategy apply
+		if inner.OnFailure != nil {
+			return nil, fmt.Errorf("step %q: plan %q defines on_failure and cannot be included", step.ID(), step.Plan)
+		}
+		if inner.Strategy == schema.StrategyPipeline {
+			return nil, fmt.Errorf("step %q: plan %q uses strategy pipeline and cannot be included", step.ID(), step.Plan)
+		}
+		innerSteps, err := flattenPlan(plans, jobs, step.Plan, stack)
+		if err != nil {
+			return nil, err
+		}
+		if plan.Strategy == schema.StrategyPipeline {
+			if stepsUseNeeds(innerSteps) {
+				return nil, fmt.Errorf("step %q: plan %q uses needs and cannot be included in a pipeline plan", step.ID(), step.Plan)
+			}
+			for _, innerStep := range innerSteps {
+				if innerStep.MatrixParallel > 1 {
+					return nil, fmt.Errorf("step %q: plan %q uses matrix_parallelism and cannot be included in a pipeline plan", step.ID(), step.Plan)
+				}
+			}
+		}
+
+		group := make([]schema.Step, len(innerSteps))
+		for j, innerStep := range innerSteps {
+			flatStep := innerStep
+			flatStep.Name = step.ID() + "/" + innerStep.ID()
+			if innerStep.MatrixOf != "" {
+				flatStep.MatrixOf = step.ID() + "/" + innerStep.MatrixOf
+			}
+
+			flatStep.Needs = nil
+			for _, need := range innerStep.Needs {
+				flatStep.Needs = append(flatStep.Needs, step.ID()+"/"+need)
+			}
+			if len(flatStep.Needs) > 0 {
+				dag = true
+			}
+
+			// Env priority: including step > inner step > inner plan
+			flatStep.Env = make(map[string]string)
+			for _, env := range []map[string]string{inner.Env, innerStep.Env, step.Env} {
+				for k, v := range env {
+					flatStep.Env[k] = v
+				}
+			}
+
+			if len(step.Targets) > 0 {
+				flatStep.Targets = step.Targets
+			}
+			if flatStep.Parallelism == "" && inner.Strategy != schema.StrategyPipeline {
+				flatStep.Parallelism = inner.Parallelism
+			}
+			group[j] = flatStep
+		}
+		groups[i] = group
+	}
+
+	if !dag {
+		var steps []schema.Step
+		for _, group := range groups {
+			steps = append(steps, group...)
+		}
+		return steps, nil
+	}
+	return groupsToGraph(plan, groups), nil
+}
+
+// groupsToGraph joins step groups into one dependency graph once any of them
+// uses needs, turning the order of sequential plans into explicit needs
+func groupsToGraph(plan schema.Plan, groups [][]schema.Step) []schema.Step {
+	index := make(map[string]int)
+	for i, step := range plan.Steps {
+		index[step.ID()] = i
+	}
+	groupIDs := make([][]string, len(groups))
+	for i, group := range groups {
+		for _, step := range group {
+			groupIDs[i] = append(groupIDs[i], step.ID())
+		}
+	}
+
+	var steps []schema.Step
+	var previous []string
+	for i, group := range groups {
+		outer := plan.Steps[i]
+
+		// What the first steps of this group wait for
+		var entry []string
+		if plan.UsesNeeds() {
+			for _, need := range outer.Needs {
+				if j, ok := index[need]; ok {
+					entry = append(entry, groupIDs[j]...)
+				} else {
+					entry = append(entry, need) // Reported by validation
+				}
+			}
+		} else {
+			entry = previous
+		}
+
+		expanded := outer.Plan != "" || outer.Matrix != nil
+		switch {
+		case !expanded:
+			group[0].Needs = entry
+			steps = append(steps, group[0])
+		case stepsUseNeeds(group):
+			for _, step := range group {
+				if len(step.Needs) == 0 {
+					step.Needs = entry
+				}
+				steps = append(steps, step)
+			}
+		default:
+			steps = append(steps, chainSteps(group, entry)...)
+		}
+
+		if len(group) > 0 {
+			previous = groupIDs[i]
+		}
+	}
+	return steps
+}
+
+// chainSteps turns a sequential group into needs: each step needs the one
+// before it, and the first steps need entry. The entries of a matrix step all
+// need the same steps and are needed together; the executor limits how many
+// run at once.
+func chainSteps(group []schema.Step, entry []string) []schema.Step {
+	steps := make([]schema.Step, len(group))
+	previous := entry
+	for start := 0; start < len(group); {
+		// A run is the entries of one matrix step, or a single step
+		end := start + 1
+		for end < len(group) && group[start].MatrixOf != "" && group[end].MatrixOf == group[start].MatrixOf {
+			end++
+		}
+
+		var ids []string
+		for j := start; j < end; j++ {
+			step := group[j]
+			step.Needs = previous
+			steps[j] = step
+			ids = append(ids, step.ID())
+		}
+		previous = ids
+		start = end
+	}
+	return steps
+}
+
+// expandMatrix returns one step per combination of matrix values. The entries
+// record the matrix step, its matrix_parallelism and their values for the
+// executor.
+func expandMatrix(step schema.Step, jobs map[string]schema.Job) ([]schema.Step, error) {
+	if len(step.Matrix) == 0 {
+		return nil, fmt.Errorf("matrix requires at least one variable")
+	}
+	if step.MatrixParallelism < 0 {
+		return nil, fmt.Errorf("invalid matrix_parallelism %d", step.MatrixParallelism)
+	}
+
+	keys := make([]string, 0, len(step.Matrix))
+	for key, values := range step.Matrix {
+		if strings.HasPrefix(key, "HADES_") {
+			return nil, fmt.Errorf("matrix cannot define HADES_* environment variables: %s", key)
+		}
+		if job, ok := jobs[step.Job]; ok {
+			if _, declared := job.Env[key]; !declared {
+				return nil, fmt.Errorf("unknown matrix variable %q (not defined in job %q)", key, step.Job)
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix variable %q has no values", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Cartesian product, the last key varying fastest
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range step.Matrix[key] {
+				entry := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					entry[k] = v
+				}
+				entry[key] = value
+				next = append(next, entry)
+			}
+		}
+		combinations = next
+	}
+
+	parallelism := step.MatrixParallelism
+	if parallelism == 0 {
+		parallelism = 1
+	}
+
+	entries := make([]schema.Step, len(combinations))
+	for i, combination := range combinations {
+		labels := make([]string, len(keys))
+		for j, key := range keys {
+			labels[j] = key + "=" + combination[key]
+		}
+
+		entry := step
+		entry.Name = fmt.Sprintf("%s[%s]", step.ID(), strings.Join(labels, ","))
+		entry.Matrix = nil
+		entry.MatrixParallelism = 0
+		entry.Needs = nil
+		entry.MatrixOf = step.ID()
+		entry.MatrixParallel = parallelism
+		entry.MatrixEnv = combination
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+func stepsUseNeeds(steps []schema.Step) bool {
+	for _, step := range steps {
+		if len(step.Needs) > 0 {
+			return true
+		}
+	}
+	return false
+}
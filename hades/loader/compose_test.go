@@ -0,0 +1,304 @@
+package loader
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+)
+
+func stepNames(steps []schema.Step) []string {
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.ID())
+	}
+	return names
+}
+
+func TestFlattenPlans_Sequential(t *testing.T) {
+	plans := map[string]schema.Plan{
+		"bootstrap": {
+			Env:         map[string]string{"MODE": "inner-plan", "USER": "inner-plan"},
+			Parallelism: "2",
+			Steps: []schema.Step{
+				{Name: "packages", Job: "install", Targets: []string{"web"}},
+				{Name: "users", Job: "users", Targets: []string{"web"}, Env: map[string]string{"USER": "inner-step"}},
+			},
+		},
+		"full": {
+			Steps: []schema.Step{
+				{Plan: "bootstrap", Targets: []string{"db"}, Env: map[string]string{"MODE": "outer-step"}},
+				{Job: "deploy"},
+			},
+		},
+	}
+
+	if err := flattenPlans(plans, nil); err != nil {
+		t.Fatalf("flattenPlans: %v", err)
+	}
+
+	steps := plans["full"].Steps
+	if got, want := stepNames(steps), []string{"bootstrap/packages", "bootstrap/users", "deploy"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected steps %v, got %v", want, got)
+	}
+
+	users := steps[1]
+	if users.Job != "users" || users.Plan != "" {
+		t.Errorf("Expected job step, got job %q plan %q", users.Job, users.Plan)
+	}
+	if want := map[string]string{"MODE": "outer-step", "USER": "inner-step"}; !reflect.DeepEqual(users.Env, want) {
+		t.Errorf("Expected env %v, got %v", want, users.Env)
+	}
+	if !reflect.DeepEqual(users.Targets, []string{"db"}) {
+		t.Errorf("Expected outer targets to override, got %v", users.Targets)
+	}
+	if users.Parallelism != "2" {
+		t.Errorf("Expected inner plan parallelism, got %q", users.Parallelism)
+	}
+	if stepsUseNeeds(steps) {
+		t.Error("Expected sequential plans to stay sequential")
+	}
+}
+
+func TestFlattenPlans_Graph(t *testing.T) {
+	plans := map[string]schema.Plan{
+		"deploy": {
+			Steps: []schema.Step{
+				{Name: "build", Job: "build"},
+				{Name: "eu", Job: "deploy", Needs: []string{"build"}},
+				{Name: "us", Job: "deploy", Needs: []string{"build"}},
+			},
+		},
+		"full": {
+			Steps: []schema.Step{
+				{Name: "prepare", Job: "prepare"},
+				{Name: "rollout", Plan: "deploy"},
+				{Name: "verify", Job: "verify"},
+			},
+		},
+	}
+
+	if err := flattenPlans(plans, nil); err != nil {
+		t.Fatalf("flattenPlans: %v", err)
+	}
+
+	needs := make(map[string][]string)
+	for _, step := range plans["full"].Steps {
+		needs[step.ID()] = step.Needs
+	}
+	want := map[string][]string{
+		"prepare":       nil,
+		"rollout/build": {"prepare"},
+		"rollout/eu":    {"rollout/build"},
+		"rollout/us":    {"rollout/build"},
+		"verify":        {"rollout/build", "rollout/eu", "rollout/us"},
+	}
+	if !reflect.DeepEqual(needs, want) {
+		t.Errorf("Expected needs %v, got %v", want, needs)
+	}
+}
+
+func TestFlattenPlans_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		plans   map[string]schema.Plan
+		wantErr string
+	}{
+		{
+			name: "recursion",
+			plans: map[string]schema.Plan{
+				"a": {Steps: []schema.Step{{Plan: "b"}}},
+				"b": {Steps: []schema.Step{{Plan: "a"}}},
+			},
+			wantErr: "plan recursion: a -> b -> a",
+		},
+		{
+			name:    "unknown plan",
+			plans:   map[string]schema.Plan{"a": {Steps: []schema.Step{{Plan: "missing"}}}},
+			wantErr: `non-existent plan "missing"`,
+		},
+		{
+			name:    "job and plan",
+			plans:   map[string]schema.Plan{"a": {Steps: []schema.Step{{Job: "x", Plan: "b"}}}, "b": {}},
+			wantErr: "either 'job' or 'plan'",
+		},
+		{
+			name:    "limit on plan step",
+			plans:   map[string]schema.Plan{"a": {Steps: []schema.Step{{Plan: "b", Limit: 1}}}, "b": {}},
+			wantErr: "apply to job steps only",
+		},
+		{
+			name: "included plan with on_failure",
+			plans: map[string]schema.Plan{
+				"a": {Steps: []schema.Step{{Plan: "b"}}},
+				"b": {Steps: []schema.Step{{Job: "x"}}, OnFailure: &schema.PlanOnFailure{Job: "restore"}},
+			},
+			wantErr: `plan "b" defines on_failure and cannot be included`,
+		},
+		{
+			name: "included pipeline plan",
+			plans: map[string]schema.Plan{
+				"a": {Steps: []schema.Step{{Plan: "b"}}},
+				"b": {Strategy: schema.StrategyPipeline, Steps: []schema.Step{{Job: "x"}}},
+			},
+			wantErr: `plan "b" uses strategy pipeline and cannot be included`,
+		},
+		{
+			name: "needs included into a pipeline plan",
+			plans: map[string]schema.Plan{
+				"a": {Strategy: schema.StrategyPipeline, Steps: []schema.Step{{Plan: "b"}}},
+				"b": {Steps: []schema.Step{{Name: "x", Job: "x"}, {Job: "y", Needs: []string{"x"}}}},
+			},
+			wantErr: `plan "b" uses needs and cannot be included in a pipeline plan`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := flattenPlans(tt.plans, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFlattenPlans_Matrix(t *testing.T) {
+	jobs := map[string]schema.Job{
+		"deploy": {Env: map[string]schema.Env{"SERVICE": {}, "REGION": {Default: "eu"}, "VERSION": {}}},
+	}
+	plans := map[string]schema.Plan{
+		"sequential": {
+			Steps: []schema.Step{
+				{Name: "deploy", Job: "deploy", Env: map[string]string{"VERSION": "1.0", "SERVICE": "ignored"}, Matrix: map[string][]string{
+					"SERVICE": {"api", "worker"},
+					"REGION":  {"eu", "us"},
+				}},
+			},
+		},
+		"parallel": {
+			Steps: []schema.Step{
+				{Job: "deploy", Matrix: map[string][]string{"SERVICE": {"api", "worker", "cron"}}, MatrixParallelism: 2},
+				{Name: "verify", Job: "verify"},
+			},
+		},
+		"twice": {
+			Steps: []schema.Step{
+				{Name: "first", Plan: "parallel"},
+				{Name: "second", Plan: "parallel"},
+			},
+		},
+		"graph": {
+			Steps: []schema.Step{
+				{Job: "deploy", Matrix: map[string][]string{"SERVICE": {"api", "worker", "cron"}}, MatrixParallelism: 2},
+				{Name: "verify", Job: "verify", Needs: []string{"deploy"}},
+			},
+		},
+	}
+
+	if err := flattenPlans(plans, jobs); err != nil {
+		t.Fatalf("flattenPlans: %v", err)
+	}
+
+	steps := plans["sequential"].Steps
+	want := []string{
+		"deploy[REGION=eu,SERVICE=api]",
+		"deploy[REGION=eu,SERVICE=worker]",
+		"deploy[REGION=us,SERVICE=api]",
+		"deploy[REGION=us,SERVICE=worker]",
+	}
+	if got := stepNames(steps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected steps %v, got %v", want, got)
+	}
+	if wantEnv := map[string]string{"SERVICE": "worker", "REGION": "us"}; !reflect.DeepEqual(steps[3].MatrixEnv, wantEnv) {
+		t.Errorf("Expected matrix env %v, got %v", wantEnv, steps[3].MatrixEnv)
+	}
+	if wantEnv := map[string]string{"VERSION": "1.0", "SERVICE": "ignored"}; !reflect.DeepEqual(steps[3].Env, wantEnv) {
+		t.Errorf("Expected step env %v, got %v", wantEnv, steps[3].Env)
+	}
+	if steps[0].Matrix != nil || stepsUseNeeds(steps) {
+		t.Error("Expected plain sequential entries")
+	}
+
+	// Without needs the plan stays sequential; the executor throttles entries
+	parallel := plans["parallel"].Steps
+	if stepsUseNeeds(parallel) {
+		t.Errorf("Expected matrix_parallelism to keep the plan sequential, got needs")
+	}
+	if parallel[0].MatrixOf != "deploy" || parallel[2].MatrixParallel != 2 || parallel[3].MatrixOf != "" {
+		t.Errorf("Expected entries to record their matrix step and parallelism, got %+v", parallel)
+	}
+
+	// In a graph plan, entries need the same steps; the executor throttles them
+	needs := make(map[string][]string)
+	for _, step := range plans["graph"].Steps {
+		needs[step.ID()] = step.Needs
+	}
+	wantNeeds := map[string][]string{
+		"deploy[SERVICE=api]":    nil,
+		"deploy[SERVICE=worker]": nil,
+		"deploy[SERVICE=cron]":   nil,
+		"verify":                 {"deploy[SERVICE=api]", "deploy[SERVICE=worker]", "deploy[SERVICE=cron]"},
+	}
+	if !reflect.DeepEqual(needs, wantNeeds) {
+		t.Errorf("Expected needs %v, got %v", wantNeeds, needs)
+	}
+	// Entries of the same matrix included twice belong to separate matrices
+	twice := plans["twice"].Steps
+	if twice[0].MatrixOf != "first/deploy" || twice[4].MatrixOf != "second/deploy" {
+		t.Errorf("Expected a matrix per include, got %q and %q", twice[0].MatrixOf, twice[4].MatrixOf)
+	}
+}
+
+func TestFlattenPlans_MatrixErrors(t *testing.T) {
+	jobs := map[string]schema.Job{"deploy": {Env: map[string]schema.Env{"SERVICE": {}}}}
+	tests := []struct {
+		name     string
+		step     schema.Step
+		strategy string
+		wantErr  string
+	}{
+		{
+			name:    "undeclared variable",
+			step:    schema.Step{Job: "deploy", Matrix: map[string][]string{"OTHER": {"a"}}},
+			wantErr: `unknown matrix variable "OTHER"`,
+		},
+		{
+			name:    "no values",
+			step:    schema.Step{Job: "deploy", Matrix: map[string][]string{"SERVICE": {}}},
+			wantErr: "has no values",
+		},
+		{
+			name:    "built-in variable",
+			step:    schema.Step{Job: "deploy", Matrix: map[string][]string{"HADES_TARGET": {"a"}}},
+			wantErr: "HADES_*",
+		},
+		{
+			name:    "parallelism without matrix",
+			step:    schema.Step{Job: "deploy", MatrixParallelism: 2},
+			wantErr: "matrix_parallelism requires a matrix",
+		},
+		{
+			name:    "plan step",
+			step:    schema.Step{Plan: "other", Matrix: map[string][]string{"SERVICE": {"a"}}},
+			wantErr: "job steps only",
+		},
+		{
+			name:     "parallelism in a pipeline plan",
+			step:     schema.Step{Job: "deploy", Matrix: map[string][]string{"SERVICE": {"a", "b"}}, MatrixParallelism: 2},
+			strategy: schema.StrategyPipeline,
+			wantErr:  "matrix_parallelism cannot be used in a pipeline plan",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans := map[string]schema.Plan{"a": {Strategy: tt.strategy, Steps: []schema.Step{tt.step}}, "other": {}}
+			err := flattenPlans(plans, jobs)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
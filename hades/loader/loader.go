@@ -2,8 +2,13 @@ package loader
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/SoftKiwiGames/hades/hades/schema"
 	"github.com/SoftKiwiGames/hades/hades/utils"
@@ -99,6 +104,11 @@ func (l *Loader) LoadDirectory(rootPath string) (*schema.File, error) {
 		return nil, err
 	}
 
+	// Expand steps that run other plans or a matrix
+	if err := flattenPlans(merged.Plans, merged.Jobs); err != nil {
+		return nil, err
+	}
+
 	return merged, nil
 }
 
@@ -125,51 +135,740 @@ func (l *Loader) Validate(file *schema.File) error {
 	// Check that all steps reference existing jobs
 	for planName, plan := range file.Plans {
 		for i, step := range plan.Steps {
-			if _, ok := file.Jobs[step.Job]; !ok {
+			job, ok := file.Jobs[step.Job]
+			if !ok {
 				return fmt.Errorf("plan %q step %d references non-existent job %q", planName, i, step.Job)
 			}
+			if step.Tunnel != nil {
+				if !job.Local {
+					return fmt.Errorf("plan %q step %d: tunnel requires a local job, %q is not local", planName, i, step.Job)
+				}
+				if err := validateTunnel(step.Tunnel); err != nil {
+					return fmt.Errorf("plan %q step %d: %w", planName, i, err)
+				}
+			}
+		}
+	}
+
+	for planName, plan := range file.Plans {
+		if err := validateStepNeeds(&plan); err != nil {
+			return fmt.Errorf("plan %q: %w", planName, err)
+		}
+		if err := validateStrategy(&plan); err != nil {
+			return fmt.Errorf("plan %q: %w", planName, err)
+		}
+	}
+
+	for planName, plan := range file.Plans {
+		if plan.OnFailure == nil {
+			continue
+		}
+		if err := validatePlanOnFailure(file, planName, plan.OnFailure); err != nil {
+			return fmt.Errorf("plan %q: %w", planName, err)
+		}
+	}
+
+	for jobName, job := range file.Jobs {
+		if job.Tunnel == nil {
+			continue
+		}
+		if !job.Local {
+			return fmt.Errorf("job %q: tunnel requires 'local: true'", jobName)
+		}
+		if err := validateTunnel(job.Tunnel); err != nil {
+			return fmt.Errorf("job %q: %w", jobName, err)
 		}
 	}
 
 	// Check that no action has more than one field set
 	for jobName, job := range file.Jobs {
-		for i, action := range job.Actions {
-			count := 0
-			if action.Run != nil {
-				count++
-			}
-			if action.Copy != nil {
-				count++
-			}
-			if action.Template != nil {
-				count++
-			}
-			if action.Mkdir != nil {
-				count++
-			}
-			if action.Push != nil {
-				count++
+		sections := []struct {
+			label   string
+			actions []schema.Action
+		}{
+			{"action", job.Actions},
+			{"on_failure action", job.OnFailure},
+			{"finally action", job.Finally},
+			{"handler", job.Handlers},
+		}
+		for _, section := range sections {
+			for i, action := range section.actions {
+				count := 0
+				if action.Run != nil {
+					count++
+				}
+				if action.Copy != nil {
+					count++
+				}
+				if action.Template != nil {
+					count++
+				}
+				if action.Mkdir != nil {
+					count++
+				}
+				if action.Push != nil {
+					count++
+				}
+				if action.Pull != nil {
+					count++
+				}
+				if action.Wait != nil {
+					count++
+				}
+				if action.Gpg != nil {
+					count++
+				}
+				if action.Fetch != nil {
+					count++
+				}
+				if action.Download != nil {
+					count++
+				}
+				if action.Unarchive != nil {
+					count++
+				}
+				if action.Release != nil {
+					count++
+				}
+				if action.Service != nil {
+					count++
+				}
+				if action.Package != nil {
+					count++
+				}
+				if action.LineInFile != nil {
+					count++
+				}
+				if action.BlockInFile != nil {
+					count++
+				}
+				if action.User != nil {
+					count++
+				}
+				if action.AuthorizedKey != nil {
+					count++
+				}
+				if action.WaitFor != nil {
+					count++
+				}
+				if action.IncludeJob != nil {
+					count++
+				}
+				if count == 0 {
+					return fmt.Errorf("job %q %s %d has no action type set", jobName, section.label, i)
+				}
+				if count > 1 {
+					return fmt.Errorf("job %q %s %d has multiple action types set", jobName, section.label, i)
+				}
+
+				if action.Download != nil {
+					if err := validateDownload(action.Download); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.Unarchive != nil {
+					if err := validateUnarchive(action.Unarchive); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.Release != nil {
+					if err := validateRelease(action.Release); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.Service != nil {
+					if err := validateService(action.Service); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.Package != nil {
+					if err := validatePackage(action.Package); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.LineInFile != nil {
+					if err := validateLineInFile(action.LineInFile); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.BlockInFile != nil {
+					if err := validateBlockInFile(action.BlockInFile); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.User != nil {
+					if err := validateUser(action.User); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.AuthorizedKey != nil {
+					if err := validateAuthorizedKey(action.AuthorizedKey); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.WaitFor != nil {
+					if err := validateWaitFor(action.WaitFor); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
+				if action.IncludeJob != nil {
+					if err := validateIncludeJob(file, &job, action.IncludeJob); err != nil {
+						return fmt.Errorf("job %q %s %d: %w", jobName, section.label, i, err)
+					}
+				}
 			}
-			if action.Pull != nil {
-				count++
+		}
+	}
+
+	for _, jobName := range sortedJobNames(file.Jobs) {
+		job := file.Jobs[jobName]
+		if err := validateHandlers(&job); err != nil {
+			return fmt.Errorf("job %q: %w", jobName, err)
+		}
+	}
+
+	// Included jobs must not include themselves, directly or indirectly
+	visited := make(map[string]bool)
+	for _, jobName := range sortedJobNames(file.Jobs) {
+		if err := checkIncludeCycle(file, jobName, nil, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateHandlers checks that handlers have unique names and that notify
+// only names handlers of the same job. Handlers run after the job's actions
+// succeed, so on_failure, finally and handlers themselves cannot notify.
+func validateHandlers(job *schema.Job) error {
+	handlers := make(map[string]bool, len(job.Handlers))
+	for i, handler := range job.Handlers {
+		if handler.Name == "" {
+			return fmt.Errorf("handler %d requires 'name'", i)
+		}
+		if handlers[handler.Name] {
+			return fmt.Errorf("duplicate handler %q", handler.Name)
+		}
+		handlers[handler.Name] = true
+	}
+
+	for i, action := range job.Actions {
+		if len(action.Notify) > 0 && action.IncludeJob != nil {
+			return fmt.Errorf("action %d: include_job cannot notify handlers", i)
+		}
+		for _, name := range action.Notify {
+			if !handlers[name] {
+				return fmt.Errorf("action %d notifies unknown handler %q", i, name)
 			}
-			if action.Wait != nil {
-				count++
+		}
+	}
+
+	sections := []struct {
+		label   string
+		actions []schema.Action
+	}{
+		{"on_failure action", job.OnFailure},
+		{"finally action", job.Finally},
+		{"handler", job.Handlers},
+	}
+	for _, section := range sections {
+		for i, action := range section.actions {
+			if len(action.Notify) > 0 {
+				return fmt.Errorf("%s %d cannot notify handlers", section.label, i)
 			}
-			if action.Gpg != nil {
-				count++
+		}
+	}
+	return nil
+}
+
+var checksumPattern = regexp.MustCompile(`^sha256:[0-9a-fA-F]{64}$`)
+
+// validateDownload checks required download fields and the checksum pin format
+func validateDownload(d *schema.ActionDownload) error {
+	if d.URL == "" {
+		return fmt.Errorf("download requires 'url'")
+	}
+	if d.Dst == "" {
+		return fmt.Errorf("download requires 'dst'")
+	}
+	if !checksumPattern.MatchString(d.Checksum) {
+		return fmt.Errorf("download requires 'checksum' in the form sha256:<64 hex chars>")
+	}
+	if d.Extract != "" && !archiveFormats[d.Extract] {
+		return fmt.Errorf("download: unsupported extract format %q (expected tar, tar.gz, tar.zst or zip)", d.Extract)
+	}
+	return nil
+}
+
+var archiveFormats = map[string]bool{"tar": true, "tar.gz": true, "tar.zst": true, "zip": true}
+
+// validateUnarchive checks that exactly one archive source and a destination are set
+func validateUnarchive(u *schema.ActionUnarchive) error {
+	sources := 0
+	if u.Src != "" {
+		sources++
+	}
+	if u.Artifact != "" {
+		sources++
+	}
+	if u.Registry != "" {
+		sources++
+		if u.Name == "" || u.Tag == "" {
+			return fmt.Errorf("unarchive from registry requires 'name' and 'tag'")
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("unarchive requires exactly one of 'src', 'artifact' or 'registry'")
+	}
+	if u.Dst == "" {
+		return fmt.Errorf("unarchive requires 'dst'")
+	}
+	if u.Format != "" && !archiveFormats[u.Format] {
+		return fmt.Errorf("unarchive: unsupported format %q (expected tar, tar.gz, tar.zst or zip)", u.Format)
+	}
+	if u.Artifact != "" && u.Format == "" {
+		if _, ok := utils.DetectArchiveFormat(u.Artifact); !ok {
+			return fmt.Errorf("unarchive: cannot detect the format of artifact %q, set 'format'", u.Artifact)
+		}
+	}
+	if u.StripComponents < 0 {
+		return fmt.Errorf("unarchive: strip_components must not be negative")
+	}
+	return nil
+}
+
+// validateRelease checks the release base path, source and retention settings
+func validateRelease(r *schema.ActionRelease) error {
+	if r.Path == "" {
+		return fmt.Errorf("release requires 'path'")
+	}
+	sources := 0
+	if r.Src != "" {
+		sources++
+	}
+	if r.Artifact != "" {
+		sources++
+	}
+	if r.Registry != "" {
+		sources++
+		if r.Name == "" || r.Tag == "" {
+			return fmt.Errorf("release from registry requires 'name' and 'tag'")
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("release requires exactly one of 'src', 'artifact' or 'registry'")
+	}
+	if r.Artifact != "" && r.File == "" && !r.Extract {
+		return fmt.Errorf("release from artifact requires 'file' (or 'extract: true')")
+	}
+	if r.Format != "" {
+		if !r.Extract {
+			return fmt.Errorf("release: 'format' requires 'extract: true'")
+		}
+		if !archiveFormats[r.Format] {
+			return fmt.Errorf("release: unsupported format %q (expected tar, tar.gz, tar.zst or zip)", r.Format)
+		}
+	}
+	// Artifact names rarely carry an extension, so the format cannot be detected
+	if r.Extract && r.Artifact != "" && r.Format == "" {
+		if _, ok := utils.DetectArchiveFormat(r.Artifact); !ok {
+			return fmt.Errorf("release: cannot detect the format of artifact %q, set 'format'", r.Artifact)
+		}
+	}
+	if r.Keep < 0 {
+		return fmt.Errorf("release: keep must not be negative")
+	}
+	return nil
+}
+
+var serviceStates = map[string]bool{"started": true, "stopped": true, "restarted": true, "reloaded": true}
+
+// validateService checks the unit name and that the action has something to do
+func validateService(s *schema.ActionService) error {
+	if s.Name == "" {
+		return fmt.Errorf("service requires 'name'")
+	}
+	if s.State != "" && !serviceStates[s.State] {
+		return fmt.Errorf("service: unsupported state %q (expected started, stopped, restarted or reloaded)", s.State)
+	}
+	if s.State == "" && s.Enabled == nil && !s.DaemonReload {
+		return fmt.Errorf("service requires at least one of 'state', 'enabled' or 'daemon_reload'")
+	}
+	return nil
+}
+
+var packageStates = map[string]bool{"present": true, "absent": true, "latest": true}
+
+var repositoryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validatePackage checks package names, the desired state and the optional repository
+func validatePackage(p *schema.ActionPackage) error {
+	if len(p.Names) == 0 {
+		return fmt.Errorf("package requires 'names'")
+	}
+	for _, name := range p.Names {
+		if name == "" {
+			return fmt.Errorf("package: empty name in 'names'")
+		}
+	}
+	if p.State != "" && !packageStates[p.State] {
+		return fmt.Errorf("package: unsupported state %q (expected present, absent or latest)", p.State)
+	}
+	if r := p.Repository; r != nil {
+		if !repositoryNamePattern.MatchString(r.Name) {
+			return fmt.Errorf("package: repository requires a 'name' made of letters, digits, '.', '_' or '-'")
+		}
+		if r.Source == "" {
+			return fmt.Errorf("package: repository requires 'source'")
+		}
+		if r.Keyring != "" && r.Key == "" {
+			return fmt.Errorf("package: repository 'keyring' requires 'key'")
+		}
+		if p.State == "absent" {
+			return fmt.Errorf("package: repository cannot be combined with state 'absent'")
+		}
+	}
+	return nil
+}
+
+// validateLineInFile checks the target path, state and that the patterns compile
+func validateLineInFile(l *schema.ActionLineInFile) error {
+	if l.Path == "" {
+		return fmt.Errorf("lineinfile requires 'path'")
+	}
+	switch l.State {
+	case "", "present":
+		if l.Line == "" {
+			return fmt.Errorf("lineinfile with state present requires 'line'")
+		}
+	case "absent":
+		if l.Line == "" && l.Regexp == "" {
+			return fmt.Errorf("lineinfile with state absent requires 'line' or 'regexp'")
+		}
+	default:
+		return fmt.Errorf("lineinfile: unsupported state %q (expected present or absent)", l.State)
+	}
+	return validateInsertPosition("lineinfile", l.Regexp, l.InsertAfter, l.InsertBefore)
+}
+
+// validateBlockInFile checks the target path, state, marker and insert position
+func validateBlockInFile(b *schema.ActionBlockInFile) error {
+	if b.Path == "" {
+		return fmt.Errorf("blockinfile requires 'path'")
+	}
+	if b.State != "" && b.State != "present" && b.State != "absent" {
+		return fmt.Errorf("blockinfile: unsupported state %q (expected present or absent)", b.State)
+	}
+	if b.Marker != "" && !strings.Contains(b.Marker, "{mark}") {
+		return fmt.Errorf("blockinfile: marker must contain '{mark}'")
+	}
+	return validateInsertPosition("blockinfile", "", b.InsertAfter, b.InsertBefore)
+}
+
+// validateInsertPosition checks that at most one insert position is set and that
+// all patterns are valid regular expressions
+func validateInsertPosition(action, pattern, after, before string) error {
+	if after != "" && before != "" {
+		return fmt.Errorf("%s: 'insert_after' and 'insert_before' are mutually exclusive", action)
+	}
+	for _, p := range []string{pattern, after, before} {
+		if p == "" || p == "EOF" || p == "BOF" {
+			continue
+		}
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("%s: invalid regexp %q: %w", action, p, err)
+		}
+	}
+	return nil
+}
+
+var accountNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_.-]*\$?$`)
+
+// validAccountName reports whether name is a valid user or group name.
+// Names that reference environment variables are checked at runtime instead.
+func validAccountName(name string) bool {
+	return accountNamePattern.MatchString(name) || strings.Contains(name, "${")
+}
+
+// validateUser checks the user and group names and the desired state
+func validateUser(u *schema.ActionUser) error {
+	if !validAccountName(u.Name) {
+		return fmt.Errorf("user: invalid or missing 'name' %q", u.Name)
+	}
+	if u.State != "" && u.State != "present" && u.State != "absent" {
+		return fmt.Errorf("user: unsupported state %q (expected present or absent)", u.State)
+	}
+	for _, group := range u.Groups {
+		if !validAccountName(group) {
+			return fmt.Errorf("user: invalid group name %q", group)
+		}
+	}
+	return nil
+}
+
+// validateAuthorizedKey checks the user, key source and desired state
+func validateAuthorizedKey(k *schema.ActionAuthorizedKey) error {
+	if k.User == "" {
+		return fmt.Errorf("authorized_key requires 'user'")
+	}
+	sources := 0
+	for _, src := range []string{k.Key, k.Src, k.URL} {
+		if src != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("authorized_key requires exactly one of 'key', 'src' or 'url'")
+	}
+	if k.State != "" && k.State != "present" && k.State != "absent" {
+		return fmt.Errorf("authorized_key: unsupported state %q (expected present or absent)", k.State)
+	}
+	if k.Exclusive && k.State == "absent" {
+		return fmt.Errorf("authorized_key: 'exclusive' cannot be combined with state absent")
+	}
+	return nil
+}
+
+// validateWaitFor checks that exactly one probe is set and that its options are valid
+func validateWaitFor(w *schema.ActionWaitFor) error {
+	probes := 0
+	for _, probe := range []string{w.TCP, w.HTTP, w.File, w.Command} {
+		if probe != "" {
+			probes++
+		}
+	}
+	if probes != 1 {
+		return fmt.Errorf("wait_for requires exactly one of 'tcp', 'http', 'file' or 'command'")
+	}
+	if (w.Status != 0 || w.Body != "") && w.HTTP == "" {
+		return fmt.Errorf("wait_for: 'status' and 'body' require 'http'")
+	}
+	if w.Status != 0 && (w.Status < 100 || w.Status > 599) {
+		return fmt.Errorf("wait_for: invalid status %d", w.Status)
+	}
+	if w.Body != "" {
+		if _, err := regexp.Compile(w.Body); err != nil {
+			return fmt.Errorf("wait_for: invalid body regexp %q: %w", w.Body, err)
+		}
+	}
+	if err := validateDuration("wait_for", "timeout", w.Timeout); err != nil {
+		return err
+	}
+	if err := validateDuration("wait_for", "interval", w.Interval); err != nil {
+		return err
+	}
+	if w.From != "" && w.From != "host" && w.From != "controller" {
+		return fmt.Errorf("wait_for: unsupported from %q (expected host or controller)", w.From)
+	}
+	return nil
+}
+
+// validateDuration checks an optional positive duration field. Values that
+// reference environment variables are checked at runtime instead.
+func validateDuration(action, field, value string) error {
+	if value == "" || strings.Contains(value, "${") {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		return fmt.Errorf("%s: invalid %s %q", action, field, value)
+	}
+	return nil
+}
+
+// validateTunnel checks the tunnel host, remote address and local port
+func validateTunnel(t *schema.Tunnel) error {
+	if t.Host == "" {
+		return fmt.Errorf("tunnel requires 'host'")
+	}
+	if _, port, err := net.SplitHostPort(t.Remote); err != nil || port == "" {
+		return fmt.Errorf("tunnel requires 'remote' in the form host:port")
+	}
+	if t.LocalPort < 0 || t.LocalPort > 65535 {
+		return fmt.Errorf("tunnel: invalid local_port %d", t.LocalPort)
+	}
+	return nil
+}
+
+// validateIncludeJob checks that the included job exists, runs in the same
+// place as the caller and declares every variable the include sets
+func validateIncludeJob(file *schema.File, caller *schema.Job, inc *schema.ActionIncludeJob) error {
+	if inc.Job == "" {
+		return fmt.Errorf("include_job requires 'job'")
+	}
+	included, ok := file.Jobs[inc.Job]
+	if !ok {
+		return fmt.Errorf("include_job references non-existent job %q", inc.Job)
+	}
+	if included.Local != caller.Local {
+		return fmt.Errorf("include_job: job %q must have the same 'local' setting as the including job", inc.Job)
+	}
+	if included.Tunnel != nil {
+		return fmt.Errorf("include_job: job %q defines a tunnel, which only applies to jobs run by a step", inc.Job)
+	}
+	for name := range inc.Env {
+		if strings.HasPrefix(name, "HADES_") {
+			return fmt.Errorf("include_job: cannot define HADES_* environment variables: %s", name)
+		}
+		if _, ok := included.Env[name]; !ok {
+			return fmt.Errorf("include_job: unknown environment variable %q (not defined in job %q)", name, inc.Job)
+		}
+	}
+	return nil
+}
+
+// checkIncludeCycle walks include_job actions depth-first from jobName and
+// reports the first cycle found as a chain of job names
+func checkIncludeCycle(file *schema.File, jobName string, stack []string, visited map[string]bool) error {
+	for i, name := range stack {
+		if name == jobName {
+			chain := strings.Join(stack[i:], " -> ")
+			return fmt.Errorf("include_job cycle: %s -> %s", chain, jobName)
+		}
+	}
+	if visited[jobName] {
+		return nil
+	}
+
+	stack = append(stack, jobName)
+	job := file.Jobs[jobName]
+	for _, action := range job.AllActions() {
+		if action.IncludeJob == nil {
+			continue
+		}
+		if err := checkIncludeCycle(file, action.IncludeJob.Job, stack, visited); err != nil {
+			return err
+		}
+	}
+	visited[jobName] = true
+	return nil
+}
+
+// sortedJobNames returns job names in a stable order so that validation
+// errors are reproducible
+func sortedJobNames(jobs map[string]schema.Job) []string {
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validatePlanOnFailure checks that on_failure names exactly one existing job
+// or plan, and that a rollback plan cannot trigger a rollback of its own
+func validatePlanOnFailure(file *schema.File, planName string, f *schema.PlanOnFailure) error {
+	if (f.Job == "") == (f.Plan == "") {
+		return fmt.Errorf("on_failure requires exactly one of 'job' or 'plan'")
+	}
+	if f.Job != "" {
+		if _, ok := file.Jobs[f.Job]; !ok {
+			return fmt.Errorf("on_failure references non-existent job %q", f.Job)
+		}
+	}
+	if f.Plan != "" {
+		rollback, ok := file.Plans[f.Plan]
+		if !ok {
+			return fmt.Errorf("on_failure references non-existent plan %q", f.Plan)
+		}
+		if f.Plan == planName || rollback.OnFailure != nil {
+			return fmt.Errorf("on_failure plan %q must not define on_failure itself", f.Plan)
+		}
+	}
+	if f.Scope != "" && f.Scope != "step" && f.Scope != "all" {
+		return fmt.Errorf("on_failure: invalid scope %q (must be step or all)", f.Scope)
+	}
+	for name := range f.Env {
+		if strings.HasPrefix(name, "HADES_") {
+			return fmt.Errorf("on_failure: cannot define HADES_* environment variables: %s", name)
+		}
+	}
+	return nil
+}
+
+// validateStepNeeds checks that needs reference existing steps by a unique
+// name and that the steps form an acyclic graph
+func validateStepNeeds(plan *schema.Plan) error {
+	if !plan.UsesNeeds() {
+		return nil
+	}
+
+	index := make(map[string]int)
+	for i, step := range plan.Steps {
+		id := step.ID()
+		if _, ok := index[id]; ok {
+			return fmt.Errorf("duplicate step %q; steps of a plan using needs must have unique names", id)
+		}
+		index[id] = i
+	}
+	for _, step := range plan.Steps {
+		for _, need := range step.Needs {
+			if need == step.ID() {
+				return fmt.Errorf("step %q needs itself", need)
 			}
-			if action.Fetch != nil {
-				count++
+			if _, ok := index[need]; !ok {
+				return fmt.Errorf("step %q needs unknown step %q", step.ID(), need)
 			}
-			if count == 0 {
-				return fmt.Errorf("job %q action %d has no action type set", jobName, i)
+		}
+	}
+
+	// Depth-first search; a step reached again while on the stack closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(plan.Steps))
+	var stack []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		id := plan.Steps[i].ID()
+		switch state[i] {
+		case visiting:
+			for j, name := range stack {
+				if name == id {
+					return fmt.Errorf("needs cycle: %s -> %s", strings.Join(stack[j:], " -> "), id)
+				}
 			}
-			if count > 1 {
-				return fmt.Errorf("job %q action %d has multiple action types set", jobName, i)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		stack = append(stack, id)
+		for _, need := range plan.Steps[i].Needs {
+			if err := visit(index[need]); err != nil {
+				return err
 			}
 		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		return nil
+	}
+	for i := range plan.Steps {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStrategy checks the plan strategy and the step settings it rules out
+func validateStrategy(plan *schema.Plan) error {
+	switch plan.Strategy {
+	case "", schema.StrategyLockstep:
+		return nil
+	case schema.StrategyPipeline:
+	default:
+		return fmt.Errorf("invalid strategy %q (must be lockstep or pipeline)", plan.Strategy)
 	}
 
+	if plan.UsesNeeds() {
+		return fmt.Errorf("strategy pipeline cannot be combined with needs")
+	}
+	for _, step := range plan.Steps {
+		if step.Parallelism != "" {
+			return fmt.Errorf("step %q: parallelism is set on the plan with strategy pipeline", step.ID())
+		}
+	}
 	return nil
 }
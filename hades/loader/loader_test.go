@@ -0,0 +1,385 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SoftKiwiGames/hades/hades/schema"
+)
+
+func includeAction(job string) schema.Action {
+	return schema.Action{IncludeJob: &schema.ActionIncludeJob{Job: job}}
+}
+
+func TestValidate_IncludeJob(t *testing.T) {
+	run := schema.ActionRun("true")
+
+	tests := []struct {
+		name    string
+		jobs    map[string]schema.Job
+		wantErr string
+	}{
+		{
+			name: "valid include chain",
+			jobs: map[string]schema.Job{
+				"deploy":  {Actions: []schema.Action{includeAction("install"), includeAction("harden")}},
+				"install": {Actions: []schema.Action{includeAction("harden")}},
+				"harden":  {Actions: []schema.Action{{Run: &run}}},
+			},
+		},
+		{
+			name: "unknown job",
+			jobs: map[string]schema.Job{
+				"deploy": {Actions: []schema.Action{includeAction("missing")}},
+			},
+			wantErr: `non-existent job "missing"`,
+		},
+		{
+			name: "self include",
+			jobs: map[string]schema.Job{
+				"deploy": {Actions: []schema.Action{includeAction("deploy")}},
+			},
+			wantErr: "include_job cycle: deploy -> deploy",
+		},
+		{
+			name: "indirect cycle",
+			jobs: map[string]schema.Job{
+				"a": {Actions: []schema.Action{includeAction("b")}},
+				"b": {Actions: []schema.Action{includeAction("c")}},
+				"c": {Actions: []schema.Action{includeAction("a")}},
+			},
+			wantErr: "include_job cycle: a -> b -> c -> a",
+		},
+		{
+			name: "local mismatch",
+			jobs: map[string]schema.Job{
+				"build":  {Local: true, Actions: []schema.Action{includeAction("remote")}},
+				"remote": {Actions: []schema.Action{{Run: &run}}},
+			},
+			wantErr: "same 'local' setting",
+		},
+		{
+			name: "undeclared env",
+			jobs: map[string]schema.Job{
+				"deploy":  {Actions: []schema.Action{{IncludeJob: &schema.ActionIncludeJob{Job: "install", Env: map[string]string{"NOPE": "x"}}}}},
+				"install": {Actions: []schema.Action{{Run: &run}}},
+			},
+			wantErr: `unknown environment variable "NOPE"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Validate(&schema.File{Jobs: tt.jobs})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_CleanupActions(t *testing.T) {
+	run := schema.ActionRun("true")
+	file := &schema.File{Jobs: map[string]schema.Job{
+		"deploy": {
+			Actions:   []schema.Action{{Run: &run}},
+			OnFailure: []schema.Action{{Run: &run}},
+			Finally:   []schema.Action{{Name: "empty"}},
+		},
+	}}
+
+	err := New().Validate(file)
+	if err == nil || !strings.Contains(err.Error(), `job "deploy" finally action 0 has no action type set`) {
+		t.Fatalf("Expected finally action error, got %v", err)
+	}
+
+	file.Jobs["deploy"].Finally[0].Run = &run
+	if err := New().Validate(file); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestValidate_PlanOnFailure(t *testing.T) {
+	run := schema.ActionRun("true")
+	jobs := map[string]schema.Job{
+		"deploy":  {Actions: []schema.Action{{Run: &run}}},
+		"restore": {Actions: []schema.Action{{Run: &run}}},
+	}
+	steps := []schema.Step{{Job: "deploy"}}
+
+	tests := []struct {
+		name      string
+		onFailure *schema.PlanOnFailure
+		cleanup   *schema.PlanOnFailure
+		wantErr   string
+	}{
+		{name: "job", onFailure: &schema.PlanOnFailure{Job: "restore", Scope: "all"}},
+		{name: "plan", onFailure: &schema.PlanOnFailure{Plan: "cleanup"}},
+		{name: "neither", onFailure: &schema.PlanOnFailure{}, wantErr: "exactly one of"},
+		{name: "both", onFailure: &schema.PlanOnFailure{Job: "restore", Plan: "cleanup"}, wantErr: "exactly one of"},
+		{name: "unknown job", onFailure: &schema.PlanOnFailure{Job: "missing"}, wantErr: `non-existent job "missing"`},
+		{name: "bad scope", onFailure: &schema.PlanOnFailure{Job: "restore", Scope: "host"}, wantErr: `invalid scope "host"`},
+		{
+			name:      "nested rollback",
+			onFailure: &schema.PlanOnFailure{Plan: "cleanup"},
+			cleanup:   &schema.PlanOnFailure{Job: "restore"},
+			wantErr:   "must not define on_failure",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &schema.File{
+				Jobs: jobs,
+				Plans: map[string]schema.Plan{
+					"deploy":  {Steps: steps, OnFailure: tt.onFailure},
+					"cleanup": {Steps: []schema.Step{{Job: "restore"}}, OnFailure: tt.cleanup},
+				},
+			}
+			err := New().Validate(file)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_StepNeeds(t *testing.T) {
+	run := schema.ActionRun("true")
+	jobs := map[string]schema.Job{
+		"build":  {Actions: []schema.Action{{Run: &run}}},
+		"deploy": {Actions: []schema.Action{{Run: &run}}},
+	}
+
+	tests := []struct {
+		name    string
+		steps   []schema.Step
+		wantErr string
+	}{
+		{
+			name: "diamond",
+			steps: []schema.Step{
+				{Job: "build"},
+				{Name: "eu", Job: "deploy", Needs: []string{"build"}},
+				{Name: "us", Job: "deploy", Needs: []string{"build"}},
+				{Name: "verify", Job: "deploy", Needs: []string{"eu", "us"}},
+			},
+		},
+		{
+			name: "duplicate step ids without needs are fine",
+			steps: []schema.Step{
+				{Job: "deploy"},
+				{Job: "deploy"},
+			},
+		},
+		{
+			name: "duplicate step ids with needs",
+			steps: []schema.Step{
+				{Job: "deploy"},
+				{Job: "deploy", Needs: []string{"build"}},
+				{Job: "build"},
+			},
+			wantErr: `duplicate step "deploy"`,
+		},
+		{
+			name: "unknown step",
+			steps: []schema.Step{
+				{Job: "deploy", Needs: []string{"compile"}},
+			},
+			wantErr: `needs unknown step "compile"`,
+		},
+		{
+			name: "self",
+			steps: []schema.Step{
+				{Job: "deploy", Needs: []string{"deploy"}},
+			},
+			wantErr: `step "deploy" needs itself`,
+		},
+		{
+			name: "cycle",
+			steps: []schema.Step{
+				{Name: "a", Job: "deploy", Needs: []string{"c"}},
+				{Name: "b", Job: "deploy", Needs: []string{"a"}},
+				{Name: "c", Job: "deploy", Needs: []string{"b"}},
+			},
+			wantErr: "needs cycle: a -> c -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &schema.File{Jobs: jobs, Plans: map[string]schema.Plan{"p": {Steps: tt.steps}}}
+			err := New().Validate(file)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Strategy(t *testing.T) {
+	run := schema.ActionRun("true")
+	jobs := map[string]schema.Job{"deploy": {Actions: []schema.Action{{Run: &run}}}}
+
+	tests := []struct {
+		name    string
+		plan    schema.Plan
+		wantErr string
+	}{
+		{name: "default", plan: schema.Plan{Steps: []schema.Step{{Job: "deploy", Parallelism: "1"}}}},
+		{name: "pipeline", plan: schema.Plan{Strategy: "pipeline", Parallelism: "25%", Steps: []schema.Step{{Job: "deploy", Limit: 1}}}},
+		{name: "unknown", plan: schema.Plan{Strategy: "free", Steps: []schema.Step{{Job: "deploy"}}}, wantErr: `invalid strategy "free"`},
+		{
+			name:    "pipeline with needs",
+			plan:    schema.Plan{Strategy: "pipeline", Steps: []schema.Step{{Name: "a", Job: "deploy"}, {Job: "deploy", Needs: []string{"a"}}}},
+			wantErr: "cannot be combined with needs",
+		},
+		{
+			name:    "pipeline with step parallelism",
+			plan:    schema.Plan{Strategy: "pipeline", Steps: []schema.Step{{Job: "deploy", Parallelism: "1"}}},
+			wantErr: "parallelism is set on the plan",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &schema.File{Jobs: jobs, Plans: map[string]schema.Plan{"p": tt.plan}}
+			err := New().Validate(file)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ArchiveFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  schema.Action
+		wantErr string
+	}{
+		{
+			name:    "release artifact extract without format",
+			action:  schema.Action{Release: &schema.ActionRelease{Path: "/opt/app", Artifact: "bundle", Extract: true}},
+			wantErr: `cannot detect the format of artifact "bundle", set 'format'`,
+		},
+		{
+			name:   "release artifact extract with format",
+			action: schema.Action{Release: &schema.ActionRelease{Path: "/opt/app", Artifact: "bundle", Extract: true, Format: "tar.gz"}},
+		},
+		{
+			name:   "release artifact named like an archive",
+			action: schema.Action{Release: &schema.ActionRelease{Path: "/opt/app", Artifact: "bundle.tgz", Extract: true}},
+		},
+		{
+			name:    "release format without extract",
+			action:  schema.Action{Release: &schema.ActionRelease{Path: "/opt/app", Src: "app", Format: "zip"}},
+			wantErr: "'format' requires 'extract: true'",
+		},
+		{
+			name:    "release unsupported format",
+			action:  schema.Action{Release: &schema.ActionRelease{Path: "/opt/app", Src: "app", Extract: true, Format: "rar"}},
+			wantErr: `unsupported format "rar"`,
+		},
+		{
+			name:    "unarchive artifact without format",
+			action:  schema.Action{Unarchive: &schema.ActionUnarchive{Artifact: "bundle", Dst: "/opt/app"}},
+			wantErr: `cannot detect the format of artifact "bundle"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &schema.File{Jobs: map[string]schema.Job{"deploy": {Actions: []schema.Action{tt.action}}}}
+			err := New().Validate(file)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Handlers(t *testing.T) {
+	run := schema.ActionRun("true")
+	reload := schema.Action{Name: "reload", Run: &run}
+
+	tests := []struct {
+		name    string
+		job     schema.Job
+		wantErr string
+	}{
+		{
+			name: "valid",
+			job:  schema.Job{Actions: []schema.Action{{Run: &run, Notify: []string{"reload"}}}, Handlers: []schema.Action{reload}},
+		},
+		{
+			name:    "unnamed handler",
+			job:     schema.Job{Actions: []schema.Action{{Run: &run}}, Handlers: []schema.Action{{Run: &run}}},
+			wantErr: "handler 0 requires 'name'",
+		},
+		{
+			name:    "duplicate handler",
+			job:     schema.Job{Actions: []schema.Action{{Run: &run}}, Handlers: []schema.Action{reload, reload}},
+			wantErr: `duplicate handler "reload"`,
+		},
+		{
+			name:    "unknown handler",
+			job:     schema.Job{Actions: []schema.Action{{Run: &run, Notify: []string{"restart"}}}, Handlers: []schema.Action{reload}},
+			wantErr: `notifies unknown handler "restart"`,
+		},
+		{
+			name: "finally notifies",
+			job: schema.Job{
+				Actions:  []schema.Action{{Run: &run}},
+				Finally:  []schema.Action{{Run: &run, Notify: []string{"reload"}}},
+				Handlers: []schema.Action{reload},
+			},
+			wantErr: "finally action 0 cannot notify handlers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Validate(&schema.File{Jobs: map[string]schema.Job{"deploy": tt.job}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
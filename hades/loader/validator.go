@@ -2,6 +2,7 @@ package loader
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/SoftKiwiGames/hades/hades/schema"
@@ -54,6 +55,61 @@ func MergeEnv(job *schema.Job, provided map[string]string) map[string]string {
 	return result
 }
 
+// HostEnv merges a host's inventory vars into a job's environment with
+// priority: provided > host vars > job defaults. Only the vars the job
+// declares are used.
+func HostEnv(job *schema.Job, vars map[string]string, provided map[string]string) map[string]string {
+	layered := make(map[string]string)
+	for name := range job.Env {
+		if value, ok := vars[name]; ok {
+			layered[name] = value
+		}
+	}
+	for name, value := range provided {
+		layered[name] = value
+	}
+	return MergeEnv(job, layered)
+}
+
+// IncludeEnv builds the variables passed to an included job: the caller's
+// values for the variables the included job declares, overridden by the
+// include's own env. Values are returned unexpanded.
+func IncludeEnv(included *schema.Job, caller map[string]string, overrides map[string]string) map[string]string {
+	result := make(map[string]string)
+	for name := range included.Env {
+		if value, ok := caller[name]; ok {
+			result[name] = value
+		}
+	}
+	for name, value := range overrides {
+		result[name] = value
+	}
+	return result
+}
+
+// validateIncludedEnv checks the env contract of every job included by job,
+// recursively, given the variables provided to job
+func validateIncludedEnv(file *schema.File, job *schema.Job, provided map[string]string) error {
+	env := MergeEnv(job, provided)
+	for _, action := range job.AllActions() {
+		if action.IncludeJob == nil {
+			continue
+		}
+		included, ok := file.Jobs[action.IncludeJob.Job]
+		if !ok {
+			return fmt.Errorf("include_job: job %q not found", action.IncludeJob.Job)
+		}
+		includedEnv := IncludeEnv(&included, env, action.IncludeJob.Env)
+		if err := ValidateEnvContract(&included, includedEnv); err != nil {
+			return fmt.Errorf("include_job %q: %w", action.IncludeJob.Job, err)
+		}
+		if err := validateIncludedEnv(file, &included, includedEnv); err != nil {
+			return fmt.Errorf("include_job %q: %w", action.IncludeJob.Job, err)
+		}
+	}
+	return nil
+}
+
 // ValidateStepEnv validates step-level environment variables against the job's contract
 func ValidateStepEnv(file *schema.File, planName string, stepIdx int) error {
 	plan := file.Plans[planName]
@@ -81,8 +137,14 @@ func ValidateStepEnv(file *schema.File, planName string, stepIdx int) error {
 	return nil
 }
 
-// ValidatePlanEnv validates all environment variables in a plan
-func ValidatePlanEnv(file *schema.File, planName string, cliEnv map[string]string) error {
+// HostVarsFunc returns the inventory vars of each host a step runs on, keyed
+// by host name
+type HostVarsFunc func(step *schema.Step) (map[string]map[string]string, error)
+
+// ValidatePlanEnv validates all environment variables in a plan. hostVars may
+// be nil; otherwise the contract is checked for every host of each step, with
+// its inventory vars merged in.
+func ValidatePlanEnv(file *schema.File, planName string, cliEnv map[string]string, hostVars HostVarsFunc) error {
 	plan, ok := file.Plans[planName]
 	if !ok {
 		return fmt.Errorf("plan %q not found", planName)
@@ -102,7 +164,9 @@ func ValidatePlanEnv(file *schema.File, planName string, cliEnv map[string]strin
 			return fmt.Errorf("step %q: job %q not found", step.Name, step.Job)
 		}
 
-		// Merge envs: CLI > step > plan > defaults
+		// Merge envs: matrix values > CLI > step > plan; host vars and
+		// defaults are merged in per host by validateHostsEnv, for the
+		// variables the job declares
 		mergedEnv := make(map[string]string)
 
 		// Start with plan-level env
@@ -115,16 +179,109 @@ func ValidatePlanEnv(file *schema.File, planName string, cliEnv map[string]strin
 			mergedEnv[k] = v
 		}
 
-		// CLI overrides everything
+		// CLI overrides step and plan
 		for k, v := range cliEnv {
 			mergedEnv[k] = v
 		}
 
-		// Validate against job contract
-		if err := ValidateEnvContract(&job, mergedEnv); err != nil {
+		for k, v := range step.MatrixEnv {
+			mergedEnv[k] = v
+		}
+
+		if err := validateHostsEnv(file, &job, &step, mergedEnv, hostVars); err != nil {
 			return fmt.Errorf("step %d (%s): %w", i, step.Name, err)
 		}
 	}
 
+	if plan.OnFailure != nil {
+		if err := validateOnFailureEnv(file, &plan, cliEnv, hostVars); err != nil {
+			return fmt.Errorf("on_failure: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateHostsEnv validates a job's contract, and that of the jobs it
+// includes, once per host of step. Without hosts the provided env is checked
+// on its own.
+func validateHostsEnv(file *schema.File, job *schema.Job, step *schema.Step, provided map[string]string, hostVars HostVarsFunc) error {
+	hosts := map[string]map[string]string{"": nil}
+	if hostVars != nil {
+		resolved, err := hostVars(step)
+		if err != nil {
+			return err
+		}
+		if len(resolved) > 0 {
+			hosts = resolved
+		}
+	}
+
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		env := HostEnv(job, hosts[name], provided)
+		err := ValidateEnvContract(job, env)
+		if err == nil {
+			err = validateIncludedEnv(file, job, env)
+		}
+		if err != nil {
+			if name != "" {
+				return fmt.Errorf("host %q: %w", name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// validateOnFailureEnv validates the env of a plan's rollback job or plan,
+// merged with priority: CLI > on_failure > plan. The rollback runs on the hosts
+// of any step, with the vars they had in that step, so it is checked against
+// the hosts of each step in turn.
+func validateOnFailureEnv(file *schema.File, plan *schema.Plan, cliEnv map[string]string, hostVars HostVarsFunc) error {
+	provided := make(map[string]string)
+	for k, v := range plan.Env {
+		provided[k] = v
+	}
+	for k, v := range plan.OnFailure.Env {
+		provided[k] = v
+	}
+	for k, v := range cliEnv {
+		provided[k] = v
+	}
+
+	job, ok := file.Jobs[plan.OnFailure.Job]
+	if plan.OnFailure.Plan == "" && !ok {
+		return fmt.Errorf("job %q not found", plan.OnFailure.Job)
+	}
+
+	for i := range plan.Steps {
+		// The rollback's own steps run on the hosts of the failed step
+		var stepHostVars HostVarsFunc
+		if hostVars != nil {
+			step := &plan.Steps[i]
+			stepHostVars = func(*schema.Step) (map[string]map[string]string, error) {
+				return hostVars(step)
+			}
+		}
+
+		var err error
+		if plan.OnFailure.Plan != "" {
+			err = ValidatePlanEnv(file, plan.OnFailure.Plan, provided, stepHostVars)
+		} else if err = validateHostsEnv(file, &job, &schema.Step{}, provided, stepHostVars); err != nil {
+			err = fmt.Errorf("job %q: %w", plan.OnFailure.Job, err)
+		}
+		if hostVars == nil {
+			return err // Without hosts every step checks the same env
+		}
+		if err != nil {
+			return fmt.Errorf("hosts of step %q: %w", plan.Steps[i].ID(), err)
+		}
+	}
 	return nil
 }
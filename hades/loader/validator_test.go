@@ -190,3 +190,144 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestIncludeEnv(t *testing.T) {
+	included := schema.Job{
+		Env: map[string]schema.Env{
+			"VERSION": {},
+			"MODE":    {Default: "prod"},
+		},
+	}
+	caller := map[string]string{
+		"VERSION":      "v1.0.0",
+		"OTHER":        "not declared",
+		"HADES_RUN_ID": "hades-1",
+	}
+
+	got := IncludeEnv(&included, caller, map[string]string{"MODE": "dev"})
+	want := map[string]string{"VERSION": "v1.0.0", "MODE": "dev"}
+
+	if len(got) != len(want) {
+		t.Fatalf("IncludeEnv() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("IncludeEnv()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestValidatePlanEnv_IncludedJob(t *testing.T) {
+	file := &schema.File{
+		Jobs: map[string]schema.Job{
+			"deploy": {
+				Env: map[string]schema.Env{"VERSION": {}},
+				Actions: []schema.Action{
+					{IncludeJob: &schema.ActionIncludeJob{Job: "install"}},
+				},
+			},
+			"install": {
+				Env: map[string]schema.Env{"VERSION": {}, "CHANNEL": {}},
+			},
+		},
+		Plans: map[string]schema.Plan{
+			"p": {Steps: []schema.Step{{Job: "deploy"}}},
+		},
+	}
+
+	err := ValidatePlanEnv(file, "p", map[string]string{"VERSION": "v1"}, nil)
+	if err == nil || !contains(err.Error(), `include_job "install"`) || !contains(err.Error(), "CHANNEL") {
+		t.Fatalf("Expected missing CHANNEL error for included job, got %v", err)
+	}
+
+	deploy := file.Jobs["deploy"]
+	deploy.Actions[0].IncludeJob.Env = map[string]string{"CHANNEL": "stable"}
+	if err := ValidatePlanEnv(file, "p", map[string]string{"VERSION": "v1"}, nil); err != nil {
+		t.Errorf("Expected included contract to be satisfied, got %v", err)
+	}
+}
+
+func TestHostEnv(t *testing.T) {
+	job := schema.Job{
+		Env: map[string]schema.Env{
+			"PORT":   {Default: "80"},
+			"REGION": {Default: "us"},
+			"MODE":   {},
+		},
+	}
+	vars := map[string]string{"PORT": "8081", "REGION": "eu", "UNDECLARED": "x"}
+
+	got := HostEnv(&job, vars, map[string]string{"REGION": "ap", "MODE": "prod"})
+	want := map[string]string{"PORT": "8081", "REGION": "ap", "MODE": "prod"}
+
+	if len(got) != len(want) {
+		t.Fatalf("HostEnv() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("HostEnv()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestValidatePlanEnv_HostVars(t *testing.T) {
+	file := &schema.File{
+		Jobs: map[string]schema.Job{
+			"deploy": {Env: map[string]schema.Env{"PORT": {}}},
+		},
+		Plans: map[string]schema.Plan{
+			"p": {Steps: []schema.Step{{Job: "deploy", Targets: []string{"web"}}}},
+		},
+	}
+	hosts := map[string]map[string]string{
+		"web-1": {"PORT": "8081"},
+		"web-2": nil,
+	}
+	hostVars := func(*schema.Step) (map[string]map[string]string, error) {
+		return hosts, nil
+	}
+
+	err := ValidatePlanEnv(file, "p", nil, hostVars)
+	if err == nil || !contains(err.Error(), `host "web-2"`) || !contains(err.Error(), "PORT") {
+		t.Fatalf("Expected missing PORT error for web-2, got %v", err)
+	}
+
+	hosts["web-2"] = map[string]string{"PORT": "8082"}
+	if err := ValidatePlanEnv(file, "p", nil, hostVars); err != nil {
+		t.Errorf("Expected host vars to satisfy the contract, got %v", err)
+	}
+
+	if err := ValidatePlanEnv(file, "p", nil, nil); err == nil {
+		t.Error("Expected missing PORT error without host vars")
+	}
+}
+
+func TestValidatePlanEnv_OnFailureHostVarsPerStep(t *testing.T) {
+	file := &schema.File{
+		Jobs: map[string]schema.Job{
+			"deploy":  {},
+			"restore": {Env: map[string]schema.Env{"REGION": {}}},
+		},
+		Plans: map[string]schema.Plan{
+			"p": {
+				Steps: []schema.Step{
+					{Name: "eu", Job: "deploy", Targets: []string{"eu"}},
+					{Name: "all", Job: "deploy", Targets: []string{"all"}},
+				},
+				OnFailure: &schema.PlanOnFailure{Job: "restore", Scope: "all"},
+			},
+		},
+	}
+	// web-1 only has REGION through the target of the first step
+	hostVars := func(step *schema.Step) (map[string]map[string]string, error) {
+		if step.Name == "eu" {
+			return map[string]map[string]string{"web-1": {"REGION": "eu"}}, nil
+		}
+		return map[string]map[string]string{"web-1": nil}, nil
+	}
+
+	err := ValidatePlanEnv(file, "p", nil, hostVars)
+	if err == nil || !contains(err.Error(), `hosts of step "all"`) || !contains(err.Error(), "REGION") {
+		t.Fatalf("Expected missing REGION error for the hosts of step all, got %v", err)
+	}
+}
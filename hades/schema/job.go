@@ -3,31 +3,65 @@ package schema
 type Job struct {
 	Local     bool                `yaml:"local"`
 	Guard     *Guard              `yaml:"guard,omitempty"`
+	Tunnel    *Tunnel             `yaml:"tunnel,omitempty"`
 	Env       map[string]Env      `yaml:"env"`
 	Artifacts map[string]Artifact `yaml:"artifacts"`
 	Actions   []Action            `yaml:"actions"`
-	SourceDir string              `yaml:"-"` // Directory of the YAML file that defined this job
+	OnFailure []Action            `yaml:"on_failure,omitempty"` // Run when an action fails or the run is cancelled
+	Finally   []Action            `yaml:"finally,omitempty"`    // Always run once the job has started
+	Handlers  []Action            `yaml:"handlers,omitempty"`   // Run once after the actions when notified by a change
+	SourceDir string              `yaml:"-"`                    // Directory of the YAML file that defined this job
+}
+
+// AllActions returns the job's actions followed by its on_failure, finally
+// and handler actions
+func (j *Job) AllActions() []Action {
+	all := make([]Action, 0, len(j.Actions)+len(j.OnFailure)+len(j.Finally)+len(j.Handlers))
+	all = append(all, j.Actions...)
+	all = append(all, j.OnFailure...)
+	all = append(all, j.Finally...)
+	return append(all, j.Handlers...)
 }
 
 type Guard struct {
 	If string `yaml:"if"`
 }
 
+// Tunnel forwards a local port to an address reachable from an inventory host
+// while a local job runs
+type Tunnel struct {
+	Host      string `yaml:"host"`
+	Remote    string `yaml:"remote"`
+	LocalPort int    `yaml:"local_port,omitempty"`
+}
+
 type Artifact struct {
 	Path string `yaml:"path"`
 }
 
 type Action struct {
-	Name     string          `yaml:"name,omitempty"`
-	Run      *ActionRun      `yaml:"run,omitempty"`
-	Copy     *ActionCopy     `yaml:"copy,omitempty"`
-	Fetch    *ActionFetch    `yaml:"fetch,omitempty"`
-	Template *ActionTemplate `yaml:"template,omitempty"`
-	Mkdir    *ActionMkdir    `yaml:"mkdir,omitempty"`
-	Push     *ActionPush     `yaml:"push,omitempty"`
-	Pull     *ActionPull     `yaml:"pull,omitempty"`
-	Wait     *ActionWait     `yaml:"wait,omitempty"`
-	Gpg      *ActionGpg      `yaml:"gpg,omitempty"`
+	Name          string               `yaml:"name,omitempty"`
+	Run           *ActionRun           `yaml:"run,omitempty"`
+	Copy          *ActionCopy          `yaml:"copy,omitempty"`
+	Fetch         *ActionFetch         `yaml:"fetch,omitempty"`
+	Template      *ActionTemplate      `yaml:"template,omitempty"`
+	Mkdir         *ActionMkdir         `yaml:"mkdir,omitempty"`
+	Push          *ActionPush          `yaml:"push,omitempty"`
+	Pull          *ActionPull          `yaml:"pull,omitempty"`
+	Wait          *ActionWait          `yaml:"wait,omitempty"`
+	Gpg           *ActionGpg           `yaml:"gpg,omitempty"`
+	Download      *ActionDownload      `yaml:"download,omitempty"`
+	Unarchive     *ActionUnarchive     `yaml:"unarchive,omitempty"`
+	Release       *ActionRelease       `yaml:"release,omitempty"`
+	Service       *ActionService       `yaml:"service,omitempty"`
+	Package       *ActionPackage       `yaml:"package,omitempty"`
+	LineInFile    *ActionLineInFile    `yaml:"lineinfile,omitempty"`
+	BlockInFile   *ActionBlockInFile   `yaml:"blockinfile,omitempty"`
+	User          *ActionUser          `yaml:"user,omitempty"`
+	AuthorizedKey *ActionAuthorizedKey `yaml:"authorized_key,omitempty"`
+	WaitFor       *ActionWaitFor       `yaml:"wait_for,omitempty"`
+	IncludeJob    *ActionIncludeJob    `yaml:"include_job,omitempty"`
+	Notify        []string             `yaml:"notify,omitempty"` // Handlers to run if this action changed something
 }
 
 type ActionRun string
@@ -73,9 +107,135 @@ type ActionWait struct {
 	Timeout string `yaml:"timeout,omitempty"`
 }
 
+type ActionWaitFor struct {
+	TCP      string `yaml:"tcp,omitempty"`
+	HTTP     string `yaml:"http,omitempty"`
+	Status   int    `yaml:"status,omitempty"`
+	Body     string `yaml:"body,omitempty"`
+	File     string `yaml:"file,omitempty"`
+	Command  string `yaml:"command,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty"`
+	Interval string `yaml:"interval,omitempty"`
+	From     string `yaml:"from,omitempty"`
+}
+
 type ActionGpg struct {
 	Src     string `yaml:"src"`
 	Path    string `yaml:"path"`
 	Mode    uint32 `yaml:"mode,omitempty"`
 	Dearmor bool   `yaml:"dearmor,omitempty"`
 }
+
+type ActionDownload struct {
+	URL      string `yaml:"url"`
+	Dst      string `yaml:"dst"`
+	Checksum string `yaml:"checksum"`
+	Extract  string `yaml:"extract,omitempty"`
+	Mode     uint32 `yaml:"mode,omitempty"`
+	Owner    string `yaml:"owner,omitempty"`
+	OnHost   bool   `yaml:"on_host,omitempty"`
+}
+
+type ActionUnarchive struct {
+	Src             string `yaml:"src,omitempty"`
+	Artifact        string `yaml:"artifact,omitempty"`
+	Registry        string `yaml:"registry,omitempty"`
+	Name            string `yaml:"name,omitempty"`
+	Tag             string `yaml:"tag,omitempty"`
+	Dst             string `yaml:"dst"`
+	Format          string `yaml:"format,omitempty"`
+	StripComponents int    `yaml:"strip_components,omitempty"`
+	Owner           string `yaml:"owner,omitempty"`
+	Mode            uint32 `yaml:"mode,omitempty"`
+}
+
+type ActionRelease struct {
+	Path            string `yaml:"path"`
+	ID              string `yaml:"id,omitempty"`
+	Src             string `yaml:"src,omitempty"`
+	Artifact        string `yaml:"artifact,omitempty"`
+	Registry        string `yaml:"registry,omitempty"`
+	Name            string `yaml:"name,omitempty"`
+	Tag             string `yaml:"tag,omitempty"`
+	File            string `yaml:"file,omitempty"`
+	Extract         bool   `yaml:"extract,omitempty"`
+	Format          string `yaml:"format,omitempty"`
+	StripComponents int    `yaml:"strip_components,omitempty"`
+	Mode            uint32 `yaml:"mode,omitempty"`
+	Owner           string `yaml:"owner,omitempty"`
+	Keep            int    `yaml:"keep,omitempty"`
+}
+
+type ActionService struct {
+	Name         string `yaml:"name"`
+	State        string `yaml:"state,omitempty"`
+	Enabled      *bool  `yaml:"enabled,omitempty"`
+	DaemonReload bool   `yaml:"daemon_reload,omitempty"`
+}
+
+type ActionPackage struct {
+	Names       []string           `yaml:"names"`
+	State       string             `yaml:"state,omitempty"`
+	UpdateCache bool               `yaml:"update_cache,omitempty"`
+	Repository  *PackageRepository `yaml:"repository,omitempty"`
+}
+
+// PackageRepository describes a third-party apt repository and its signing key
+type PackageRepository struct {
+	Name    string `yaml:"name"`
+	Source  string `yaml:"source"`
+	Key     string `yaml:"key,omitempty"`
+	Dearmor bool   `yaml:"dearmor,omitempty"`
+	Keyring string `yaml:"keyring,omitempty"`
+}
+
+type ActionLineInFile struct {
+	Path         string `yaml:"path"`
+	Line         string `yaml:"line,omitempty"`
+	Regexp       string `yaml:"regexp,omitempty"`
+	State        string `yaml:"state,omitempty"`
+	InsertAfter  string `yaml:"insert_after,omitempty"`
+	InsertBefore string `yaml:"insert_before,omitempty"`
+	Create       bool   `yaml:"create,omitempty"`
+	Backup       bool   `yaml:"backup,omitempty"`
+	Mode         uint32 `yaml:"mode,omitempty"`
+}
+
+type ActionBlockInFile struct {
+	Path         string `yaml:"path"`
+	Block        string `yaml:"block,omitempty"`
+	Marker       string `yaml:"marker,omitempty"`
+	State        string `yaml:"state,omitempty"`
+	InsertAfter  string `yaml:"insert_after,omitempty"`
+	InsertBefore string `yaml:"insert_before,omitempty"`
+	Create       bool   `yaml:"create,omitempty"`
+	Backup       bool   `yaml:"backup,omitempty"`
+	Mode         uint32 `yaml:"mode,omitempty"`
+}
+
+type ActionUser struct {
+	Name   string   `yaml:"name"`
+	State  string   `yaml:"state,omitempty"`
+	Shell  string   `yaml:"shell,omitempty"`
+	Home   string   `yaml:"home,omitempty"`
+	Groups []string `yaml:"groups,omitempty"`
+	System bool     `yaml:"system,omitempty"`
+}
+
+type ActionAuthorizedKey struct {
+	User      string `yaml:"user"`
+	Key       string `yaml:"key,omitempty"`
+	Src       string `yaml:"src,omitempty"`
+	URL       string `yaml:"url,omitempty"`
+	Path      string `yaml:"path,omitempty"`
+	State     string `yaml:"state,omitempty"`
+	Exclusive bool   `yaml:"exclusive,omitempty"`
+}
+
+// ActionIncludeJob runs another job's actions inline on the same host. The
+// included job receives the caller's values for the variables it declares;
+// Env sets or overrides them and may reference the caller's variables.
+type ActionIncludeJob struct {
+	Job string            `yaml:"job"`
+	Env map[string]string `yaml:"env,omitempty"`
+}
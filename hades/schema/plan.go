@@ -1,15 +1,73 @@
 package schema
 
 type Plan struct {
+	Env         map[string]string `yaml:"env,omitempty"`
+	Steps       []Step            `yaml:"steps"`
+	OnFailure   *PlanOnFailure    `yaml:"on_failure,omitempty"`
+	Strategy    string            `yaml:"strategy,omitempty"`    // lockstep (default) or pipeline
+	Parallelism string            `yaml:"parallelism,omitempty"` // Default step parallelism; hosts in flight for pipeline
+}
+
+const (
+	// StrategyLockstep runs every host through a step before the next starts
+	StrategyLockstep = "lockstep"
+	// StrategyPipeline moves each host through all steps on its own
+	StrategyPipeline = "pipeline"
+)
+
+// PlanOnFailure names a job or plan to run against the hosts a failed plan
+// already touched: those of the failed step (scope "step", the default) or of
+// every step run so far (scope "all")
+type PlanOnFailure struct {
+	Job   string            `yaml:"job,omitempty"`
+	Plan  string            `yaml:"plan,omitempty"`
+	Scope string            `yaml:"scope,omitempty"`
 	Env   map[string]string `yaml:"env,omitempty"`
-	Steps []Step            `yaml:"steps"`
 }
 
 type Step struct {
 	Name        string            `yaml:"name"`
 	Job         string            `yaml:"job"`
+	Plan        string            `yaml:"plan,omitempty"` // Run another plan's steps instead of a job
 	Targets     []string          `yaml:"targets"`
 	Env         map[string]string `yaml:"env,omitempty"`
 	Parallelism string            `yaml:"parallelism,omitempty"`
 	Limit       int               `yaml:"limit,omitempty"`
+	Tunnel      *Tunnel           `yaml:"tunnel,omitempty"`
+	Needs       []string          `yaml:"needs,omitempty"` // Steps that must complete first
+
+	// Matrix runs the step once per combination of values, as steps named
+	// "<step>[KEY=value]"; MatrixParallelism entries run at a time (default 1)
+	Matrix            map[string][]string `yaml:"matrix,omitempty"`
+	MatrixParallelism int                 `yaml:"matrix_parallelism,omitempty"`
+
+	// Set on matrix entries by the loader: the matrix step they were expanded
+	// from, how many of its entries may run at a time, and the entry's values,
+	// which override all other env including the CLI
+	MatrixOf       string            `yaml:"-"`
+	MatrixParallel int               `yaml:"-"`
+	MatrixEnv      map[string]string `yaml:"-"`
+}
+
+// ID identifies a step in needs: its name, or its job (or plan) when it has
+// no name
+func (s *Step) ID() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Job != "" {
+		return s.Job
+	}
+	return s.Plan
+}
+
+// UsesNeeds reports whether any step declares needs, which makes the plan run
+// as a dependency graph instead of in sequence
+func (p *Plan) UsesNeeds() bool {
+	for _, step := range p.Steps {
+		if len(step.Needs) > 0 {
+			return true
+		}
+	}
+	return false
 }
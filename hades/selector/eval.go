@@ -27,6 +27,17 @@ func (e *EvalErrors) Error() string {
 	return strings.Join(msgs, "; ")
 }
 
+// IsExpression reports whether s is a selector expression rather than a
+// plain name: every expression contains at least one comparison operator.
+func IsExpression(s string) bool {
+	for _, op := range []string{"==", "!=", "=~", "!~"} {
+		if strings.Contains(s, op) {
+			return true
+		}
+	}
+	return false
+}
+
 // Eval parses and evaluates a selector expression against the given tags.
 // Returns true if the tags match the selector.
 func Eval(selector string, tags map[string]string) (bool, *EvalErrors) {
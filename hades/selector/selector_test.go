@@ -273,3 +273,25 @@ func TestEval(t *testing.T) {
 		})
 	}
 }
+
+func TestIsExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`role == "web"`, true},
+		{`role == "web" && region == "eu"`, true},
+		{`env != "prod"`, true},
+		{`name =~ "db-[0-9]+"`, true},
+		{`name !~ "test.*"`, true},
+		{`web-servers`, false},
+		{`app-1.example.com`, false},
+		{`!canary`, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsExpression(tt.input); got != tt.want {
+			t.Errorf("IsExpression(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 )
 
 type Client interface {
 	Connect(ctx context.Context, host Host) (Session, error)
+	// Forward opens a local port that forwards connections to remoteAddr as seen from host
+	Forward(ctx context.Context, host Host, remoteAddr string, localPort int) (*Forward, error)
 	Close() error
 }
 
@@ -19,9 +22,13 @@ type Host struct {
 	User    string
 	KeyPath string
 	Port    int
+	Vars    map[string]string // Inventory vars: host vars over those of the target it was resolved through
+	Labels  map[string]string // Matched by selector targets; cloud tags for dynamic hosts
+	Source  string            // Inventory file or "provider:<name>" that defined the host
 }
 
 type client struct {
+	mu          sync.Mutex // Hosts of a batch connect in parallel
 	connections map[string]*ssh.Client
 }
 
@@ -32,10 +39,30 @@ func NewClient() Client {
 }
 
 func (c *client) Connect(ctx context.Context, host Host) (Session, error) {
+	conn, err := c.dial(host)
+	if err != nil {
+		return nil, err
+	}
+	return newSession(conn, host)
+}
+
+func (c *client) Forward(ctx context.Context, host Host, remoteAddr string, localPort int) (*Forward, error) {
+	conn, err := c.dial(host)
+	if err != nil {
+		return nil, err
+	}
+	return newForward(localPort, remoteAddr, conn.Dial)
+}
+
+// dial returns the shared connection to host, connecting on first use
+func (c *client) dial(host Host) (*ssh.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// Check if we already have a connection to this host
 	key := fmt.Sprintf("%s@%s", host.User, host.Address)
 	if conn, ok := c.connections[key]; ok {
-		return newSession(conn, host)
+		return conn, nil
 	}
 
 	// Read private key
@@ -73,10 +100,13 @@ func (c *client) Connect(ctx context.Context, host Host) (Session, error) {
 	// Store connection for reuse
 	c.connections[key] = conn
 
-	return newSession(conn, host)
+	return conn, nil
 }
 
 func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	var firstErr error
 	for key, conn := range c.connections {
 		if err := conn.Close(); err != nil && firstErr == nil {
@@ -0,0 +1,76 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// Forward is a local TCP listener whose connections are relayed to a remote
+// address through a dial function, such as an SSH connection's Dial
+type Forward struct {
+	listener net.Listener
+	remote   string
+	dial     func(network, addr string) (net.Conn, error)
+	wg       sync.WaitGroup
+}
+
+// newForward listens on 127.0.0.1:localPort (a free port when 0) and relays
+// every accepted connection to remoteAddr
+func newForward(localPort int, remoteAddr string, dial func(network, addr string) (net.Conn, error)) (*Forward, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on local port %d: %w", localPort, err)
+	}
+
+	f := &Forward{listener: listener, remote: remoteAddr, dial: dial}
+	f.wg.Add(1)
+	go f.serve()
+	return f, nil
+}
+
+// Port returns the local port the forward listens on
+func (f *Forward) Port() int {
+	return f.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops accepting connections and waits for the accept loop to exit.
+// Connections that are still open are closed by their peers.
+func (f *Forward) Close() error {
+	err := f.listener.Close()
+	f.wg.Wait()
+	return err
+}
+
+func (f *Forward) serve() {
+	defer f.wg.Done()
+	for {
+		local, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.relay(local)
+	}
+}
+
+func (f *Forward) relay(local net.Conn) {
+	defer local.Close()
+
+	remote, err := f.dial("tcp", f.remote)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
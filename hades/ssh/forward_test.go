@@ -0,0 +1,72 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestForward_RelaysConnections(t *testing.T) {
+	// Echo server standing in for the remote address
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				line, _ := bufio.NewReader(c).ReadString('\n')
+				fmt.Fprintf(c, "echo %s", line)
+			}(conn)
+		}
+	}()
+
+	fwd, err := newForward(0, echo.Addr().String(), net.Dial)
+	if err != nil {
+		t.Fatalf("newForward: %v", err)
+	}
+	defer fwd.Close()
+
+	if fwd.Port() == 0 {
+		t.Fatal("Expected a free port to be chosen")
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", fwd.Port()))
+		if err != nil {
+			t.Fatalf("dial forward: %v", err)
+		}
+		fmt.Fprintf(conn, "ping %d\n", i)
+		got, err := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if expected := fmt.Sprintf("echo ping %d\n", i); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestForward_CloseStopsListening(t *testing.T) {
+	fwd, err := newForward(0, "127.0.0.1:1", net.Dial)
+	if err != nil {
+		t.Fatalf("newForward: %v", err)
+	}
+	port := fwd.Port()
+
+	if err := fwd.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port)); err == nil {
+		conn.Close()
+		t.Error("Expected forward port to be closed")
+	}
+}
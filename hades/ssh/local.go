@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,12 +23,21 @@ func (c *LocalClient) Connect(ctx context.Context, host Host) (Session, error) {
 	return &localSession{workDir: c.workDir}, nil
 }
 
+func (c *LocalClient) Forward(ctx context.Context, host Host, remoteAddr string, localPort int) (*Forward, error) {
+	return newForward(localPort, remoteAddr, net.Dial)
+}
+
 func (c *LocalClient) Close() error {
 	return nil
 }
 
 type localSession struct {
 	workDir string
+	env     map[string]string
+}
+
+func (s *localSession) SetEnv(env map[string]string) {
+	s.env = env
 }
 
 func (s *localSession) Run(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
@@ -38,6 +48,12 @@ func (s *localSession) Run(ctx context.Context, cmd string, stdout, stderr io.Wr
 	if s.workDir != "" {
 		execCmd.Dir = s.workDir
 	}
+	if len(s.env) > 0 {
+		execCmd.Env = os.Environ()
+		for _, name := range sortedEnvNames(s.env) {
+			execCmd.Env = append(execCmd.Env, name+"="+s.env[name])
+		}
+	}
 
 	if err := execCmd.Run(); err != nil {
 		return fmt.Errorf("command failed: %w", err)
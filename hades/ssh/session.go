@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/SoftKiwiGames/hades/hades/utils"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -14,21 +18,32 @@ type Session interface {
 	Run(ctx context.Context, cmd string, stdout, stderr io.Writer) error
 	CopyFile(ctx context.Context, content io.Reader, remotePath string, mode uint32) error
 	ReadFile(ctx context.Context, remotePath string) (io.ReadCloser, error)
+	// SetEnv sets environment variables exported to subsequent Run calls
+	SetEnv(env map[string]string)
 	Close() error
 }
 
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type session struct {
-	conn *ssh.Client
-	host Host
+	conn     *ssh.Client
+	host     Host
+	env      map[string]string
+	rejected map[string]bool // variables the server refused via Setenv
 }
 
 func newSession(conn *ssh.Client, host Host) (Session, error) {
 	return &session{
-		conn: conn,
-		host: host,
+		conn:     conn,
+		host:     host,
+		rejected: make(map[string]bool),
 	}, nil
 }
 
+func (s *session) SetEnv(env map[string]string) {
+	s.env = env
+}
+
 func (s *session) Run(ctx context.Context, cmd string, stdout, stderr io.Writer) error {
 	sess, err := s.conn.NewSession()
 	if err != nil {
@@ -39,8 +54,37 @@ func (s *session) Run(ctx context.Context, cmd string, stdout, stderr io.Writer)
 	sess.Stdout = stdout
 	sess.Stderr = stderr
 
+	// Pass env via Setenv where the server accepts it (sshd AcceptEnv),
+	// otherwise export it in front of the command
+	var fallback []string
+	for _, name := range sortedEnvNames(s.env) {
+		if !s.rejected[name] {
+			if err := sess.Setenv(name, s.env[name]); err == nil {
+				continue
+			}
+			s.rejected[name] = true
+		}
+		fallback = append(fallback, name)
+	}
+	cmd = envPrefix(fallback, s.env) + cmd
+
+	// Close the channel when the context ends so Run does not outlive it
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sess.Signal(ssh.SIGKILL)
+			sess.Close()
+		case <-done:
+		}
+	}()
+
 	// Run command
 	if err := sess.Run(cmd); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("command interrupted: %w", ctx.Err())
+		}
 		return fmt.Errorf("command failed: %w", err)
 	}
 
@@ -108,6 +152,30 @@ func (s *session) ReadFile(ctx context.Context, remotePath string) (io.ReadClose
 	return io.NopCloser(bytes.NewReader(stdout.Bytes())), nil
 }
 
+// sortedEnvNames returns the exportable variable names in env, sorted
+func sortedEnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if envNamePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envPrefix builds a shell prefix exporting the given variables
+func envPrefix(names []string, env map[string]string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, utils.ShellQuote(env[name])))
+	}
+	return "export " + strings.Join(parts, " ") + "\n"
+}
+
 func (s *session) Close() error {
 	// Connection is managed by the client, not individual sessions
 	return nil
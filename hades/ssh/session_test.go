@@ -0,0 +1,24 @@
+package ssh
+
+import "testing"
+
+func TestEnvPrefix(t *testing.T) {
+	env := map[string]string{
+		"HADES_RUN_ID": "hades-1",
+		"GREETING":     "it's $HOME",
+		"bad-name":     "skipped",
+	}
+
+	got := envPrefix(sortedEnvNames(env), env)
+	expected := "export GREETING='it'\\''s $HOME' HADES_RUN_ID='hades-1'\n"
+
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestEnvPrefix_Empty(t *testing.T) {
+	if got := envPrefix(nil, nil); got != "" {
+		t.Errorf("Expected empty prefix, got %q", got)
+	}
+}
@@ -5,26 +5,29 @@ import (
 	"io"
 	"path/filepath"
 
+	"github.com/SoftKiwiGames/hades/hades/approval"
 	"github.com/SoftKiwiGames/hades/hades/artifacts"
 	"github.com/SoftKiwiGames/hades/hades/registry"
 	"github.com/SoftKiwiGames/hades/hades/ssh"
 )
 
 type Runtime struct {
-	SSHClient      ssh.Client
-	ArtifactMgr    artifacts.Manager
-	RegistryMgr    registry.Manager
-	Env            map[string]string
-	RunID          string
-	Plan           string
-	Target         string
-	Host           ssh.Host
-	Stdout         io.Writer // Logs only
-	Stderr         io.Writer // Logs only
-	ConsoleStdout  io.Writer // Console only
-	ConsoleStderr  io.Writer // Console only
-	ActionDesc     string    // For formatted console messages
-	SourceDir      string    // Directory of the YAML file that defined the job
+	SSHClient     ssh.Client
+	ArtifactMgr   artifacts.Manager
+	RegistryMgr   registry.Manager
+	Env           map[string]string
+	RunID         string
+	Plan          string
+	Target        string
+	Host          ssh.Host
+	Stdout        io.Writer         // Logs only
+	Stderr        io.Writer         // Logs only
+	ConsoleStdout io.Writer         // Console only
+	ConsoleStderr io.Writer         // Console only
+	ActionDesc    string            // For formatted console messages
+	SourceDir     string            // Directory of the YAML file that defined the job
+	Approver      approval.Approver // Answers approval gates, shared by the hosts of a batch
+	Skipped       bool              // Set by an action that found nothing to change
 }
 
 func NewRuntime(sshClient ssh.Client, artifactMgr artifacts.Manager, registryMgr registry.Manager, runID string, plan string, target string, host ssh.Host, userEnv map[string]string, stdout, stderr io.Writer, consoleStdout, consoleStderr io.Writer, sourceDir string) *Runtime {
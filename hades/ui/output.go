@@ -101,6 +101,15 @@ func (o *Output) PlanFailed(step, host string, err error) {
 	o.Info("Error: %v", err)
 }
 
+// RollbackSummary prints the outcome of a plan's on_failure run
+func (o *Output) RollbackSummary(name string, hosts int, err error) {
+	if err != nil {
+		o.Info("On failure: %s failed on %d host(s) - %v", name, hosts, err)
+		return
+	}
+	o.Info("On failure: %s completed on %d host(s)", name, hosts)
+}
+
 func (o *Output) DotRed() string {
 	// ⏺
 	return fmt.Sprint(ctc.ForegroundRed, "•", ctc.Reset)
@@ -0,0 +1,27 @@
+package utils
+
+import "strings"
+
+// archiveExtensions maps file extensions to archive formats, longest first
+var archiveExtensions = []struct {
+	ext    string
+	format string
+}{
+	{".tar.gz", "tar.gz"},
+	{".tar.zst", "tar.zst"},
+	{".tgz", "tar.gz"},
+	{".tzst", "tar.zst"},
+	{".tar", "tar"},
+	{".zip", "zip"},
+}
+
+// DetectArchiveFormat returns the archive format implied by the file name
+func DetectArchiveFormat(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, e := range archiveExtensions {
+		if strings.HasSuffix(lower, e.ext) {
+			return e.format, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestDetectArchiveFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOk bool
+	}{
+		{name: "release.tar.gz", want: "tar.gz", wantOk: true},
+		{name: "release.TGZ", want: "tar.gz", wantOk: true},
+		{name: "release.tar.zst", want: "tar.zst", wantOk: true},
+		{name: "release.tar", want: "tar", wantOk: true},
+		{name: "release.zip", want: "zip", wantOk: true},
+		{name: "release.bin", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DetectArchiveFormat(tt.name)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("DetectArchiveFormat(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}